@@ -47,11 +47,24 @@ func (p *Payload) Scan(src any) error {
 }
 
 const (
-	ActionCreated       = "created"
-	ActionStatusChanged = "status_changed"
-	ActionAssigned      = "assigned"
-	ActionMessageSent   = "message_sent"
-	ActionRated         = "rated"
+	ActionCreated         = "created"
+	ActionStatusChanged   = "status_changed"
+	ActionAssigned        = "assigned"
+	ActionMessageSent     = "message_sent"
+	ActionRated           = "rated"
+	ActionMerged          = "merged"
+	ActionTagAdded        = "tag_added"
+	ActionTagRemoved      = "tag_removed"
+	ActionFieldsUpdated   = "fields_updated"
+	ActionBotMatched      = "bot_matched"
+	ActionBotCommand      = "bot_command"
+	ActionBotLoopLimit    = "bot_loop_limit"
+	ActionBotVariant      = "bot_variant"
+	ActionBotWebhook      = "bot_webhook"
+	ActionBotFAQ          = "bot_faq"
+	ActionPriorityChanged = "priority_changed"
+	ActionTeamChanged     = "team_changed"
+	ActionCategoryChanged = "category_changed"
 
 	ActorUser = "user"
 )
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/AzizovHikmatullo/j-support/internal/activity_log"
+	"github.com/AzizovHikmatullo/j-support/internal/bulk"
 	"github.com/AzizovHikmatullo/j-support/internal/categories"
 	"github.com/AzizovHikmatullo/j-support/internal/channel"
 	channelDriverApp "github.com/AzizovHikmatullo/j-support/internal/channel/driverapp"
@@ -16,9 +17,12 @@ import (
 	channelWeb "github.com/AzizovHikmatullo/j-support/internal/channel/web"
 	"github.com/AzizovHikmatullo/j-support/internal/config"
 	"github.com/AzizovHikmatullo/j-support/internal/contacts"
+	"github.com/AzizovHikmatullo/j-support/internal/customfields"
+	"github.com/AzizovHikmatullo/j-support/internal/export"
 	"github.com/AzizovHikmatullo/j-support/internal/middleware"
 	"github.com/AzizovHikmatullo/j-support/internal/scenario"
 	"github.com/AzizovHikmatullo/j-support/internal/scheduler"
+	"github.com/AzizovHikmatullo/j-support/internal/tags"
 	"github.com/AzizovHikmatullo/j-support/internal/tickets"
 	"github.com/AzizovHikmatullo/j-support/internal/ws"
 	"github.com/gin-contrib/cors"
@@ -95,6 +99,41 @@ func (a *App) InitRoutes() {
 		categoriesRoutes.PATCH("/:id", middleware.RequireRole("admin"), categoriesHandler.Update)
 	}
 
+	// ---------
+	// TAGS
+	// ----------
+
+	tagsRepo := tags.NewRepository(a.db)
+	tagsService := tags.NewService(tagsRepo, a.logger)
+	tagsHandler := tags.NewHandler(tagsService, a.logger)
+
+	tagsRoutes := a.router.Group("/tags")
+	tagsRoutes.Use(middleware.AuthMiddleware(a.cfg.JWT.Secret))
+	{
+		tagsRoutes.GET("", middleware.RequireRole("support", "admin"), tagsHandler.GetAll)
+		tagsRoutes.GET("/stats", middleware.RequireRole("admin"), tagsHandler.Stats)
+		tagsRoutes.POST("", middleware.RequireRole("admin"), tagsHandler.Create)
+		tagsRoutes.PATCH("/:id", middleware.RequireRole("admin"), tagsHandler.Update)
+		tagsRoutes.DELETE("/:id", middleware.RequireRole("admin"), tagsHandler.Delete)
+	}
+
+	// ---------
+	// CUSTOM FIELDS
+	// ----------
+
+	fieldsRepo := customfields.NewRepository(a.db)
+	fieldsService := customfields.NewService(fieldsRepo, a.logger)
+	fieldsHandler := customfields.NewHandler(fieldsService, a.logger)
+
+	fieldsRoutes := a.router.Group("/fields")
+	fieldsRoutes.Use(middleware.AuthMiddleware(a.cfg.JWT.Secret))
+	{
+		fieldsRoutes.GET("", middleware.RequireRole("support", "admin"), fieldsHandler.GetAll)
+		fieldsRoutes.POST("", middleware.RequireRole("admin"), fieldsHandler.Create)
+		fieldsRoutes.PATCH("/:id", middleware.RequireRole("admin"), fieldsHandler.Update)
+		fieldsRoutes.DELETE("/:id", middleware.RequireRole("admin"), fieldsHandler.Delete)
+	}
+
 	// ---------
 	// ACTIVITY_LOG
 	// ----------
@@ -115,7 +154,16 @@ func (a *App) InitRoutes() {
 	// ----------
 
 	contactRepo := contacts.NewRepository(a.db)
-	contactService := contacts.NewService(contactRepo, a.logger)
+	contactService := contacts.NewService(contactRepo, fieldsRepo, a.logger)
+	contactHandler := contacts.NewHandler(contactService, a.logger)
+
+	contactRoutes := a.router.Group("/support/contacts")
+	contactRoutes.Use(middleware.AuthMiddleware(a.cfg.JWT.Secret))
+	{
+		contactRoutes.GET("/:id", middleware.RequireRole("support", "admin"), contactHandler.GetByID)
+		contactRoutes.PATCH("/:id/fields", middleware.RequireRole("support", "admin"), contactHandler.UpdateFields)
+		contactRoutes.PATCH("/:id/locale", middleware.RequireRole("support", "admin"), contactHandler.UpdateLocale)
+	}
 
 	// ---------
 	// CHANNELS
@@ -134,7 +182,7 @@ func (a *App) InitRoutes() {
 	// ----------
 
 	ticketsRepo := tickets.NewRepository(a.db)
-	ticketsService := tickets.NewService(ticketsRepo, categoriesRepo, publisher, nil, activityService, a.logger)
+	ticketsService := tickets.NewService(ticketsRepo, categoriesRepo, tagsRepo, fieldsRepo, publisher, nil, activityService, a.logger)
 	ticketsHandler := tickets.NewHandler(ticketsService, registry, a.logger)
 
 	// ---------
@@ -142,7 +190,7 @@ func (a *App) InitRoutes() {
 	// ----------
 
 	scenarioRepository := scenario.NewRepository(a.db)
-	scenarioService := scenario.NewService(scenarioRepository, ticketsService, a.logger)
+	scenarioService := scenario.NewService(scenarioRepository, ticketsService, fieldsRepo, tagsRepo, categoriesRepo, contactRepo, activityService, scenario.NewWebhookClient(a.cfg.Webhook.AllowedHosts), a.logger)
 	scenarioHandler := scenario.NewHandler(scenarioService, a.logger)
 
 	scenarioRoutes := a.router.Group("/scenarios")
@@ -150,9 +198,43 @@ func (a *App) InitRoutes() {
 	{
 		scenarioRoutes.POST("", middleware.RequireRole("admin"), scenarioHandler.Create)
 		scenarioRoutes.GET("", middleware.RequireRole("admin"), scenarioHandler.GetAll)
+		scenarioRoutes.GET("/experiments", middleware.RequireRole("admin"), scenarioHandler.Experiment)
+		scenarioRoutes.GET("/faq", middleware.RequireRole("admin"), scenarioHandler.GetFAQRules)
+		scenarioRoutes.POST("/faq", middleware.RequireRole("admin"), scenarioHandler.CreateFAQRule)
+		scenarioRoutes.PATCH("/faq/:ruleID", middleware.RequireRole("admin"), scenarioHandler.UpdateFAQRule)
+		scenarioRoutes.DELETE("/faq/:ruleID", middleware.RequireRole("admin"), scenarioHandler.DeleteFAQRule)
 		scenarioRoutes.GET("/:id", middleware.RequireRole("admin"), scenarioHandler.GetByID)
 		scenarioRoutes.PATCH("/:id", middleware.RequireRole("admin"), scenarioHandler.Update)
 		scenarioRoutes.DELETE("/:id", middleware.RequireRole("admin"), scenarioHandler.Delete)
+		scenarioRoutes.GET("/:id/commands", middleware.RequireRole("admin"), scenarioHandler.GetCommands)
+		scenarioRoutes.PUT("/:id/commands", middleware.RequireRole("admin"), scenarioHandler.UpdateCommands)
+		scenarioRoutes.DELETE("/:id/commands", middleware.RequireRole("admin"), scenarioHandler.ResetCommands)
+		scenarioRoutes.GET("/:id/timeout", middleware.RequireRole("admin"), scenarioHandler.GetTimeout)
+		scenarioRoutes.PUT("/:id/timeout", middleware.RequireRole("admin"), scenarioHandler.UpdateTimeout)
+		scenarioRoutes.DELETE("/:id/timeout", middleware.RequireRole("admin"), scenarioHandler.ResetTimeout)
+		scenarioRoutes.GET("/:id/targeting", middleware.RequireRole("admin"), scenarioHandler.GetTargeting)
+		scenarioRoutes.PUT("/:id/targeting", middleware.RequireRole("admin"), scenarioHandler.UpdateTargeting)
+		scenarioRoutes.DELETE("/:id/targeting", middleware.RequireRole("admin"), scenarioHandler.ResetTargeting)
+		scenarioRoutes.GET("/:id/schedule", middleware.RequireRole("admin"), scenarioHandler.GetSchedule)
+		scenarioRoutes.PUT("/:id/schedule", middleware.RequireRole("admin"), scenarioHandler.UpdateSchedule)
+		scenarioRoutes.DELETE("/:id/schedule", middleware.RequireRole("admin"), scenarioHandler.ResetSchedule)
+
+		scenarioRoutes.POST("/import", middleware.RequireRole("admin"), scenarioHandler.Import)
+		scenarioRoutes.POST("/:id/simulate", middleware.RequireRole("admin"), scenarioHandler.Simulate)
+		scenarioRoutes.GET("/:id/validate", middleware.RequireRole("admin"), scenarioHandler.Validate)
+		scenarioRoutes.GET("/:id/analytics", middleware.RequireRole("admin"), scenarioHandler.Analytics)
+		scenarioRoutes.GET("/:id/export", middleware.RequireRole("admin"), scenarioHandler.Export)
+		scenarioRoutes.GET("/:id/diagram", middleware.RequireRole("admin"), scenarioHandler.Diagram)
+		scenarioRoutes.POST("/:id/import", middleware.RequireRole("admin"), scenarioHandler.ImportInto)
+		scenarioRoutes.POST("/:id/clone", middleware.RequireRole("admin"), scenarioHandler.Clone)
+
+		scenarioRoutes.GET("/:id/versions", middleware.RequireRole("admin"), scenarioHandler.GetVersions)
+		scenarioRoutes.GET("/:id/versions/:versionID", middleware.RequireRole("admin"), scenarioHandler.GetVersion)
+		scenarioRoutes.POST("/:id/versions/:versionID/rollback", middleware.RequireRole("admin"), scenarioHandler.Rollback)
+		scenarioRoutes.GET("/:id/draft", middleware.RequireRole("admin"), scenarioHandler.GetDraft)
+		scenarioRoutes.POST("/:id/draft", middleware.RequireRole("admin"), scenarioHandler.CreateDraft)
+		scenarioRoutes.DELETE("/:id/draft", middleware.RequireRole("admin"), scenarioHandler.DiscardDraft)
+		scenarioRoutes.POST("/:id/publish", middleware.RequireRole("admin"), scenarioHandler.Publish)
 
 		scenarioRoutes.POST("/:id/steps", middleware.RequireRole("admin"), scenarioHandler.CreateStep)
 		scenarioRoutes.PATCH("/:id/steps/:stepID", middleware.RequireRole("admin"), scenarioHandler.UpdateStep)
@@ -188,10 +270,47 @@ func (a *App) InitRoutes() {
 		supportRoutes.GET(":id", middleware.RequireRole("support", "admin"), ticketsHandler.GetByID)
 		supportRoutes.PATCH(":id/assign", middleware.RequireRole("support", "admin"), ticketsHandler.ChangeAssigned)
 		supportRoutes.PATCH(":id/status", middleware.RequireRole("support", "admin"), ticketsHandler.ChangeStatus)
+		supportRoutes.PATCH(":id/priority", middleware.RequireRole("support", "admin"), ticketsHandler.ChangePriority)
+		supportRoutes.PATCH(":id/team", middleware.RequireRole("support", "admin"), ticketsHandler.ChangeTeam)
+		supportRoutes.PATCH(":id/category", middleware.RequireRole("support", "admin"), ticketsHandler.ChangeCategory)
+		supportRoutes.POST(":id/merge", middleware.RequireRole("support", "admin"), ticketsHandler.Merge)
+		supportRoutes.PATCH(":id/fields", middleware.RequireRole("support", "admin"), ticketsHandler.UpdateFields)
+		supportRoutes.GET(":id/tags", middleware.RequireRole("support", "admin"), ticketsHandler.GetTags)
+		supportRoutes.POST(":id/tags", middleware.RequireRole("support", "admin"), ticketsHandler.AddTag)
+		supportRoutes.DELETE(":id/tags/:tagID", middleware.RequireRole("support", "admin"), ticketsHandler.RemoveTag)
 		supportRoutes.POST(":id/messages", middleware.RequireRole("support", "admin"), idem, ticketsHandler.CreateMessageBySupport)
 		supportRoutes.GET(":id/messages", middleware.RequireRole("support", "admin"), ticketsHandler.GetMessagesForSupport)
 	}
 
+	// ---------
+	// BULK
+	// ----------
+
+	bulkRepo := bulk.NewRepository(a.db)
+	bulkService := bulk.NewService(bulkRepo, ticketsService, tagsRepo, a.logger)
+	bulkHandler := bulk.NewHandler(bulkService, a.logger)
+
+	bulkRoutes := a.router.Group("/support/bulk")
+	bulkRoutes.Use(middleware.AuthMiddleware(a.cfg.JWT.Secret))
+	{
+		bulkRoutes.POST("", middleware.RequireRole("support", "admin"), bulkHandler.Create)
+		bulkRoutes.GET("/:id", middleware.RequireRole("support", "admin"), bulkHandler.GetByID)
+	}
+
+	// ---------
+	// EXPORT
+	// ----------
+
+	exportRepo := export.NewRepository(a.db)
+	exportService := export.NewService(exportRepo, a.logger)
+	exportHandler := export.NewHandler(exportService, a.logger)
+
+	exportRoutes := a.router.Group("/export")
+	exportRoutes.Use(middleware.AuthMiddleware(a.cfg.JWT.Secret))
+	{
+		exportRoutes.GET("/tickets", middleware.RequireRole("admin"), exportHandler.Tickets)
+	}
+
 	// ---------
 	// WEBSOCKET
 	// ----------
@@ -217,7 +336,7 @@ func (a *App) InitRoutes() {
 	// SCHEDULER
 	// ----------
 
-	sched := scheduler.New(ticketsService, scenarioRepository, a.logger)
+	sched := scheduler.New(scenarioService, a.logger)
 
 	sched.Start()
 
@@ -0,0 +1,113 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/AzizovHikmatullo/j-support/internal/tags"
+	"github.com/AzizovHikmatullo/j-support/internal/tickets"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type Service interface {
+	Create(ctx context.Context, userID int, role string, req Request) (Job, error)
+	GetByID(ctx context.Context, userID int, role string, id uuid.UUID) (Job, error)
+}
+
+type handler struct {
+	service Service
+
+	logger *slog.Logger
+}
+
+func NewHandler(service Service, logger *slog.Logger) *handler {
+	return &handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// @Summary      Запустить массовую операцию над тикетами
+// @Description  Тикеты выбираются списком ticket_ids или фильтром. Действия применяются в фоне в порядке: назначение, сообщение, теги, статус.
+// @Tags         bulk
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        body  body  bulk.Request  true  "Выбор тикетов и действия"
+// @Success      202   {object}  bulk.Job
+// @Failure      400   {object}  map[string]string
+// @Failure      401   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /support/bulk [post]
+func (h *handler) Create(c *gin.Context) {
+	var req Request
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	role := c.GetString("role")
+	userID := c.GetInt("userID")
+
+	job, err := h.service.Create(c.Request.Context(), userID, role, req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// @Summary      Получить прогресс массовой операции
+// @Tags         bulk
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   string   true   "UUID задачи"
+// @Success      200   {object}  bulk.Job
+// @Failure      400   {object}  map[string]string
+// @Failure      403   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Router       /support/bulk/{id} [get]
+func (h *handler) GetByID(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	role := c.GetString("role")
+	userID := c.GetInt("userID")
+
+	job, err := h.service.GetByID(c.Request.Context(), userID, role, id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+func (h *handler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrNoTickets), errors.Is(err, ErrBothSelectors), errors.Is(err, ErrNoActions),
+		errors.Is(err, ErrTooManyTickets), errors.Is(err, ErrInvalidStatus), errors.Is(err, ErrEmptyMessage),
+		errors.Is(err, ErrNothingSelected), errors.Is(err, ErrEmptyFilter), errors.Is(err, tickets.ErrInvalidTag),
+		errors.Is(err, tickets.ErrInvalidPriority), errors.Is(err, tickets.ErrInvalidTeam):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrForbidden):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": ErrForbidden.Error()})
+	case errors.Is(err, ErrJobNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrJobNotFound.Error()})
+	case errors.Is(err, tags.ErrTagNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": tags.ErrTagNotFound.Error()})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		h.logger.Error("bulk error", "error", err.Error())
+	}
+}
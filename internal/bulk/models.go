@@ -0,0 +1,114 @@
+package bulk
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AzizovHikmatullo/j-support/internal/tickets"
+	"github.com/google/uuid"
+)
+
+type Job struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	CreatedBy  int        `json:"created_by" db:"created_by"`
+	ActorRole  string     `json:"actor_role" db:"actor_role"`
+	Status     string     `json:"status" db:"status"`
+	Params     Request    `json:"params" db:"params"`
+	Total      int        `json:"total" db:"total"`
+	Processed  int        `json:"processed" db:"processed"`
+	Failed     int        `json:"failed" db:"failed"`
+	Failures   Failures   `json:"failures" db:"failures"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	FinishedAt *time.Time `json:"finished_at" db:"finished_at"`
+}
+
+// Request selects tickets either by ID or by filter and lists the actions to
+// apply. Several actions in one request work as a macro and are applied to
+// each ticket in a fixed order: assignment, message, tags, status.
+type Request struct {
+	TicketIDs  []uuid.UUID     `json:"ticket_ids,omitempty"`
+	Filter     *tickets.Filter `json:"filter,omitempty"`
+	AssignedTo *int            `json:"assigned_to,omitempty"`
+	Message    *string         `json:"message,omitempty"`
+	AddTags    []int           `json:"add_tags,omitempty"`
+	RemoveTags []int           `json:"remove_tags,omitempty"`
+	Status     *string         `json:"status,omitempty"`
+}
+
+func (r Request) Value() (driver.Value, error) {
+	b, err := json.Marshal(r)
+	return string(b), err
+}
+
+func (r *Request) Scan(src any) error {
+	return scanJSON(src, r)
+}
+
+type Failure struct {
+	TicketID uuid.UUID `json:"ticket_id"`
+	Error    string    `json:"error"`
+}
+
+type Failures []Failure
+
+func (f Failures) Value() (driver.Value, error) {
+	if f == nil {
+		f = Failures{}
+	}
+	b, err := json.Marshal(f)
+	return string(b), err
+}
+
+func (f *Failures) Scan(src any) error {
+	return scanJSON(src, f)
+}
+
+func scanJSON(src any, dst any) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dst)
+	case string:
+		return json.Unmarshal([]byte(v), dst)
+	default:
+		return fmt.Errorf("unsupported type: %T", src)
+	}
+}
+
+const (
+	statusQueued   = "queued"
+	statusRunning  = "running"
+	statusFinished = "finished"
+
+	maxTickets    = 1000
+	ticketTimeout = 30 * time.Second
+)
+
+var (
+	ErrJobNotFound     = errors.New("bulk job not found")
+	ErrNoTickets       = errors.New("ticket_ids or filter is required")
+	ErrBothSelectors   = errors.New("use either ticket_ids or filter, not both")
+	ErrNoActions       = errors.New("at least one action is required")
+	ErrTooManyTickets  = errors.New("too many tickets for one bulk job")
+	ErrInvalidStatus   = errors.New("invalid status")
+	ErrEmptyMessage    = errors.New("message must not be empty")
+	ErrForbidden       = errors.New("forbidden")
+	ErrNothingSelected = errors.New("no tickets match the selection")
+	ErrEmptyFilter     = errors.New("filter must have at least one criterion")
+)
+
+func NewJob(createdBy int, role string, req Request, total int) Job {
+	return Job{
+		ID:        uuid.Must(uuid.NewV7()),
+		CreatedBy: createdBy,
+		ActorRole: role,
+		Status:    statusQueued,
+		Params:    req,
+		Total:     total,
+		Failures:  Failures{},
+	}
+}
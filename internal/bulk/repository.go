@@ -0,0 +1,91 @@
+package bulk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type postgresRepo struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) Repository {
+	return &postgresRepo{
+		db: db,
+	}
+}
+
+func (r *postgresRepo) Create(ctx context.Context, job *Job) error {
+	query := `
+		INSERT INTO bulk_jobs(id, created_by, actor_role, status, params, total, failures)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at
+	`
+
+	return r.db.QueryRowxContext(ctx, query,
+		job.ID,
+		job.CreatedBy,
+		job.ActorRole,
+		job.Status,
+		job.Params,
+		job.Total,
+		job.Failures,
+	).Scan(&job.CreatedAt)
+}
+
+func (r *postgresRepo) GetByID(ctx context.Context, id uuid.UUID) (Job, error) {
+	var job Job
+
+	query := `
+		SELECT *
+		FROM bulk_jobs
+		WHERE id = $1
+	`
+
+	err := r.db.GetContext(ctx, &job, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return job, ErrJobNotFound
+	}
+
+	return job, err
+}
+
+func (r *postgresRepo) SetStatus(ctx context.Context, id uuid.UUID, status string) error {
+	query := `
+		UPDATE bulk_jobs
+		SET status = $2
+		WHERE id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, id, status)
+
+	return err
+}
+
+func (r *postgresRepo) UpdateProgress(ctx context.Context, id uuid.UUID, processed int, failures Failures) error {
+	query := `
+		UPDATE bulk_jobs
+		SET processed = $2, failed = $3, failures = $4
+		WHERE id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, id, processed, len(failures), failures)
+
+	return err
+}
+
+func (r *postgresRepo) Finish(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE bulk_jobs
+		SET status = $2, finished_at = now()
+		WHERE id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, id, statusFinished)
+
+	return err
+}
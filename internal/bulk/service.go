@@ -0,0 +1,255 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/AzizovHikmatullo/j-support/internal/tags"
+	"github.com/AzizovHikmatullo/j-support/internal/tickets"
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	Create(ctx context.Context, job *Job) error
+	GetByID(ctx context.Context, id uuid.UUID) (Job, error)
+	SetStatus(ctx context.Context, id uuid.UUID, status string) error
+	UpdateProgress(ctx context.Context, id uuid.UUID, processed int, failures Failures) error
+	Finish(ctx context.Context, id uuid.UUID) error
+}
+
+type service struct {
+	repo          Repository
+	ticketService tickets.Service
+	tagRepo       tags.Repository
+
+	logger *slog.Logger
+}
+
+func NewService(repo Repository, ticketService tickets.Service, tagRepo tags.Repository, logger *slog.Logger) Service {
+	return &service{
+		repo:          repo,
+		ticketService: ticketService,
+		tagRepo:       tagRepo,
+		logger:        logger,
+	}
+}
+
+func (s *service) Create(ctx context.Context, userID int, role string, req Request) (Job, error) {
+	if err := s.validate(ctx, &req); err != nil {
+		return Job{}, err
+	}
+
+	ticketIDs, err := s.resolveTickets(ctx, userID, role, req)
+	if err != nil {
+		return Job{}, err
+	}
+
+	job := NewJob(userID, role, req, len(ticketIDs))
+
+	if err = s.repo.Create(ctx, &job); err != nil {
+		return Job{}, fmt.Errorf("create bulk job: %w", err)
+	}
+
+	go s.run(job, ticketIDs)
+
+	s.logger.Info("bulk job created", "id", job.ID.String(), "tickets", job.Total)
+	return job, nil
+}
+
+func (s *service) GetByID(ctx context.Context, userID int, role string, id uuid.UUID) (Job, error) {
+	job, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return Job{}, fmt.Errorf("get bulk job: %w", err)
+	}
+
+	if role != "admin" && job.CreatedBy != userID {
+		return Job{}, ErrForbidden
+	}
+
+	return job, nil
+}
+
+func (s *service) validate(ctx context.Context, req *Request) error {
+	if len(req.TicketIDs) == 0 && req.Filter == nil {
+		return ErrNoTickets
+	}
+	if len(req.TicketIDs) > 0 && req.Filter != nil {
+		return ErrBothSelectors
+	}
+	if len(req.TicketIDs) > maxTickets {
+		return ErrTooManyTickets
+	}
+
+	if req.Filter != nil {
+		filter, err := tickets.NormalizeFilter(*req.Filter)
+		if err != nil {
+			return err
+		}
+		if filter.Empty() {
+			return ErrEmptyFilter
+		}
+		if filter.Status != "" && !tickets.ValidStatus(filter.Status) {
+			return ErrInvalidStatus
+		}
+		req.Filter = &filter
+	}
+
+	if req.AssignedTo == nil && req.Message == nil && req.Status == nil && len(req.AddTags) == 0 && len(req.RemoveTags) == 0 {
+		return ErrNoActions
+	}
+
+	if req.Status != nil && !tickets.ValidStatus(*req.Status) {
+		return ErrInvalidStatus
+	}
+
+	if req.Message != nil {
+		message := strings.TrimSpace(*req.Message)
+		if message == "" {
+			return ErrEmptyMessage
+		}
+		req.Message = &message
+	}
+
+	for _, tagID := range append(req.AddTags, req.RemoveTags...) {
+		if _, err := s.tagRepo.GetByID(ctx, tagID); err != nil {
+			return fmt.Errorf("get tag: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *service) resolveTickets(ctx context.Context, userID int, role string, req Request) ([]uuid.UUID, error) {
+	if req.Filter == nil {
+		return uniqueIDs(req.TicketIDs), nil
+	}
+
+	found, err := s.ticketService.Get(ctx, role, userID, *req.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("get tickets by filter: %w", err)
+	}
+
+	if len(found) == 0 {
+		return nil, ErrNothingSelected
+	}
+	if len(found) > maxTickets {
+		return nil, ErrTooManyTickets
+	}
+
+	ids := make([]uuid.UUID, 0, len(found))
+	for _, ticket := range found {
+		ids = append(ids, ticket.ID)
+	}
+
+	return ids, nil
+}
+
+func (s *service) run(job Job, ticketIDs []uuid.UUID) {
+	ctx := context.Background()
+
+	if err := s.repo.SetStatus(ctx, job.ID, statusRunning); err != nil {
+		s.logger.Error("failed to start bulk job", "id", job.ID.String(), "error", err.Error())
+	}
+
+	failures := Failures{}
+
+	for i, ticketID := range ticketIDs {
+		if err := s.apply(ctx, job, ticketID); err != nil {
+			failures = append(failures, Failure{TicketID: ticketID, Error: failureReason(err)})
+			s.logger.Warn("bulk job ticket failed", "id", job.ID.String(), "ticket id", ticketID.String(), "error", err.Error())
+		}
+
+		if err := s.repo.UpdateProgress(ctx, job.ID, i+1, failures); err != nil {
+			s.logger.Error("failed to update bulk job progress", "id", job.ID.String(), "error", err.Error())
+		}
+	}
+
+	if err := s.repo.Finish(ctx, job.ID); err != nil {
+		s.logger.Error("failed to finish bulk job", "id", job.ID.String(), "error", err.Error())
+	}
+
+	s.logger.Info("bulk job finished", "id", job.ID.String(), "processed", len(ticketIDs), "failed", len(failures))
+}
+
+func (s *service) apply(ctx context.Context, job Job, ticketID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, ticketTimeout)
+	defer cancel()
+
+	userID, role, req := job.CreatedBy, job.ActorRole, job.Params
+
+	if _, err := s.ticketService.GetByID(ctx, userID, role, ticketID); err != nil {
+		return err
+	}
+
+	if req.AssignedTo != nil {
+		if _, err := s.ticketService.ChangeAssigned(ctx, userID, role, ticketID, *req.AssignedTo); err != nil {
+			return fmt.Errorf("assign: %w", err)
+		}
+	}
+
+	if req.Message != nil {
+		if _, err := s.ticketService.CreateMessage(ctx, ticketID, userID, role, *req.Message); err != nil {
+			return fmt.Errorf("message: %w", err)
+		}
+	}
+
+	for _, tagID := range req.AddTags {
+		if _, err := s.ticketService.AddTag(ctx, userID, role, ticketID, tagID); err != nil {
+			return fmt.Errorf("add tag: %w", err)
+		}
+	}
+
+	for _, tagID := range req.RemoveTags {
+		if _, err := s.ticketService.RemoveTag(ctx, userID, role, ticketID, tagID); err != nil {
+			return fmt.Errorf("remove tag: %w", err)
+		}
+	}
+
+	if req.Status != nil {
+		if err := s.ticketService.ChangeStatus(ctx, userID, role, ticketID, *req.Status); err != nil {
+			return fmt.Errorf("status: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// failureReason keeps known domain errors readable and hides internal ones,
+// since failures are returned to the client as is.
+func failureReason(err error) string {
+	known := []error{
+		tickets.ErrTicketNotFound,
+		tickets.ErrForbidden,
+		tickets.ErrClosedTicket,
+		tickets.ErrCannotAssign,
+		tickets.ErrInvalidStatus,
+		tickets.ErrTicketMerged,
+		tags.ErrTagNotFound,
+	}
+
+	for _, target := range known {
+		if errors.Is(err, target) {
+			return err.Error()
+		}
+	}
+
+	return "internal error"
+}
+
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]struct{}, len(ids))
+	result := make([]uuid.UUID, 0, len(ids))
+
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		result = append(result, id)
+	}
+
+	return result
+}
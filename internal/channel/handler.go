@@ -23,6 +23,7 @@ func NewHandler(service contacts.Service) *handler {
 // @Accept       json
 // @Produce      json
 // @Param        body  body  channel.InitWebRequest  true  "Имя и телефон"
+// @Param        Accept-Language  header  string  false  "Язык бота, если locale не передан"
 // @Success      200   {object}  map[string]interface{} "contact"
 // @Failure      400   {object}  map[string]string
 // @Failure      500   {object}  map[string]string
@@ -36,7 +37,7 @@ func (h *handler) InitWeb(c *gin.Context) {
 
 	sessionID := "js_" + uuid.Must(uuid.NewV7()).String()
 
-	contact, err := h.contactService.InitContact(c.Request.Context(), sessionID, req.Name, req.Phone, ChannelWeb)
+	contact, err := h.contactService.InitContact(c.Request.Context(), sessionID, req.Name, req.Phone, requestLocale(c, req.Locale), ChannelWeb)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to create contact"})
 		return
@@ -52,6 +53,7 @@ func (h *handler) InitWeb(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Param        body  body  channel.InitTelegramRequest  true  "telegram_id, имя и телефон"
+// @Param        Accept-Language  header  string  false  "Язык бота, если locale не передан"
 // @Success      200   {object}  map[string]interface{} "contact"
 // @Failure      400   {object}  map[string]string
 // @Failure      500   {object}  map[string]string
@@ -63,7 +65,7 @@ func (h *handler) InitTelegram(c *gin.Context) {
 		return
 	}
 
-	contact, err := h.contactService.InitContact(c.Request.Context(), req.TelegramID, req.Name, req.Phone, ChannelTelegram)
+	contact, err := h.contactService.InitContact(c.Request.Context(), req.TelegramID, req.Name, req.Phone, requestLocale(c, req.Locale), ChannelTelegram)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to create contact"})
 		return
@@ -73,3 +75,12 @@ func (h *handler) InitTelegram(c *gin.Context) {
 		"contact": contact,
 	})
 }
+
+// requestLocale prefers the locale sent by the client, e.g. the Telegram
+// language code, and falls back to the Accept-Language header.
+func requestLocale(c *gin.Context, locale string) string {
+	if locale != "" {
+		return locale
+	}
+	return contacts.MatchLocale(c.GetHeader("Accept-Language"))
+}
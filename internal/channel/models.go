@@ -20,12 +20,14 @@ type Identity struct {
 }
 
 type InitWebRequest struct {
-	Name  string `json:"name"  binding:"required"`
-	Phone string `json:"phone" binding:"required"`
+	Name   string `json:"name"  binding:"required"`
+	Phone  string `json:"phone" binding:"required"`
+	Locale string `json:"locale"`
 }
 
 type InitTelegramRequest struct {
 	TelegramID string `json:"telegram_id"  binding:"required"`
 	Name       string `json:"name"  binding:"required"`
 	Phone      string `json:"phone" binding:"required"`
+	Locale     string `json:"locale"`
 }
@@ -28,6 +28,9 @@ type Config struct {
 	WS struct {
 		AllowedOrigins []string
 	}
+	Webhook struct {
+		AllowedHosts []string
+	}
 }
 
 func LoadConfig() (*Config, error) {
@@ -51,5 +54,7 @@ func LoadConfig() (*Config, error) {
 
 	cfg.WS.AllowedOrigins = strings.Split(os.Getenv("WS_ORIGINS"), ",")
 
+	cfg.Webhook.AllowedHosts = strings.Split(os.Getenv("WEBHOOK_ALLOWED_HOSTS"), ",")
+
 	return cfg, nil
 }
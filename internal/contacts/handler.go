@@ -2,10 +2,146 @@ package contacts
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/AzizovHikmatullo/j-support/internal/customfields"
+	"github.com/gin-gonic/gin"
 )
 
 type Service interface {
 	Resolve(ctx context.Context, userID, externalID *string, source string) (Contact, error)
 	Update(ctx context.Context, id int, name, phone string) (Contact, error)
-	InitContact(ctx context.Context, externalID, name, phone, source string) (Contact, error)
+	InitContact(ctx context.Context, externalID, name, phone, locale, source string) (Contact, error)
+	GetByID(ctx context.Context, id int) (Contact, error)
+	UpdateFields(ctx context.Context, id int, values map[string]any) (Contact, error)
+	UpdateLocale(ctx context.Context, id int, locale string) (Contact, error)
+}
+
+type handler struct {
+	service Service
+
+	logger *slog.Logger
+}
+
+func NewHandler(service Service, logger *slog.Logger) *handler {
+	return &handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// @Summary      Получить контакт
+// @Tags         contacts
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path  int  true  "ID контакта"
+// @Success      200  {object}  contacts.Contact
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /support/contacts/{id} [get]
+func (h *handler) GetByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid contact id"})
+		return
+	}
+
+	contact, err := h.service.GetByID(c.Request.Context(), id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, contact)
+}
+
+// @Summary      Изменить пользовательские поля контакта
+// @Description  Значение null удаляет необязательное поле
+// @Tags         contacts
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id    path  int                           true  "ID контакта"
+// @Param        body  body  contacts.UpdateFieldsRequest  true  "Значения полей"
+// @Success      200   {object}  contacts.Contact
+// @Failure      400   {object}  map[string]any
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /support/contacts/{id}/fields [patch]
+func (h *handler) UpdateFields(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid contact id"})
+		return
+	}
+
+	var req UpdateFieldsRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	contact, err := h.service.UpdateFields(c.Request.Context(), id, req.Fields)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, contact)
+}
+
+// @Summary      Изменить язык контакта
+// @Description  Бот отвечает на этом языке в новых тикетах: ru, tg или en
+// @Tags         contacts
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id    path  int                           true  "ID контакта"
+// @Param        body  body  contacts.UpdateLocaleRequest  true  "Язык"
+// @Success      200   {object}  contacts.Contact
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /support/contacts/{id}/locale [patch]
+func (h *handler) UpdateLocale(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid contact id"})
+		return
+	}
+
+	var req UpdateLocaleRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	contact, err := h.service.UpdateLocale(c.Request.Context(), id, req.Locale)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, contact)
+}
+
+func (h *handler) handleError(c *gin.Context, err error) {
+	var fieldsErr customfields.ValidationError
+
+	switch {
+	case errors.As(err, &fieldsErr):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid fields", "fields": fieldsErr})
+	case errors.Is(err, ErrInvalidLocale):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrInvalidLocale.Error()})
+	case errors.Is(err, ErrContactNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrContactNotFound.Error()})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		h.logger.Error("contact error", "error", err.Error())
+	}
 }
@@ -0,0 +1,34 @@
+package contacts
+
+import (
+	"slices"
+	"strings"
+)
+
+// NormalizeLocale lowercases the locale and drops the region, so "en-US" and
+// "EN" both become "en". It reports whether the locale is supported.
+func NormalizeLocale(locale string) (string, bool) {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+
+	if !slices.Contains(Locales, locale) {
+		return "", false
+	}
+
+	return locale, true
+}
+
+// MatchLocale returns the first supported locale of an Accept-Language
+// header, or an empty string. Quality values are ignored: browsers list the
+// preferred languages first.
+func MatchLocale(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		if locale, ok := NormalizeLocale(tag); ok {
+			return locale
+		}
+	}
+	return ""
+}
@@ -4,16 +4,20 @@ import (
 	"errors"
 	"regexp"
 	"time"
+
+	"github.com/AzizovHikmatullo/j-support/internal/customfields"
 )
 
 type Contact struct {
-	ID         int       `db:"id" json:"id"`
-	UserID     *string   `db:"user_id" json:"user_id,omitempty"`
-	ExternalID *string   `db:"external_id" json:"external_id,omitempty"`
-	Name       *string   `db:"name" json:"name,omitempty"`
-	Phone      *string   `db:"phone" json:"phone,omitempty"`
-	Source     string    `db:"source" json:"source"`
-	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	ID         int                 `db:"id" json:"id"`
+	UserID     *string             `db:"user_id" json:"user_id,omitempty"`
+	ExternalID *string             `db:"external_id" json:"external_id,omitempty"`
+	Name       *string             `db:"name" json:"name,omitempty"`
+	Phone      *string             `db:"phone" json:"phone,omitempty"`
+	Source     string              `db:"source" json:"source"`
+	Metadata   customfields.Values `db:"metadata" json:"metadata,omitempty"`
+	Locale     *string             `db:"locale" json:"locale,omitempty"`
+	CreatedAt  time.Time           `db:"created_at" json:"created_at"`
 }
 
 type UpdateContactRequest struct {
@@ -21,10 +25,31 @@ type UpdateContactRequest struct {
 	Phone string `json:"phone" binding:"required"`
 }
 
+type UpdateFieldsRequest struct {
+	Fields map[string]any `json:"fields" binding:"required"`
+}
+
+type UpdateLocaleRequest struct {
+	Locale string `json:"locale" binding:"required"`
+}
+
+// Locales the bot speaks. Scenario content without a translation falls back
+// to the default locale.
+const (
+	LocaleRussian = "ru"
+	LocaleTajik   = "tg"
+	LocaleEnglish = "en"
+
+	DefaultLocale = LocaleRussian
+)
+
+var Locales = []string{LocaleRussian, LocaleTajik, LocaleEnglish}
+
 var tjPhoneRegex = regexp.MustCompile(`^(?:\+992|992)?\d{9}$`)
 
 var (
 	ErrContactNotFound = errors.New("contact not found")
 	ErrInvalidPhone    = errors.New("invalid phone")
 	ErrInvalidName     = errors.New("invalid name")
+	ErrInvalidLocale   = errors.New("locale must be one of ru, tg, en")
 )
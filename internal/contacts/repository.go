@@ -4,7 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+
+	"github.com/AzizovHikmatullo/j-support/internal/customfields"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type postgresRepo struct {
@@ -124,3 +127,47 @@ func (r *postgresRepo) Update(ctx context.Context, id int, name, phone string) (
 
 	return contact, err
 }
+
+func (r *postgresRepo) UpdateLocale(ctx context.Context, id int, locale string) (Contact, error) {
+	var contact Contact
+
+	query := `
+		UPDATE contacts
+		SET locale = $2
+		WHERE id = $1
+		RETURNING *
+	`
+
+	err := r.db.QueryRowxContext(ctx, query, id, locale).StructScan(&contact)
+	if errors.Is(err, sql.ErrNoRows) {
+		return contact, ErrContactNotFound
+	}
+
+	return contact, err
+}
+
+func (r *postgresRepo) MergeMetadata(ctx context.Context, id int, set customfields.Values, unset []string) (Contact, error) {
+	var contact Contact
+
+	if set == nil {
+		set = customfields.Values{}
+	}
+
+	query := `
+		UPDATE contacts
+		SET metadata = (coalesce(metadata, '{}'::jsonb) || $2::jsonb) - $3::text[]
+		WHERE id = $1
+		RETURNING *
+	`
+
+	err := r.db.QueryRowxContext(ctx, query,
+		id,
+		set,
+		pq.StringArray(unset),
+	).StructScan(&contact)
+	if errors.Is(err, sql.ErrNoRows) {
+		return contact, ErrContactNotFound
+	}
+
+	return contact, err
+}
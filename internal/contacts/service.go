@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+
+	"github.com/AzizovHikmatullo/j-support/internal/customfields"
 )
 
 type Repository interface {
@@ -14,18 +16,22 @@ type Repository interface {
 	GetByID(ctx context.Context, id int) (Contact, error)
 	Create(ctx context.Context, contact *Contact) error
 	Update(ctx context.Context, id int, name, phone string) (Contact, error)
+	MergeMetadata(ctx context.Context, id int, set customfields.Values, unset []string) (Contact, error)
+	UpdateLocale(ctx context.Context, id int, locale string) (Contact, error)
 }
 
 type service struct {
-	repo Repository
+	repo      Repository
+	fieldRepo customfields.Repository
 
 	logger *slog.Logger
 }
 
-func NewService(repo Repository, logger *slog.Logger) Service {
+func NewService(repo Repository, fieldRepo customfields.Repository, logger *slog.Logger) Service {
 	return &service{
-		repo:   repo,
-		logger: logger,
+		repo:      repo,
+		fieldRepo: fieldRepo,
+		logger:    logger,
 	}
 }
 
@@ -96,12 +102,20 @@ func (s *service) Update(ctx context.Context, id int, name, phone string) (Conta
 	return updatedContact, nil
 }
 
-func (s *service) InitContact(ctx context.Context, externalID, name, phone, source string) (Contact, error) {
+// InitContact finds or creates the contact of a widget or bot user. A known
+// locale is stored on the contact; an unknown one is ignored, so the bot
+// falls back to the default language.
+func (s *service) InitContact(ctx context.Context, externalID, name, phone, locale, source string) (Contact, error) {
+	locale, _ = NormalizeLocale(locale)
+
 	c, err := s.repo.GetByPhone(ctx, phone, source)
 	if err == nil {
 		if c.ExternalID == nil {
 			c.ExternalID = &externalID
 		}
+		if locale != "" && (c.Locale == nil || *c.Locale != locale) {
+			return s.repo.UpdateLocale(ctx, c.ID, locale)
+		}
 		return c, nil
 	}
 
@@ -118,10 +132,72 @@ func (s *service) InitContact(ctx context.Context, externalID, name, phone, sour
 	if err != nil {
 		return Contact{}, err
 	}
+
+	if locale != "" {
+		updatedContact, err = s.repo.UpdateLocale(ctx, contact.ID, locale)
+		if err != nil {
+			return Contact{}, err
+		}
+	}
 	s.logger.Info("contact initialized", "id", updatedContact.ID, "name", updatedContact.Name, "phone", updatedContact.Phone)
 	return updatedContact, err
 }
 
+func (s *service) GetByID(ctx context.Context, id int) (Contact, error) {
+	contact, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return Contact{}, fmt.Errorf("get contact by id: %w", err)
+	}
+	return contact, nil
+}
+
+func (s *service) UpdateLocale(ctx context.Context, id int, locale string) (Contact, error) {
+	locale, ok := NormalizeLocale(locale)
+	if !ok {
+		return Contact{}, ErrInvalidLocale
+	}
+
+	contact, err := s.repo.UpdateLocale(ctx, id, locale)
+	if err != nil {
+		return Contact{}, fmt.Errorf("update contact locale: %w", err)
+	}
+	s.logger.Info("contact locale updated", "id", contact.ID, "locale", locale)
+	return contact, nil
+}
+
+func (s *service) UpdateFields(ctx context.Context, id int, values map[string]any) (Contact, error) {
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return Contact{}, fmt.Errorf("get contact by id: %w", err)
+	}
+
+	definitions, err := s.fieldRepo.GetForContacts(ctx)
+	if err != nil {
+		return Contact{}, fmt.Errorf("get contact fields: %w", err)
+	}
+
+	validated, err := customfields.Validate(definitions, values, true)
+	if err != nil {
+		return Contact{}, err
+	}
+
+	set := make(customfields.Values, len(validated))
+	var unset []string
+	for key, value := range validated {
+		if value == nil {
+			unset = append(unset, key)
+			continue
+		}
+		set[key] = value
+	}
+
+	contact, err := s.repo.MergeMetadata(ctx, id, set, unset)
+	if err != nil {
+		return Contact{}, fmt.Errorf("update contact fields: %w", err)
+	}
+	s.logger.Info("contact fields updated", "id", contact.ID)
+	return contact, nil
+}
+
 func (s *service) checkPhone(phone string) bool {
 	return tjPhoneRegex.MatchString(phone)
 }
@@ -0,0 +1,168 @@
+package customfields
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Service interface {
+	Create(ctx context.Context, req CreateFieldRequest) (Field, error)
+	GetAll(ctx context.Context, entity string, categoryID *int) ([]Field, error)
+	Update(ctx context.Context, id int, req UpdateFieldRequest) (Field, error)
+	Delete(ctx context.Context, id int) error
+}
+
+type handler struct {
+	service Service
+
+	logger *slog.Logger
+}
+
+func NewHandler(service Service, logger *slog.Logger) *handler {
+	return &handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// @Summary      Создать пользовательское поле
+// @Description  Только администратор. entity: ticket (поле тикета, category_id - необязательная категория) или contact
+// @Tags         fields
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        body  body  customfields.CreateFieldRequest  true  "Поле"
+// @Success      201   {object}  customfields.Field
+// @Failure      400   {object}  map[string]string
+// @Failure      409   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /fields [post]
+func (h *handler) Create(c *gin.Context) {
+	var req CreateFieldRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	field, err := h.service.Create(c.Request.Context(), req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, field)
+}
+
+// @Summary      Получить пользовательские поля
+// @Tags         fields
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        entity       query  string  false  "ticket или contact"
+// @Param        category_id  query  int     false  "ID категории"
+// @Success      200  {array}   customfields.Field
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /fields [get]
+func (h *handler) GetAll(c *gin.Context) {
+	var categoryID *int
+	if raw := c.Query("category_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid category id"})
+			return
+		}
+		categoryID = &id
+	}
+
+	fields, err := h.service.GetAll(c.Request.Context(), c.Query("entity"), categoryID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, fields)
+}
+
+// @Summary      Обновить пользовательское поле
+// @Description  Только администратор
+// @Tags         fields
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id    path  int                              true  "ID поля"
+// @Param        body  body  customfields.UpdateFieldRequest  true  "Обновление"
+// @Success      200   {object}  customfields.Field
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /fields/{id} [patch]
+func (h *handler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid field id"})
+		return
+	}
+
+	var req UpdateFieldRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	field, err := h.service.Update(c.Request.Context(), id, req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, field)
+}
+
+// @Summary      Удалить пользовательское поле
+// @Description  Только администратор. Уже сохранённые значения остаются в metadata
+// @Tags         fields
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path  int  true  "ID поля"
+// @Success      200  {object}  map[string]bool
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /fields/{id} [delete]
+func (h *handler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid field id"})
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), id); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (h *handler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidEntity), errors.Is(err, ErrInvalidKey), errors.Is(err, ErrInvalidType),
+		errors.Is(err, ErrInvalidLabel), errors.Is(err, ErrInvalidOptions), errors.Is(err, ErrContactCategory):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrFieldNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrFieldNotFound.Error()})
+	case errors.Is(err, ErrFieldExists):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": ErrFieldExists.Error()})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		h.logger.Error("custom field error", "error", err.Error())
+	}
+}
@@ -0,0 +1,119 @@
+package customfields
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type Field struct {
+	ID         int            `json:"id" db:"id"`
+	Entity     string         `json:"entity" db:"entity"`
+	CategoryID *int           `json:"category_id" db:"category_id"`
+	Key        string         `json:"key" db:"key"`
+	Label      string         `json:"label" db:"label"`
+	Type       string         `json:"type" db:"type"`
+	Required   bool           `json:"required" db:"required"`
+	Options    pq.StringArray `json:"options" db:"options"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+}
+
+type CreateFieldRequest struct {
+	Entity     string   `json:"entity" binding:"required"`
+	CategoryID *int     `json:"category_id"`
+	Key        string   `json:"key" binding:"required"`
+	Label      string   `json:"label" binding:"required"`
+	Type       string   `json:"type" binding:"required"`
+	Required   bool     `json:"required"`
+	Options    []string `json:"options"`
+}
+
+type UpdateFieldRequest struct {
+	Label    *string  `json:"label"`
+	Required *bool    `json:"required"`
+	Options  []string `json:"options"`
+}
+
+// Values holds custom field values keyed by field key. A nil value in an
+// update request removes the key.
+type Values map[string]any
+
+func (v Values) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func (v *Values) Scan(src any) error {
+	if src == nil {
+		*v = nil
+		return nil
+	}
+
+	switch s := src.(type) {
+	case []byte:
+		return json.Unmarshal(s, v)
+	case string:
+		return json.Unmarshal([]byte(s), v)
+	default:
+		return fmt.Errorf("unsupported type: %T", src)
+	}
+}
+
+// ValidationError maps field keys to the reason their value was rejected.
+type ValidationError map[string]string
+
+func (e ValidationError) Error() string {
+	keys := make([]string, 0, len(e))
+	for k := range e {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + ": " + e[k]
+	}
+	return "invalid fields: " + strings.Join(parts, "; ")
+}
+
+const (
+	EntityTicket  = "ticket"
+	EntityContact = "contact"
+
+	TypeText    = "text"
+	TypeNumber  = "number"
+	TypeEnum    = "enum"
+	TypeDate    = "date"
+	TypeBoolean = "boolean"
+)
+
+var fieldTypes = map[string]bool{
+	TypeText:    true,
+	TypeNumber:  true,
+	TypeEnum:    true,
+	TypeDate:    true,
+	TypeBoolean: true,
+}
+
+var keyRegex = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+var (
+	ErrFieldNotFound   = errors.New("field not found")
+	ErrFieldExists     = errors.New("field with this key already exists")
+	ErrInvalidEntity   = errors.New("entity must be ticket or contact")
+	ErrInvalidKey      = errors.New("key must be lowercase latin letters, digits and underscores")
+	ErrInvalidType     = errors.New("type must be one of text, number, enum, date, boolean")
+	ErrInvalidLabel    = errors.New("invalid label")
+	ErrInvalidOptions  = errors.New("enum field requires options")
+	ErrContactCategory = errors.New("contact fields cannot belong to a category")
+)
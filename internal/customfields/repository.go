@@ -0,0 +1,163 @@
+package customfields
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type postgresRepo struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) Repository {
+	return &postgresRepo{
+		db: db,
+	}
+}
+
+func (r *postgresRepo) Create(ctx context.Context, req CreateFieldRequest) (Field, error) {
+	var field Field
+
+	query := `
+		INSERT INTO custom_fields(entity, category_id, key, label, type, required, options)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING *
+	`
+
+	err := r.db.QueryRowxContext(ctx, query,
+		req.Entity,
+		req.CategoryID,
+		req.Key,
+		req.Label,
+		req.Type,
+		req.Required,
+		pq.StringArray(req.Options),
+	).StructScan(&field)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return field, ErrFieldExists
+	}
+
+	return field, err
+}
+
+func (r *postgresRepo) GetByID(ctx context.Context, id int) (Field, error) {
+	var field Field
+
+	query := `
+		SELECT *
+		FROM custom_fields
+		WHERE id = $1
+	`
+
+	err := r.db.GetContext(ctx, &field, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return field, ErrFieldNotFound
+	}
+
+	return field, err
+}
+
+func (r *postgresRepo) GetAll(ctx context.Context, entity string, categoryID *int) ([]Field, error) {
+	fields := make([]Field, 0)
+
+	builder := squirrel.Select("*").
+		PlaceholderFormat(squirrel.Dollar).
+		From("custom_fields").
+		OrderBy("entity", "category_id NULLS FIRST", "id")
+
+	if entity != "" {
+		builder = builder.Where(squirrel.Eq{"entity": entity})
+	}
+
+	if categoryID != nil {
+		builder = builder.Where(squirrel.Eq{"category_id": *categoryID})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return fields, err
+	}
+
+	err = r.db.SelectContext(ctx, &fields, query, args...)
+
+	return fields, err
+}
+
+func (r *postgresRepo) GetForCategory(ctx context.Context, categoryID int) ([]Field, error) {
+	fields := make([]Field, 0)
+
+	query := `
+		SELECT *
+		FROM custom_fields
+		WHERE entity = $1 AND (category_id = $2 OR category_id IS NULL)
+		ORDER BY id
+	`
+
+	err := r.db.SelectContext(ctx, &fields, query, EntityTicket, categoryID)
+
+	return fields, err
+}
+
+func (r *postgresRepo) GetForContacts(ctx context.Context) ([]Field, error) {
+	fields := make([]Field, 0)
+
+	query := `
+		SELECT *
+		FROM custom_fields
+		WHERE entity = $1
+		ORDER BY id
+	`
+
+	err := r.db.SelectContext(ctx, &fields, query, EntityContact)
+
+	return fields, err
+}
+
+func (r *postgresRepo) Update(ctx context.Context, id int, req UpdateFieldRequest) (Field, error) {
+	var field Field
+
+	builder := squirrel.Update("custom_fields").
+		PlaceholderFormat(squirrel.Dollar).
+		Where(squirrel.Eq{"id": id})
+
+	if req.Label != nil {
+		builder = builder.Set("label", *req.Label)
+	}
+
+	if req.Required != nil {
+		builder = builder.Set("required", *req.Required)
+	}
+
+	if req.Options != nil {
+		builder = builder.Set("options", pq.StringArray(req.Options))
+	}
+
+	builder = builder.Suffix("RETURNING *")
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return Field{}, err
+	}
+
+	err = r.db.QueryRowxContext(ctx, query, args...).StructScan(&field)
+	if errors.Is(err, sql.ErrNoRows) {
+		return field, ErrFieldNotFound
+	}
+
+	return field, err
+}
+
+func (r *postgresRepo) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM custom_fields WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+
+	return err
+}
@@ -0,0 +1,234 @@
+package customfields
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Repository interface {
+	Create(ctx context.Context, req CreateFieldRequest) (Field, error)
+	GetByID(ctx context.Context, id int) (Field, error)
+	GetAll(ctx context.Context, entity string, categoryID *int) ([]Field, error)
+	GetForCategory(ctx context.Context, categoryID int) ([]Field, error)
+	GetForContacts(ctx context.Context) ([]Field, error)
+	Update(ctx context.Context, id int, req UpdateFieldRequest) (Field, error)
+	Delete(ctx context.Context, id int) error
+}
+
+type service struct {
+	repo Repository
+
+	logger *slog.Logger
+}
+
+func NewService(repo Repository, logger *slog.Logger) Service {
+	return &service{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+func (s *service) Create(ctx context.Context, req CreateFieldRequest) (Field, error) {
+	if req.Entity != EntityTicket && req.Entity != EntityContact {
+		return Field{}, ErrInvalidEntity
+	}
+
+	if req.Entity == EntityContact && req.CategoryID != nil {
+		return Field{}, ErrContactCategory
+	}
+
+	if !keyRegex.MatchString(req.Key) {
+		return Field{}, ErrInvalidKey
+	}
+
+	if strings.TrimSpace(req.Label) == "" {
+		return Field{}, ErrInvalidLabel
+	}
+
+	if !fieldTypes[req.Type] {
+		return Field{}, ErrInvalidType
+	}
+
+	if req.Type == TypeEnum && len(req.Options) == 0 {
+		return Field{}, ErrInvalidOptions
+	}
+
+	if req.Type != TypeEnum {
+		req.Options = []string{}
+	}
+
+	field, err := s.repo.Create(ctx, req)
+	if err != nil {
+		return Field{}, fmt.Errorf("create field: %w", err)
+	}
+	s.logger.Info("custom field created", "id", field.ID, "key", field.Key)
+	return field, nil
+}
+
+func (s *service) GetAll(ctx context.Context, entity string, categoryID *int) ([]Field, error) {
+	fields, err := s.repo.GetAll(ctx, entity, categoryID)
+	if err != nil {
+		return nil, fmt.Errorf("get all fields: %w", err)
+	}
+	return fields, nil
+}
+
+func (s *service) Update(ctx context.Context, id int, req UpdateFieldRequest) (Field, error) {
+	field, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return Field{}, fmt.Errorf("get field by id: %w", err)
+	}
+
+	if req.Label != nil && strings.TrimSpace(*req.Label) == "" {
+		return Field{}, ErrInvalidLabel
+	}
+
+	if req.Options != nil && (field.Type != TypeEnum || len(req.Options) == 0) {
+		return Field{}, ErrInvalidOptions
+	}
+
+	if req.Label == nil && req.Required == nil && req.Options == nil {
+		return field, nil
+	}
+
+	updated, err := s.repo.Update(ctx, id, req)
+	if err != nil {
+		return Field{}, fmt.Errorf("update field: %w", err)
+	}
+	s.logger.Info("custom field updated", "id", updated.ID)
+	return updated, nil
+}
+
+func (s *service) Delete(ctx context.Context, id int) error {
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return fmt.Errorf("get field by id: %w", err)
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete field: %w", err)
+	}
+	s.logger.Info("custom field deleted", "id", id)
+	return nil
+}
+
+// Validate checks values against field definitions and returns them
+// normalized to their JSON representation. Unknown keys are rejected. When
+// partial is false every required field must be present.
+func Validate(fields []Field, values map[string]any, partial bool) (Values, error) {
+	byKey := make(map[string]Field, len(fields))
+	for _, f := range fields {
+		byKey[f.Key] = f
+	}
+
+	result := make(Values, len(values))
+	errs := make(ValidationError)
+
+	for key, value := range values {
+		field, ok := byKey[key]
+		if !ok {
+			errs[key] = "unknown field"
+			continue
+		}
+
+		if value == nil {
+			if field.Required {
+				errs[key] = "required"
+				continue
+			}
+			result[key] = nil
+			continue
+		}
+
+		normalized, reason := normalize(field, value)
+		if reason != "" {
+			errs[key] = reason
+			continue
+		}
+		result[key] = normalized
+	}
+
+	if !partial {
+		for _, f := range fields {
+			if _, ok := result[f.Key]; !ok && f.Required && errs[f.Key] == "" {
+				errs[f.Key] = "required"
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return result, nil
+}
+
+func normalize(field Field, value any) (any, string) {
+	switch field.Type {
+	case TypeText:
+		s, ok := value.(string)
+		if !ok {
+			return nil, "must be a string"
+		}
+		s = strings.TrimSpace(s)
+		if s == "" && field.Required {
+			return nil, "required"
+		}
+		return s, ""
+
+	case TypeNumber:
+		switch v := value.(type) {
+		case float64:
+			return v, ""
+		case int:
+			return float64(v), ""
+		case string:
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, "must be a number"
+			}
+			return n, ""
+		default:
+			return nil, "must be a number"
+		}
+
+	case TypeEnum:
+		s, ok := value.(string)
+		if !ok || !slices.Contains(field.Options, s) {
+			return nil, "must be one of " + strings.Join(field.Options, ", ")
+		}
+		return s, ""
+
+	case TypeDate:
+		s, ok := value.(string)
+		if !ok {
+			return nil, "must be a date in YYYY-MM-DD format"
+		}
+		d, err := time.Parse(time.DateOnly, strings.TrimSpace(s))
+		if err != nil {
+			return nil, "must be a date in YYYY-MM-DD format"
+		}
+		return d.Format(time.DateOnly), ""
+
+	case TypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, ""
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, "must be a boolean"
+			}
+			return b, ""
+		default:
+			return nil, "must be a boolean"
+		}
+
+	default:
+		return nil, "unsupported field type"
+	}
+}
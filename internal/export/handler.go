@@ -0,0 +1,123 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/AzizovHikmatullo/j-support/internal/tickets"
+	"github.com/gin-gonic/gin"
+)
+
+type Service interface {
+	Validate(format string, req *Request) error
+	Export(ctx context.Context, format string, req Request, w io.Writer) error
+}
+
+type handler struct {
+	service Service
+
+	logger *slog.Logger
+}
+
+func NewHandler(service Service, logger *slog.Logger) *handler {
+	return &handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+var contentTypes = map[string]string{
+	FormatCSV:    "text/csv; charset=utf-8",
+	FormatNDJSON: "application/x-ndjson",
+}
+
+// @Summary      Выгрузить тикеты
+// @Description  Только администратор. Тикеты отдаются потоком построчно в CSV или NDJSON
+// @Tags         export
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Security     Bearer
+// @Param        format       query  string    false  "Формат: csv (по умолчанию) или ndjson"
+// @Param        from         query  string    false  "Создан не раньше (RFC3339 или YYYY-MM-DD)"
+// @Param        to           query  string    false  "Создан раньше (RFC3339 или YYYY-MM-DD)"
+// @Param        status       query  string    false  "Статус тикета"
+// @Param        category_id  query  int       false  "ID категории"
+// @Param        tag          query  []string  false  "Только тикеты со всеми указанными тегами"  collectionFormat(multi)
+// @Param        field        query  object    false  "Фильтр по пользовательским полям: field[key]=value"
+// @Success      200  {array}   export.Row
+// @Failure      400  {object}  map[string]string
+// @Failure      401  {object}  map[string]string
+// @Router       /export/tickets [get]
+func (h *handler) Tickets(c *gin.Context) {
+	format := c.DefaultQuery("format", FormatCSV)
+
+	filter, err := tickets.FilterFromQuery(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req := Request{Filter: filter}
+
+	if from := c.Query("from"); from != "" {
+		t, err := parseTime(from)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+			return
+		}
+		req.From = &t
+	}
+
+	if to := c.Query("to"); to != "" {
+		t, err := parseTime(to)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+			return
+		}
+		req.To = &t
+	}
+
+	if err = h.service.Validate(format, &req); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	// Large exports outlive the server write timeout.
+	if err = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Warn("failed to reset write deadline", "error", err.Error())
+	}
+
+	filename := fmt.Sprintf("tickets-%s.%s", time.Now().Format("20060102-150405"), format)
+
+	c.Header("Content-Type", contentTypes[format])
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	if err = h.service.Export(c.Request.Context(), format, req, c.Writer); err != nil {
+		// Headers are already sent, so the client only sees a truncated file.
+		h.logger.Error("export error", "error", err.Error())
+	}
+}
+
+func (h *handler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidFormat), errors.Is(err, ErrInvalidRange), errors.Is(err, ErrInvalidStatus),
+		errors.Is(err, tickets.ErrInvalidTag), errors.Is(err, tickets.ErrInvalidPriority), errors.Is(err, tickets.ErrInvalidTeam):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		h.logger.Error("export error", "error", err.Error())
+	}
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
@@ -0,0 +1,52 @@
+package export
+
+import (
+	"errors"
+	"time"
+
+	"github.com/AzizovHikmatullo/j-support/internal/tickets"
+	"github.com/google/uuid"
+)
+
+// Row is one exported ticket. Status timestamps are taken from the activity
+// log: the first time the ticket became open and in_progress and the last
+// time it was closed.
+type Row struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	Status       string     `json:"status" db:"status"`
+	Source       string     `json:"source" db:"source"`
+	CategoryID   int        `json:"category_id" db:"category_id"`
+	CategoryName string     `json:"category_name" db:"category_name"`
+	ContactID    int        `json:"contact_id" db:"contact_id"`
+	ContactName  *string    `json:"contact_name" db:"contact_name"`
+	ContactPhone *string    `json:"contact_phone" db:"contact_phone"`
+	AssignedTo   *int       `json:"assigned_to" db:"assigned_id"`
+	MergedInto   *uuid.UUID `json:"merged_into" db:"merged_into"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+	OpenedAt     *time.Time `json:"opened_at" db:"opened_at"`
+	InProgressAt *time.Time `json:"in_progress_at" db:"in_progress_at"`
+	ClosedAt     *time.Time `json:"closed_at" db:"closed_at"`
+	Rating       *int       `json:"rating" db:"rating"`
+	RatingReason *string    `json:"rating_reason" db:"rating_reason"`
+	MessageCount int        `json:"message_count" db:"message_count"`
+}
+
+type Request struct {
+	Filter tickets.Filter
+	From   *time.Time
+	To     *time.Time
+}
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+
+	flushEvery = 100
+)
+
+var (
+	ErrInvalidFormat = errors.New("format must be one of csv, ndjson")
+	ErrInvalidRange  = errors.New("invalid date range")
+	ErrInvalidStatus = errors.New("invalid status")
+)
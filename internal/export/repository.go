@@ -0,0 +1,88 @@
+package export
+
+import (
+	"context"
+
+	"github.com/AzizovHikmatullo/j-support/internal/tickets"
+	"github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+type postgresRepo struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) Repository {
+	return &postgresRepo{
+		db: db,
+	}
+}
+
+func (r *postgresRepo) Stream(ctx context.Context, req Request, fn func(Row) error) error {
+	builder := squirrel.Select(
+		"tickets.id",
+		"tickets.status",
+		"tickets.source",
+		"tickets.category_id",
+		"c.name AS category_name",
+		"tickets.contact_id",
+		"ct.name AS contact_name",
+		"ct.phone AS contact_phone",
+		"tickets.assigned_id",
+		"tickets.merged_into",
+		"tickets.created_at",
+		"tickets.updated_at",
+		statusTime("min", "open")+" AS opened_at",
+		statusTime("min", "in_progress")+" AS in_progress_at",
+		statusTime("max", "closed")+" AS closed_at",
+		"r.score AS rating",
+		"r.reason AS rating_reason",
+		"(SELECT count(*) FROM messages m WHERE m.ticket_id = tickets.id) AS message_count",
+	).
+		PlaceholderFormat(squirrel.Dollar).
+		From("tickets").
+		Join("categories c ON c.id = tickets.category_id").
+		Join("contacts ct ON ct.id = tickets.contact_id").
+		LeftJoin("ticket_ratings r ON r.ticket_id = tickets.id").
+		OrderBy("tickets.created_at")
+
+	if req.From != nil {
+		builder = builder.Where(squirrel.GtOrEq{"tickets.created_at": *req.From})
+	}
+
+	if req.To != nil {
+		builder = builder.Where(squirrel.Lt{"tickets.created_at": *req.To})
+	}
+
+	query, args, err := tickets.ApplyFilter(builder, req.Filter).ToSql()
+	if err != nil {
+		return err
+	}
+
+	rows, err := r.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row Row
+		if err = rows.StructScan(&row); err != nil {
+			return err
+		}
+
+		if err = fn(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func statusTime(agg, status string) string {
+	return `(
+		SELECT ` + agg + `(al.created_at)
+		FROM activity_log al
+		WHERE al.ticket_id = tickets.id AND al.action = 'status_changed' AND al.payload ->> 'to' = '` + status + `'
+	)`
+}
@@ -0,0 +1,212 @@
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AzizovHikmatullo/j-support/internal/tickets"
+)
+
+type Repository interface {
+	Stream(ctx context.Context, req Request, fn func(Row) error) error
+}
+
+type service struct {
+	repo Repository
+
+	logger *slog.Logger
+}
+
+func NewService(repo Repository, logger *slog.Logger) Service {
+	return &service{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Validate checks the request and normalizes its filter in place, so an
+// invalid filter is rejected before any rows are streamed.
+func (s *service) Validate(format string, req *Request) error {
+	if format != FormatCSV && format != FormatNDJSON {
+		return ErrInvalidFormat
+	}
+
+	filter, err := tickets.NormalizeFilter(req.Filter)
+	if err != nil {
+		return err
+	}
+	req.Filter = filter
+
+	if req.Filter.Status != "" && !tickets.ValidStatus(req.Filter.Status) {
+		return ErrInvalidStatus
+	}
+
+	if req.From != nil && req.To != nil && !req.From.Before(*req.To) {
+		return ErrInvalidRange
+	}
+
+	return nil
+}
+
+func (s *service) Export(ctx context.Context, format string, req Request, w io.Writer) error {
+	if err := s.Validate(format, &req); err != nil {
+		return err
+	}
+
+	var enc encoder
+	if format == FormatCSV {
+		enc = newCSVEncoder(w)
+	} else {
+		enc = newJSONEncoder(w)
+	}
+
+	count := 0
+	err := s.repo.Stream(ctx, req, func(row Row) error {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+
+		count++
+		if count%flushEvery == 0 {
+			return enc.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("stream tickets: %w", err)
+	}
+
+	if err = enc.Flush(); err != nil {
+		return fmt.Errorf("flush export: %w", err)
+	}
+
+	s.logger.Info("tickets exported", "format", format, "rows", count)
+	return nil
+}
+
+type encoder interface {
+	Encode(row Row) error
+	Flush() error
+}
+
+var csvHeader = []string{
+	"id", "status", "source", "category_id", "category_name",
+	"contact_id", "contact_name", "contact_phone", "assigned_to", "merged_into",
+	"created_at", "updated_at", "opened_at", "in_progress_at", "closed_at",
+	"rating", "rating_reason", "message_count",
+}
+
+type csvEncoder struct {
+	out    io.Writer
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{out: w, w: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) Encode(row Row) error {
+	if !e.header {
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+		e.header = true
+	}
+
+	mergedInto := ""
+	if row.MergedInto != nil {
+		mergedInto = row.MergedInto.String()
+	}
+
+	return e.w.Write([]string{
+		row.ID.String(),
+		row.Status,
+		row.Source,
+		strconv.Itoa(row.CategoryID),
+		row.CategoryName,
+		strconv.Itoa(row.ContactID),
+		formatString(row.ContactName),
+		formatString(row.ContactPhone),
+		formatInt(row.AssignedTo),
+		mergedInto,
+		row.CreatedAt.Format(time.RFC3339),
+		row.UpdatedAt.Format(time.RFC3339),
+		formatTime(row.OpenedAt),
+		formatTime(row.InProgressAt),
+		formatTime(row.ClosedAt),
+		formatInt(row.Rating),
+		formatString(row.RatingReason),
+		strconv.Itoa(row.MessageCount),
+	})
+}
+
+func (e *csvEncoder) Flush() error {
+	// An empty export still gets the header row.
+	if !e.header {
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+		e.header = true
+	}
+
+	e.w.Flush()
+	if err := e.w.Error(); err != nil {
+		return err
+	}
+
+	return flush(e.out)
+}
+
+type jsonEncoder struct {
+	out io.Writer
+	enc *json.Encoder
+}
+
+func newJSONEncoder(w io.Writer) *jsonEncoder {
+	return &jsonEncoder{out: w, enc: json.NewEncoder(w)}
+}
+
+func (e *jsonEncoder) Encode(row Row) error {
+	return e.enc.Encode(row)
+}
+
+func (e *jsonEncoder) Flush() error {
+	return flush(e.out)
+}
+
+// flush pushes buffered data to the client when w is an HTTP response.
+func flush(w io.Writer) error {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+func formatString(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func formatInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func formatTime(v *time.Time) string {
+	if v == nil {
+		return ""
+	}
+	return v.Format(time.RFC3339)
+}
@@ -0,0 +1,182 @@
+package scenario
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/AzizovHikmatullo/j-support/internal/customfields"
+	"github.com/AzizovHikmatullo/j-support/internal/tags"
+	"github.com/AzizovHikmatullo/j-support/internal/tickets"
+	"github.com/google/uuid"
+)
+
+func validateAction(action *Action) error {
+	switch action.Type {
+	case actionSetPriority:
+		if !tickets.ValidPriority(action.Priority) {
+			return fmt.Errorf("%w: %s", ErrInvalidAction, tickets.ErrInvalidPriority.Error())
+		}
+	case actionAddTag:
+		name, ok := tags.NormalizeName(action.Tag)
+		if !ok {
+			return fmt.Errorf("%w: tag is required", ErrInvalidAction)
+		}
+		action.Tag = name
+	case actionTransfer:
+		if action.CategoryID <= 0 {
+			return fmt.Errorf("%w: category_id is required", ErrInvalidAction)
+		}
+	case actionAssignTeam:
+		team, ok := tickets.NormalizeTeam(action.Team)
+		if !ok || team == "" {
+			return fmt.Errorf("%w: team must be 1-64 characters", ErrInvalidAction)
+		}
+		action.Team = team
+	case actionSetFields:
+		if len(action.Fields) == 0 {
+			return fmt.Errorf("%w: fields are required", ErrInvalidAction)
+		}
+	case actionClose:
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidAction, action.Type)
+	}
+
+	return nil
+}
+
+// Field values containing {{variables}} are only checked for a known key, since
+// their value is known when the action runs.
+func (s *service) checkAction(ctx context.Context, definitions []customfields.Field, action *Action) error {
+	if err := validateAction(action); err != nil {
+		return err
+	}
+
+	switch action.Type {
+	case actionAddTag:
+		_, err := s.tagRepo.GetByName(ctx, action.Tag)
+		if errors.Is(err, tags.ErrTagNotFound) {
+			return fmt.Errorf("%w: tag %q not found", ErrInvalidAction, action.Tag)
+		}
+		if err != nil {
+			return fmt.Errorf("get tag by name: %w", err)
+		}
+	case actionTransfer:
+		category, err := s.categoryRepo.GetByID(ctx, action.CategoryID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: category %d not found", ErrInvalidAction, action.CategoryID)
+		}
+		if err != nil {
+			return fmt.Errorf("get category by id: %w", err)
+		}
+		if !category.Enabled {
+			return fmt.Errorf("%w: category %d is disabled", ErrInvalidAction, action.CategoryID)
+		}
+	case actionSetFields:
+		static := make(map[string]any, len(action.Fields))
+		known := make(map[string]bool, len(definitions))
+		for _, field := range definitions {
+			known[field.Key] = true
+		}
+
+		for key, value := range action.Fields {
+			if text, ok := value.(string); ok && placeholderPattern.MatchString(text) {
+				if !known[key] {
+					return fmt.Errorf("%w: unknown field %q", ErrInvalidAction, key)
+				}
+				continue
+			}
+			static[key] = value
+		}
+
+		validated, err := customfields.Validate(definitions, static, true)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidAction, err.Error())
+		}
+		for key, value := range validated {
+			action.Fields[key] = value
+		}
+	}
+
+	return nil
+}
+
+func (s *service) checkActions(ctx context.Context, categoryID int, actions Actions) error {
+	if len(actions) == 0 {
+		return nil
+	}
+
+	if len(actions) > maxActions {
+		return fmt.Errorf("%w: at most %d actions per step", ErrInvalidAction, maxActions)
+	}
+
+	definitions, err := s.fieldRepo.GetForCategory(ctx, categoryID)
+	if err != nil {
+		return fmt.Errorf("get custom fields: %w", err)
+	}
+
+	for i := range actions {
+		if err = s.checkAction(ctx, definitions, &actions[i]); err != nil {
+			return fmt.Errorf("actions[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// runActions reports whether the step closes the ticket; closing is left to the
+// caller, so the step question can be sent as the final answer first.
+func (s *service) runActions(ctx context.Context, ticketID uuid.UUID, step Step, vars Variables) bool {
+	var closes bool
+
+	for _, action := range step.Actions {
+		var err error
+
+		switch action.Type {
+		case actionSetPriority:
+			_, err = s.ticketService.ChangePriority(ctx, 0, "bot", ticketID, action.Priority)
+		case actionAddTag:
+			var tag tags.Tag
+			tag, err = s.tagRepo.GetByName(ctx, action.Tag)
+			if err == nil {
+				_, err = s.ticketService.AddTag(ctx, 0, "bot", ticketID, tag.ID)
+			}
+		case actionTransfer:
+			_, err = s.ticketService.ChangeCategory(ctx, 0, "bot", ticketID, action.CategoryID)
+		case actionAssignTeam:
+			_, err = s.ticketService.ChangeTeam(ctx, 0, "bot", ticketID, action.Team)
+		case actionSetFields:
+			_, err = s.ticketService.UpdateFields(ctx, 0, "bot", ticketID, interpolateFields(action.Fields, vars))
+		case actionClose:
+			closes = true
+		}
+
+		if err != nil {
+			s.logger.Error("failed to run step action", "ticket id", ticketID.String(), "step id", step.ID, "action", action.Type, "error", err.Error())
+		}
+	}
+
+	return closes
+}
+
+func (a Actions) closes() bool {
+	for _, action := range a {
+		if action.Type == actionClose {
+			return true
+		}
+	}
+	return false
+}
+
+func interpolateFields(values customfields.Values, vars Variables) map[string]any {
+	result := make(map[string]any, len(values))
+	for key, value := range values {
+		if text, ok := value.(string); ok && strings.Contains(text, "{{") {
+			value = interpolate(text, vars)
+		}
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (s *service) Analytics(ctx context.Context, scenarioID int, req AnalyticsRequest) (Funnel, error) {
+	if !req.From.Before(req.To) {
+		return Funnel{}, ErrInvalidRange
+	}
+
+	if _, err := s.repo.GetByID(ctx, scenarioID); err != nil {
+		return Funnel{}, fmt.Errorf("get scenario by id: %w", err)
+	}
+
+	summary, err := s.repo.GetFunnelSummary(ctx, scenarioID, req)
+	if err != nil {
+		return Funnel{}, fmt.Errorf("get funnel summary: %w", err)
+	}
+
+	steps, err := s.repo.GetStepStats(ctx, scenarioID, req)
+	if err != nil {
+		return Funnel{}, fmt.Errorf("get step stats: %w", err)
+	}
+
+	branches, err := s.repo.GetBranchStats(ctx, scenarioID, req)
+	if err != nil {
+		return Funnel{}, fmt.Errorf("get branch stats: %w", err)
+	}
+
+	return Funnel{
+		ScenarioID:    scenarioID,
+		From:          req.From,
+		To:            req.To,
+		Channel:       req.Channel,
+		FunnelSummary: summary,
+		Steps:         steps,
+		Branches:      branches,
+	}, nil
+}
+
+func (s *service) recordTransitions(ctx context.Context, session Session, transitions []Transition) {
+	for i := range transitions {
+		transitions[i].TicketID = session.TicketID
+		transitions[i].ScenarioID = session.ScenarioID
+		transitions[i].VersionID = session.VersionID
+	}
+
+	if err := s.repo.RecordTransitions(ctx, transitions); err != nil {
+		s.logger.Error("failed to record bot transitions", "ticket id", session.TicketID.String(), "error", err.Error())
+	}
+}
+
+// moves returns the transitions the bot made on its own after entering the first
+// step of the resolution, including the end of the session.
+func (g *graph) moves(res resolution) []Transition {
+	for i, step := range res.path {
+		if step.Actions.closes() {
+			return append(hops(res.path[:i+1]), Transition{Event: EventResolved, FromStepID: &step.ID})
+		}
+	}
+
+	transitions := hops(res.path)
+
+	switch {
+	case res.limited:
+		transitions = append(transitions, handoff(res.step.ID, openLoopLimit, 0))
+	case res.failed:
+		transitions = append(transitions, handoff(res.step.ID, openWebhook, 0))
+	case g.isLeaf(res.step):
+		transitions = append(transitions, handoff(res.step.ID, openLeaf, 0))
+	}
+
+	return transitions
+}
+
+func hops(path []*Step) []Transition {
+	var transitions []Transition
+	for i := 1; i < len(path); i++ {
+		transitions = append(transitions, Transition{Event: EventGoto, FromStepID: &path[i-1].ID, ToStepID: &path[i].ID})
+	}
+	return transitions
+}
+
+func handoff(stepID int, reason string, duration int64) Transition {
+	return Transition{Event: EventHandoff, FromStepID: &stepID, Reason: &reason, DurationMs: duration}
+}
+
+func waited(session Session) int64 {
+	if session.StepEnteredAt.IsZero() {
+		return 0
+	}
+	return time.Since(session.StepEnteredAt).Milliseconds()
+}
@@ -0,0 +1,143 @@
+package scenario
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+func defaultCommands() Commands {
+	return Commands{
+		Operator: Command{
+			Keywords: []string{"оператор", "позвать оператора", "operator", "человек"},
+			Button:   "Позвать оператора",
+			Reply:    "Соединяем вас с оператором, пожалуйста, подождите",
+		},
+		Back: Command{
+			Keywords: []string{"назад", "back"},
+			Button:   "Назад",
+		},
+		Restart: Command{
+			Keywords: []string{"в начало", "заново", "restart"},
+			Button:   "В начало",
+		},
+	}
+}
+
+func scenarioCommands(scenario Scenario) Commands {
+	if scenario.Commands == nil {
+		return defaultCommands()
+	}
+	return *scenario.Commands
+}
+
+type namedCommand struct {
+	name    string
+	command Command
+}
+
+func (c Commands) named() []namedCommand {
+	return []namedCommand{
+		{commandOperator, c.Operator},
+		{commandBack, c.Back},
+		{commandRestart, c.Restart},
+	}
+}
+
+func (c Commands) detect(answer string) string {
+	answer = normalizeCommand(answer)
+	if answer == "" {
+		return ""
+	}
+
+	for _, nc := range c.named() {
+		if nc.command.Disabled {
+			continue
+		}
+		if nc.command.Button != "" && normalizeCommand(nc.command.Button) == answer {
+			return nc.name
+		}
+		for _, keyword := range nc.command.Keywords {
+			if keyword == answer {
+				return nc.name
+			}
+		}
+	}
+
+	return ""
+}
+
+// Back and restart are only offered once the customer has left the root step.
+func (c Commands) buttons(hasHistory bool) []string {
+	var buttons []string
+	for _, nc := range c.named() {
+		if nc.command.Disabled || nc.command.Button == "" {
+			continue
+		}
+		if nc.name != commandOperator && !hasHistory {
+			continue
+		}
+		buttons = append(buttons, nc.command.Button)
+	}
+	return buttons
+}
+
+func normalizeCommands(c Commands) (Commands, error) {
+	seen := make(map[string]string)
+
+	normalize := func(name string, command *Command) error {
+		command.Button = strings.TrimSpace(command.Button)
+		command.Reply = strings.TrimSpace(command.Reply)
+
+		if utf8.RuneCountInString(command.Button) > maxCommandLength {
+			return fmt.Errorf("%w: %s button is too long", ErrInvalidCommands, name)
+		}
+		if len(command.Keywords) > maxCommandKeywords {
+			return fmt.Errorf("%w: %s has more than %d keywords", ErrInvalidCommands, name, maxCommandKeywords)
+		}
+		if !command.Disabled && len(command.Keywords) == 0 && command.Button == "" {
+			return fmt.Errorf("%w: %s needs a keyword or a button", ErrInvalidCommands, name)
+		}
+
+		keywords := make([]string, 0, len(command.Keywords))
+		triggers := make([]string, 0, len(command.Keywords)+1)
+
+		for _, keyword := range command.Keywords {
+			keyword = normalizeCommand(keyword)
+			if keyword == "" || utf8.RuneCountInString(keyword) > maxCommandLength {
+				return fmt.Errorf("%w: %s keywords must be 1-%d characters", ErrInvalidCommands, name, maxCommandLength)
+			}
+			keywords = append(keywords, keyword)
+			triggers = append(triggers, keyword)
+		}
+		if command.Button != "" {
+			triggers = append(triggers, normalizeCommand(command.Button))
+		}
+
+		for _, trigger := range triggers {
+			if other, ok := seen[trigger]; ok && other != name {
+				return fmt.Errorf("%w: %q is used by %s and %s", ErrInvalidCommands, trigger, other, name)
+			}
+			seen[trigger] = name
+		}
+
+		command.Keywords = keywords
+		return nil
+	}
+
+	if err := normalize(commandOperator, &c.Operator); err != nil {
+		return c, err
+	}
+	if err := normalize(commandBack, &c.Back); err != nil {
+		return c, err
+	}
+	if err := normalize(commandRestart, &c.Restart); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+func normalizeCommand(value string) string {
+	return strings.ToLower(strings.Join(strings.Fields(value), " "))
+}
@@ -0,0 +1,329 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxDiagramQuestion = 60
+
+const (
+	nodeStep = iota
+	nodeRoot
+	nodeSubflow
+	nodeWebhook
+	nodeEnd
+)
+
+const (
+	edgeAnswer = iota
+	edgeDefault
+	edgeGoto
+	edgeEnd
+)
+
+const (
+	handoffNode = "handoff"
+	closedNode  = "closed"
+)
+
+type diagramNode struct {
+	id    string
+	lines []string
+	kind  int
+}
+
+type diagramEdge struct {
+	from  string
+	to    string
+	label string
+	kind  int
+}
+
+type diagram struct {
+	name  string
+	nodes []diagramNode
+	edges []diagramEdge
+}
+
+// Diagram renders the published version, the draft or a specific version of
+// the scenario as a Mermaid flowchart or a Graphviz DOT graph.
+func (s *service) Diagram(ctx context.Context, scenarioID int, version, format string) (string, error) {
+	scenario, err := s.repo.GetByID(ctx, scenarioID)
+	if err != nil {
+		return "", fmt.Errorf("get scenario by id: %w", err)
+	}
+
+	versionID, err := s.resolveVersion(ctx, scenario, version)
+	if err != nil {
+		return "", err
+	}
+
+	steps, err := s.repo.GetAllSteps(ctx, versionID)
+	if err != nil {
+		return "", fmt.Errorf("get steps: %w", err)
+	}
+
+	d := buildDiagram(scenario, steps)
+
+	switch format {
+	case FormatMermaid:
+		return d.mermaid(), nil
+	case FormatDOT:
+		return d.dot(), nil
+	default:
+		return "", ErrInvalidDiagramFormat
+	}
+}
+
+// buildDiagram collects the nodes and edges of a version. A step the bot is done
+// at leads to the operator handoff, or to the closed ticket when a close action
+// ends the conversation.
+func buildDiagram(scenario Scenario, steps []Step) diagram {
+	sorted := append([]Step(nil), steps...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	g := newGraph(sorted)
+	d := diagram{name: fmt.Sprintf("scenario_%d", scenario.ID)}
+
+	var handoff, closed bool
+
+	for i := range sorted {
+		step := &sorted[i]
+		id := stepNodeID(step.ID)
+
+		d.nodes = append(d.nodes, diagramNode{id: id, lines: stepLines(step), kind: stepKind(g, step)})
+
+		if closesTicket(step) {
+			d.edges = append(d.edges, diagramEdge{from: id, to: closedNode, label: actionClose, kind: edgeEnd})
+			closed = true
+			continue
+		}
+
+		defaults := 0
+		for _, child := range g.children[step.ID] {
+			edge := diagramEdge{from: id, to: stepNodeID(child.ID), label: conditionLabel(child), kind: edgeAnswer}
+			if child.Condition == nil {
+				edge.kind = edgeDefault
+				defaults++
+			}
+			d.edges = append(d.edges, edge)
+		}
+
+		if target := g.target(step); target != nil {
+			d.edges = append(d.edges, diagramEdge{from: id, to: stepNodeID(target.ID), label: step.GotoMode, kind: edgeGoto})
+		}
+
+		// The leaf of a sub-flow returns to the step that called it, which is
+		// already drawn as the default child of the call step.
+		if g.isLeaf(step) && g.steps[g.flowRoot(step.ID)].Subflow {
+			continue
+		}
+
+		if g.isLeaf(step) {
+			d.edges = append(d.edges, diagramEdge{from: id, to: handoffNode, label: openLeaf, kind: edgeEnd})
+			handoff = true
+			continue
+		}
+
+		// A webhook step without a default hands the ticket over when no
+		// child matches the call result.
+		if step.Webhook != nil && defaults == 0 {
+			for _, outcome := range []string{webhookSuccess, webhookFailure} {
+				if findNext(g.children[step.ID], outcome).step == nil {
+					d.edges = append(d.edges, diagramEdge{from: id, to: handoffNode, label: outcome, kind: edgeEnd})
+					handoff = true
+				}
+			}
+		}
+	}
+
+	if handoff {
+		d.nodes = append(d.nodes, diagramNode{id: handoffNode, lines: []string{"operator"}, kind: nodeEnd})
+	}
+	if closed {
+		d.nodes = append(d.nodes, diagramNode{id: closedNode, lines: []string{"closed"}, kind: nodeEnd})
+	}
+
+	return d
+}
+
+func stepNodeID(id int) string {
+	return fmt.Sprintf("s%d", id)
+}
+
+func stepKind(g *graph, step *Step) int {
+	switch {
+	case g.root != nil && step.ID == g.root.ID:
+		return nodeRoot
+	case step.Webhook != nil:
+		return nodeWebhook
+	case step.Subflow && step.ParentID == nil:
+		return nodeSubflow
+	default:
+		return nodeStep
+	}
+}
+
+func stepLines(step *Step) []string {
+	name := fmt.Sprintf("#%d", step.ID)
+	if step.Key != nil {
+		name = *step.Key
+	}
+
+	lines := []string{name}
+
+	if question, _, _ := strings.Cut(strings.TrimSpace(step.Question), "\n"); question != "" {
+		lines = append(lines, truncate(question, maxDiagramQuestion))
+	}
+
+	if step.Webhook != nil {
+		lines = append(lines, step.Webhook.Method+" "+step.Webhook.URL)
+	}
+
+	if step.Variable != nil {
+		lines = append(lines, "{{"+*step.Variable+"}}")
+	}
+
+	return lines
+}
+
+func conditionLabel(step Step) string {
+	if step.Condition == nil {
+		return "default"
+	}
+
+	label := *step.Condition
+	if step.MatchMode != "" && step.MatchMode != matchContains {
+		label = step.MatchMode + ": " + label
+	}
+
+	return truncate(label, maxDiagramQuestion)
+}
+
+func closesTicket(step *Step) bool {
+	for _, action := range step.Actions {
+		if action.Type == actionClose {
+			return true
+		}
+	}
+	return false
+}
+
+func truncate(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	return string([]rune(s)[:limit-1]) + "…"
+}
+
+func (d diagram) mermaid() string {
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+
+	for _, node := range d.nodes {
+		lines := make([]string, len(node.lines))
+		for i, line := range node.lines {
+			lines[i] = mermaidEscape(line)
+		}
+		label := `"` + strings.Join(lines, "<br/>") + `"`
+
+		switch node.kind {
+		case nodeRoot:
+			fmt.Fprintf(&b, "    %s([%s])\n", node.id, label)
+		case nodeSubflow:
+			fmt.Fprintf(&b, "    %s[[%s]]\n", node.id, label)
+		case nodeWebhook:
+			fmt.Fprintf(&b, "    %s{{%s}}\n", node.id, label)
+		case nodeEnd:
+			fmt.Fprintf(&b, "    %s((%s))\n", node.id, label)
+		default:
+			fmt.Fprintf(&b, "    %s[%s]\n", node.id, label)
+		}
+	}
+
+	for _, edge := range d.edges {
+		arrow := "-->"
+		switch edge.kind {
+		case edgeDefault, edgeEnd:
+			arrow = "-.->"
+		case edgeGoto:
+			arrow = "==>"
+		}
+
+		fmt.Fprintf(&b, "    %s %s|\"%s\"| %s\n", edge.from, arrow, mermaidEscape(edge.label), edge.to)
+	}
+
+	return b.String()
+}
+
+var mermaidReplacer = strings.NewReplacer(
+	"#", "#35;",
+	`"`, "#quot;",
+	"<", "#lt;",
+	">", "#gt;",
+	"\r", " ",
+	"\n", " ",
+)
+
+func mermaidEscape(s string) string {
+	return mermaidReplacer.Replace(s)
+}
+
+func (d diagram) dot() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", d.name)
+	b.WriteString("    rankdir=TB;\n")
+	b.WriteString("    node [shape=box, fontname=\"Helvetica\"];\n")
+	b.WriteString("    edge [fontname=\"Helvetica\"];\n")
+
+	for _, node := range d.nodes {
+		lines := make([]string, len(node.lines))
+		for i, line := range node.lines {
+			lines[i] = dotEscape(line)
+		}
+
+		attrs := ""
+		switch node.kind {
+		case nodeRoot:
+			attrs = ", style=\"rounded,bold\""
+		case nodeSubflow:
+			attrs = ", peripheries=2"
+		case nodeWebhook:
+			attrs = ", shape=hexagon"
+		case nodeEnd:
+			attrs = ", shape=doublecircle"
+		}
+
+		fmt.Fprintf(&b, "    %s [label=\"%s\"%s];\n", node.id, strings.Join(lines, `\n`), attrs)
+	}
+
+	for _, edge := range d.edges {
+		attrs := ""
+		switch edge.kind {
+		case edgeDefault, edgeEnd:
+			attrs = ", style=dashed"
+		case edgeGoto:
+			attrs = ", style=bold"
+		}
+
+		fmt.Fprintf(&b, "    %s -> %s [label=\"%s\"%s];\n", edge.from, edge.to, dotEscape(edge.label), attrs)
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+var dotReplacer = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\r", " ",
+	"\n", " ",
+)
+
+func dotEscape(s string) string {
+	return dotReplacer.Replace(s)
+}
@@ -0,0 +1,419 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/AzizovHikmatullo/j-support/internal/customfields"
+	"github.com/goccy/go-yaml"
+)
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+func validKey(key string) bool {
+	return len(key) <= maxKeyLength && keyPattern.MatchString(key)
+}
+
+// EncodeDocument renders a document as indented JSON or YAML.
+func EncodeDocument(doc Document, format string) ([]byte, error) {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatJSON:
+		return data, nil
+	case FormatYAML:
+		return yaml.JSONToYAML(data)
+	default:
+		return nil, ErrInvalidFormat
+	}
+}
+
+// DecodeDocument parses a JSON or YAML document. Unknown fields are rejected,
+// so a typo in a key does not silently drop part of the tree.
+func DecodeDocument(data []byte, format string) (Document, error) {
+	var doc Document
+
+	switch format {
+	case FormatJSON:
+	case FormatYAML:
+		converted, err := yaml.YAMLToJSON(data)
+		if err != nil {
+			return doc, fmt.Errorf("%w: %s", ErrInvalidDocument, err.Error())
+		}
+		data = converted
+	default:
+		return doc, ErrInvalidFormat
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&doc); err != nil {
+		return doc, fmt.Errorf("%w: %s", ErrInvalidDocument, err.Error())
+	}
+
+	return doc, nil
+}
+
+// buildDocument converts the steps of one version into a document. Steps
+// without a stored key get one derived from their ID.
+func buildDocument(categoryID int, steps []Step) Document {
+	doc := Document{CategoryID: categoryID}
+
+	used := make(map[string]bool, len(steps))
+	for _, step := range steps {
+		if step.Key != nil {
+			used[*step.Key] = true
+		}
+	}
+
+	keys := make(map[int]string, len(steps))
+	for _, step := range steps {
+		if step.Key != nil {
+			keys[step.ID] = *step.Key
+			continue
+		}
+
+		key := "step_" + strconv.Itoa(step.ID)
+		for i := 2; used[key]; i++ {
+			key = "step_" + strconv.Itoa(step.ID) + "_" + strconv.Itoa(i)
+		}
+		used[key] = true
+		keys[step.ID] = key
+	}
+
+	var convert func(node StepNode) *DocumentStep
+	convert = func(node StepNode) *DocumentStep {
+		docStep := &DocumentStep{
+			Key:              keys[node.ID],
+			Condition:        node.Condition,
+			MatchThreshold:   node.MatchThreshold,
+			ButtonLabel:      node.ButtonLabel,
+			Question:         node.Question,
+			SetFields:        node.SetFields,
+			Variable:         node.Variable,
+			Validator:        node.Validator,
+			ValidatorPattern: node.ValidatorPattern,
+			ErrorMessage:     node.ErrorMessage,
+			Actions:          node.Actions,
+			Timeout:          node.Timeout,
+			Translations:     node.Translations,
+			Webhook:          node.Webhook,
+		}
+		if node.MatchMode != matchContains {
+			docStep.MatchMode = node.MatchMode
+		}
+		if node.GotoStepID != nil {
+			docStep.Goto = keys[*node.GotoStepID]
+			if node.GotoMode != gotoJump {
+				docStep.GotoMode = node.GotoMode
+			}
+		}
+		for _, child := range node.Children {
+			docStep.Children = append(docStep.Children, convert(*child))
+		}
+		return docStep
+	}
+
+	for _, root := range buildTree(steps) {
+		if root.Subflow || doc.Root != nil {
+			doc.Subflows = append(doc.Subflows, convert(root))
+			continue
+		}
+		doc.Root = convert(root)
+	}
+
+	return doc
+}
+
+func walkDocument(doc Document, fn func(step *DocumentStep)) {
+	var walk func(step *DocumentStep)
+	walk = func(step *DocumentStep) {
+		if step == nil {
+			return
+		}
+		fn(step)
+		for _, child := range step.Children {
+			walk(child)
+		}
+	}
+
+	walk(doc.Root)
+	for _, subflow := range doc.Subflows {
+		walk(subflow)
+	}
+}
+
+type gotoRef struct {
+	path string
+	step *DocumentStep
+}
+
+// validateDocument returns every issue found instead of stopping at the first
+// one. Field values, actions and webhooks are normalized in place.
+func validateDocument(doc Document, fields []customfields.Field, checkAction func(*Action) error, checkWebhook func(Webhook) (Webhook, error)) DocumentError {
+	issues := DocumentError{}
+
+	if doc.Root == nil {
+		return append(issues, DocumentIssue{Path: "root", Message: "is required"})
+	}
+
+	keys := make(map[string]string)
+	var gotos, timeouts []gotoRef
+
+	var walk func(step *DocumentStep, path string)
+	walk = func(step *DocumentStep, path string) {
+		if step == nil {
+			issues = append(issues, DocumentIssue{Path: path, Message: "step is empty"})
+			return
+		}
+
+		switch {
+		case step.Key == "":
+			issues = append(issues, DocumentIssue{Path: path + ".key", Message: "is required"})
+		case !validKey(step.Key):
+			issues = append(issues, DocumentIssue{Path: path + ".key", Message: ErrInvalidKey.Error()})
+		default:
+			if other, ok := keys[step.Key]; ok {
+				issues = append(issues, DocumentIssue{Path: path + ".key", Message: fmt.Sprintf("duplicate key %q, already used at %s", step.Key, other)})
+			} else {
+				keys[step.Key] = path
+			}
+		}
+
+		if strings.TrimSpace(step.Question) == "" && step.Goto == "" && step.Webhook == nil {
+			issues = append(issues, DocumentIssue{Path: path + ".question", Message: "is required"})
+		}
+
+		if step.Goto != "" {
+			if step.GotoMode == "" {
+				step.GotoMode = gotoJump
+			}
+			gotos = append(gotos, gotoRef{path: path, step: step})
+		}
+		if err := validateDocumentGoto(step); err != nil {
+			issues = append(issues, DocumentIssue{Path: path + ".goto", Message: err.Error()})
+		}
+
+		if step.Timeout != nil {
+			timeout, err := normalizeTimeout(*step.Timeout)
+			if err != nil {
+				issues = append(issues, DocumentIssue{Path: path + ".timeout", Message: err.Error()})
+			} else {
+				step.Timeout = &timeout
+				if timeout.Outcome == timeoutGoto {
+					timeouts = append(timeouts, gotoRef{path: path, step: step})
+				}
+			}
+		}
+
+		if step.MatchMode == "" {
+			step.MatchMode = matchContains
+		}
+		if err := validateCondition(step.MatchMode, step.Condition); err != nil {
+			issues = append(issues, DocumentIssue{Path: path + ".condition", Message: err.Error()})
+		}
+		if err := validateThreshold(step.MatchThreshold); err != nil {
+			issues = append(issues, DocumentIssue{Path: path + ".match_threshold", Message: err.Error()})
+		}
+		step.Translations = normalizeTranslations(step.Translations)
+		if err := validateTranslations(step.Translations, step.MatchMode, step.Condition); err != nil {
+			issues = append(issues, DocumentIssue{Path: path + ".translations", Message: err.Error()})
+		}
+		step.Variable = nonEmpty(step.Variable)
+		step.Validator = nonEmpty(step.Validator)
+		step.ValidatorPattern = nonEmpty(step.ValidatorPattern)
+		step.ErrorMessage = nonEmpty(step.ErrorMessage)
+		if err := validateCapture(step.Variable, step.Validator, step.ValidatorPattern); err != nil {
+			issues = append(issues, DocumentIssue{Path: path + ".variable", Message: err.Error()})
+		}
+
+		if step.Webhook != nil {
+			webhook, err := checkWebhook(*step.Webhook)
+			if err != nil {
+				issues = append(issues, DocumentIssue{Path: path + ".webhook", Message: err.Error()})
+			} else {
+				step.Webhook = &webhook
+			}
+
+			if step.Goto != "" || step.Variable != nil {
+				issues = append(issues, DocumentIssue{Path: path + ".webhook", Message: "webhook step cannot have goto or variable"})
+			}
+		}
+
+		if len(step.Actions) > maxActions {
+			issues = append(issues, DocumentIssue{Path: path + ".actions", Message: fmt.Sprintf("at most %d actions per step", maxActions)})
+		}
+		for i := range step.Actions {
+			if err := checkAction(&step.Actions[i]); err != nil {
+				issues = append(issues, DocumentIssue{Path: fmt.Sprintf("%s.actions[%d]", path, i), Message: err.Error()})
+			}
+		}
+
+		if len(step.SetFields) > 0 {
+			values, err := customfields.Validate(fields, step.SetFields, true)
+
+			var fieldsErr customfields.ValidationError
+			if errors.As(err, &fieldsErr) {
+				for _, key := range sortedKeys(fieldsErr) {
+					issues = append(issues, DocumentIssue{Path: path + ".set_fields." + key, Message: fieldsErr[key]})
+				}
+			} else if err == nil {
+				step.SetFields = values
+			}
+		}
+
+		defaults := 0
+		conditions := make(map[string]bool, len(step.Children))
+
+		for i, child := range step.Children {
+			childPath := fmt.Sprintf("%s.children[%d]", path, i)
+
+			if child != nil {
+				if child.Condition == nil {
+					defaults++
+					if defaults > 1 {
+						issues = append(issues, DocumentIssue{Path: childPath, Message: ErrDefaultAlreadyExists.Error()})
+					}
+				} else {
+					condition := strings.ToLower(strings.TrimSpace(*child.Condition))
+					if conditions[condition] {
+						issues = append(issues, DocumentIssue{Path: childPath + ".condition", Message: fmt.Sprintf("duplicate condition %q", *child.Condition)})
+					}
+					conditions[condition] = true
+
+					if step.Webhook != nil && condition != webhookSuccess && condition != webhookFailure {
+						issues = append(issues, DocumentIssue{Path: childPath + ".condition", Message: fmt.Sprintf("condition of a webhook step child must be %s or %s", webhookSuccess, webhookFailure)})
+					}
+				}
+			}
+
+			walk(child, childPath)
+		}
+	}
+
+	walk(doc.Root, "root")
+	for i, subflow := range doc.Subflows {
+		walk(subflow, fmt.Sprintf("subflows[%d]", i))
+	}
+
+	for _, ref := range gotos {
+		if ref.step.Goto == ref.step.Key {
+			issues = append(issues, DocumentIssue{Path: ref.path + ".goto", Message: "step cannot jump to itself"})
+		} else if _, ok := keys[ref.step.Goto]; !ok {
+			issues = append(issues, DocumentIssue{Path: ref.path + ".goto", Message: fmt.Sprintf("unknown step %q", ref.step.Goto)})
+		}
+	}
+
+	for _, ref := range timeouts {
+		if _, ok := keys[ref.step.Timeout.Goto]; !ok {
+			issues = append(issues, DocumentIssue{Path: ref.path + ".timeout.goto", Message: fmt.Sprintf("unknown step %q", ref.step.Timeout.Goto)})
+		}
+	}
+
+	for _, key := range unusedSubflows(doc) {
+		issues = append(issues, DocumentIssue{Path: keys[key], Message: "sub-flow is unreachable from the root"})
+	}
+
+	if len(issues) == 0 {
+		return nil
+	}
+
+	return issues
+}
+
+func validateDocumentGoto(step *DocumentStep) error {
+	if step.Goto == "" {
+		if step.GotoMode != "" {
+			return fmt.Errorf("%w: goto_mode requires goto", ErrInvalidGoto)
+		}
+		return nil
+	}
+
+	switch step.GotoMode {
+	case gotoJump:
+		if len(step.Children) > 0 {
+			return fmt.Errorf("%w: jump step cannot have children", ErrInvalidGoto)
+		}
+	case gotoCall:
+		if len(step.Children) > 1 || (len(step.Children) == 1 && step.Children[0] != nil && step.Children[0].Condition != nil) {
+			return fmt.Errorf("%w: call step can only have a default child", ErrInvalidGoto)
+		}
+	default:
+		return fmt.Errorf("%w: goto_mode must be one of jump, call", ErrInvalidGoto)
+	}
+
+	if step.Variable != nil {
+		return fmt.Errorf("%w: goto step cannot capture a variable", ErrInvalidGoto)
+	}
+
+	return nil
+}
+
+func unusedSubflows(doc Document) []string {
+	owner := make(map[string]int)
+	var collect func(step *DocumentStep, flow int)
+	collect = func(step *DocumentStep, flow int) {
+		if step == nil {
+			return
+		}
+		owner[step.Key] = flow
+		for _, child := range step.Children {
+			collect(child, flow)
+		}
+	}
+
+	collect(doc.Root, -1)
+	for i, subflow := range doc.Subflows {
+		collect(subflow, i)
+	}
+
+	used := make(map[int]bool, len(doc.Subflows))
+	queue := []int{-1}
+
+	for len(queue) > 0 {
+		flow := queue[0]
+		queue = queue[1:]
+
+		var root *DocumentStep
+		if flow < 0 {
+			root = doc.Root
+		} else {
+			root = doc.Subflows[flow]
+		}
+
+		walkDocument(Document{Root: root}, func(step *DocumentStep) {
+			if target, ok := owner[step.Goto]; ok && step.Goto != "" && target >= 0 && !used[target] {
+				used[target] = true
+				queue = append(queue, target)
+			}
+		})
+	}
+
+	var unused []string
+	for i, subflow := range doc.Subflows {
+		if subflow != nil && !used[i] {
+			unused = append(unused, subflow.Key)
+		}
+	}
+
+	return unused
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
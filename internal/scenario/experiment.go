@@ -0,0 +1,84 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/AzizovHikmatullo/j-support/internal/activity_log"
+	"github.com/AzizovHikmatullo/j-support/internal/tickets"
+)
+
+const maxWeight = 1000
+
+// pickVariant picks among the matched variants with the priority of the top
+// match. The choice depends only on the ticket ID, so a ticket always lands in
+// the same variant while the weights stay the same.
+func (s *service) pickVariant(ctx context.Context, ticket tickets.Ticket, matched []Scenario) Scenario {
+	name := *matched[0].Experiment
+	priority := matched[0].Priority
+
+	var variants []Scenario
+	total := 0
+	for _, scenario := range matched {
+		if scenario.Priority == priority && scenario.Experiment != nil && *scenario.Experiment == name {
+			variants = append(variants, scenario)
+			total += scenario.Weight
+		}
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write(ticket.ID[:])
+	bucket := int(h.Sum32() % uint32(total))
+
+	chosen := variants[len(variants)-1]
+	for _, variant := range variants {
+		if bucket < variant.Weight {
+			chosen = variant
+			break
+		}
+		bucket -= variant.Weight
+	}
+
+	s.activityLog.Log(ctx, activity_log.LogEntry{
+		TicketID:  ticket.ID,
+		ActorID:   0,
+		ActorType: "bot",
+		Action:    activity_log.ActionBotVariant,
+		Payload:   activity_log.Payload{"experiment": name, "scenario_id": chosen.ID, "variants": len(variants)},
+	})
+
+	return chosen
+}
+
+func (s *service) Experiment(ctx context.Context, categoryID int, name string, req AnalyticsRequest) (Experiment, error) {
+	if !req.From.Before(req.To) {
+		return Experiment{}, ErrInvalidRange
+	}
+
+	variants, err := s.repo.GetExperimentStats(ctx, categoryID, name, req)
+	if err != nil {
+		return Experiment{}, fmt.Errorf("get experiment stats: %w", err)
+	}
+
+	if len(variants) == 0 {
+		return Experiment{}, ErrExperimentNotFound
+	}
+
+	for i := range variants {
+		v := &variants[i]
+		if v.Sessions > 0 {
+			v.HandoffRate = float64(v.HandedOff) / float64(v.Sessions)
+			v.SelfResolutionRate = float64(v.SelfResolved) / float64(v.Sessions)
+		}
+	}
+
+	return Experiment{
+		CategoryID: categoryID,
+		Name:       name,
+		From:       req.From,
+		To:         req.To,
+		Channel:    req.Channel,
+		Variants:   variants,
+	}, nil
+}
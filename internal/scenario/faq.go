@@ -0,0 +1,344 @@
+package scenario
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/AzizovHikmatullo/j-support/internal/activity_log"
+	"github.com/AzizovHikmatullo/j-support/internal/contacts"
+	"github.com/google/uuid"
+)
+
+const (
+	faqAnswered = "answered"
+	faqHelped   = "helped"
+	faqOperator = "operator"
+)
+
+// Indexes of the feedback buttons in faqButtons.
+const (
+	faqButtonHelped = iota
+	faqButtonOperator
+)
+
+func checkFAQRule(rule FAQRule) error {
+	if err := validateCondition(rule.MatchMode, &rule.Condition); err != nil {
+		return err
+	}
+
+	if err := validateThreshold(rule.MatchThreshold); err != nil {
+		return err
+	}
+
+	if rule.Answer == "" {
+		return fmt.Errorf("%w: answer is required", ErrInvalidFAQRule)
+	}
+
+	for locale, t := range rule.Translations {
+		if err := checkLocale(locale); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidTranslation, err.Error())
+		}
+
+		if t.Condition == "" {
+			continue
+		}
+
+		if err := validateCondition(rule.MatchMode, &t.Condition); err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidTranslation, locale, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func normalizeFAQTranslations(translations FAQTranslations) FAQTranslations {
+	normalized := make(FAQTranslations, len(translations))
+	for locale, t := range translations {
+		t = FAQTranslation{
+			Condition: strings.TrimSpace(t.Condition),
+			Answer:    strings.TrimSpace(t.Answer),
+		}
+		if t != (FAQTranslation{}) {
+			normalized[strings.ToLower(strings.TrimSpace(locale))] = t
+		}
+	}
+
+	if len(normalized) == 0 {
+		return nil
+	}
+	return normalized
+}
+
+// Nil and 0 stand for a global rule.
+func (s *service) checkFAQCategory(ctx context.Context, categoryID *int) error {
+	if categoryID == nil || *categoryID == 0 {
+		return nil
+	}
+
+	if _, err := s.categoryRepo.GetByID(ctx, *categoryID); errors.Is(err, sql.ErrNoRows) {
+		return ErrCategoryNotFound
+	} else if err != nil {
+		return fmt.Errorf("get category by id: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) GetFAQRules(ctx context.Context, categoryID *int) ([]FAQRule, error) {
+	rules, err := s.repo.GetFAQRules(ctx, categoryID)
+	if err != nil {
+		return nil, fmt.Errorf("get faq rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+func (s *service) CreateFAQRule(ctx context.Context, req CreateFAQRuleRequest) (FAQRule, error) {
+	req.Condition = strings.TrimSpace(req.Condition)
+	req.Answer = strings.TrimSpace(req.Answer)
+	req.Translations = normalizeFAQTranslations(req.Translations)
+
+	if req.MatchMode == "" {
+		req.MatchMode = matchOneOf
+	}
+
+	if req.CategoryID != nil && *req.CategoryID == 0 {
+		req.CategoryID = nil
+	}
+
+	if req.IsActive == nil {
+		active := true
+		req.IsActive = &active
+	}
+
+	err := checkFAQRule(FAQRule{
+		Condition:      req.Condition,
+		MatchMode:      req.MatchMode,
+		MatchThreshold: req.MatchThreshold,
+		Answer:         req.Answer,
+		Translations:   req.Translations,
+	})
+	if err != nil {
+		return FAQRule{}, err
+	}
+
+	if err = s.checkFAQCategory(ctx, req.CategoryID); err != nil {
+		return FAQRule{}, err
+	}
+
+	rule, err := s.repo.CreateFAQRule(ctx, req)
+	if err != nil {
+		return FAQRule{}, fmt.Errorf("create faq rule: %w", err)
+	}
+
+	s.logger.Info("faq rule created", "id", rule.ID)
+	return rule, nil
+}
+
+func (s *service) UpdateFAQRule(ctx context.Context, id int, req UpdateFAQRuleRequest) (FAQRule, error) {
+	rule, err := s.repo.GetFAQRule(ctx, id)
+	if err != nil {
+		return FAQRule{}, fmt.Errorf("get faq rule: %w", err)
+	}
+
+	updated := rule
+	if req.Condition != nil {
+		*req.Condition = strings.TrimSpace(*req.Condition)
+		updated.Condition = *req.Condition
+	}
+	if req.MatchMode != nil {
+		updated.MatchMode = *req.MatchMode
+	}
+	if req.MatchThreshold != nil {
+		updated.MatchThreshold = req.MatchThreshold
+	}
+	if req.Answer != nil {
+		*req.Answer = strings.TrimSpace(*req.Answer)
+		updated.Answer = *req.Answer
+	}
+	// Empty translations in the request clear the stored ones.
+	if req.Translations != nil {
+		updated.Translations = normalizeFAQTranslations(req.Translations)
+		req.Translations = updated.Translations
+		if req.Translations == nil {
+			req.Translations = FAQTranslations{}
+		}
+	}
+
+	if err = checkFAQRule(updated); err != nil {
+		return FAQRule{}, err
+	}
+
+	if err = s.checkFAQCategory(ctx, req.CategoryID); err != nil {
+		return FAQRule{}, err
+	}
+
+	rule, err = s.repo.UpdateFAQRule(ctx, id, req)
+	if err != nil {
+		return FAQRule{}, fmt.Errorf("update faq rule: %w", err)
+	}
+
+	s.logger.Info("faq rule updated", "id", id)
+	return rule, nil
+}
+
+func (s *service) DeleteFAQRule(ctx context.Context, id int) error {
+	if err := s.repo.DeleteFAQRule(ctx, id); err != nil {
+		return fmt.Errorf("delete faq rule: %w", err)
+	}
+
+	s.logger.Info("faq rule deleted", "id", id)
+	return nil
+}
+
+// The base condition always applies, so a customer writing in the default
+// locale is answered too.
+func (rule FAQRule) texts(locale string) ([]string, string) {
+	conditions := []string{rule.Condition}
+	answer := rule.Answer
+
+	if t, ok := rule.Translations[locale]; ok {
+		if t.Condition != "" {
+			conditions = append(conditions, t.Condition)
+		}
+		if t.Answer != "" {
+			answer = t.Answer
+		}
+	}
+
+	return conditions, answer
+}
+
+func matchFAQ(rules []FAQRule, message, locale string) *FAQRule {
+	var best *FAQRule
+	var bestConfidence float64
+
+	for i, rule := range rules {
+		conditions, _ := rule.texts(locale)
+
+		for _, condition := range conditions {
+			step := Step{Condition: &condition, MatchMode: rule.MatchMode, MatchThreshold: rule.MatchThreshold}
+
+			confidence := score(step, message)
+			if confidence >= 1 {
+				return &rules[i]
+			}
+
+			if rule.MatchMode == matchFuzzy && confidence >= threshold(step) && confidence > bestConfidence {
+				best, bestConfidence = &rules[i], confidence
+			}
+		}
+	}
+
+	return best
+}
+
+// The customer may answer with a button sent before the locale was detected.
+func isFAQButton(answer string, button int) bool {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	for _, buttons := range faqButtons {
+		if answer == strings.ToLower(buttons[button]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isStepButton(children []Step, answer string) bool {
+	answer = strings.TrimSpace(answer)
+	for _, button := range stepButtons(children) {
+		if strings.EqualFold(button, answer) {
+			return true
+		}
+	}
+	return false
+}
+
+func faqButtonLabels(locale string) []string {
+	buttons, ok := faqButtons[locale]
+	if !ok {
+		buttons = faqButtons[contacts.DefaultLocale]
+	}
+	return buttons[:]
+}
+
+// answerFAQ reports whether the message was answered; the bot then waits for
+// the feedback instead of moving through the scenario tree.
+func (s *service) answerFAQ(ctx context.Context, session Session, categoryID int, message, locale string) (bool, error) {
+	rules, err := s.repo.GetActiveFAQRules(ctx, categoryID)
+	if err != nil {
+		s.logger.Error("failed to get faq rules", "ticket id", session.TicketID.String(), "error", err.Error())
+		return false, nil
+	}
+
+	rule := matchFAQ(rules, message, locale)
+	if rule == nil {
+		return false, nil
+	}
+
+	if err = s.repo.SetSessionFAQ(ctx, session.TicketID, &rule.ID); err != nil {
+		return false, fmt.Errorf("set session faq: %w", err)
+	}
+
+	s.logFAQ(ctx, session.TicketID, rule.ID, faqAnswered)
+
+	_, answer := rule.texts(locale)
+	answer = interpolate(answer, session.Variables)
+
+	if _, err = s.ticketService.CreateMessageWithButtons(ctx, session.TicketID, 0, "bot", answer, faqButtonLabels(locale)); err != nil {
+		return true, err
+	}
+
+	s.logger.Info("bot answered with faq", "ticket id", session.TicketID.String(), "rule id", rule.ID)
+	return true, nil
+}
+
+// faqFeedback reports whether the message was handled; any message other than
+// the feedback buttons goes through the scenario as usual.
+func (s *service) faqFeedback(ctx context.Context, session Session, answer, locale string) (bool, error) {
+	ticketID := session.TicketID
+	ruleID := *session.FAQRuleID
+
+	if err := s.repo.SetSessionFAQ(ctx, ticketID, nil); err != nil {
+		return false, fmt.Errorf("set session faq: %w", err)
+	}
+
+	reason := openFAQ
+
+	switch {
+	case isFAQButton(answer, faqButtonHelped):
+		s.logFAQ(ctx, ticketID, ruleID, faqHelped)
+		s.recordTransitions(ctx, session, []Transition{{Event: EventResolved, FromStepID: &session.CurrentStepID, Reason: &reason}})
+
+		_, err := s.closeWithAnswer(ctx, ticketID, defaultFAQResolved[locale], session.Variables)
+		return true, err
+
+	case isFAQButton(answer, faqButtonOperator):
+		s.logFAQ(ctx, ticketID, ruleID, faqOperator)
+		s.recordTransitions(ctx, session, []Transition{handoff(session.CurrentStepID, openFAQ, waited(session))})
+		s.saveVariables(ctx, ticketID, session.Variables)
+
+		if _, err := s.ticketService.CreateMessage(ctx, ticketID, 0, "bot", defaultFAQHandoff[locale]); err != nil {
+			return true, err
+		}
+
+		s.logger.Info("bot handed off to operator after faq", "ticket id", ticketID.String())
+		return true, s.ticketService.ChangeStatus(ctx, 0, "bot", ticketID, "open")
+
+	default:
+		return false, nil
+	}
+}
+
+func (s *service) logFAQ(ctx context.Context, ticketID uuid.UUID, ruleID int, outcome string) {
+	s.activityLog.Log(ctx, activity_log.LogEntry{
+		TicketID:  ticketID,
+		ActorID:   0,
+		ActorType: "bot",
+		Action:    activity_log.ActionBotFAQ,
+		Payload:   activity_log.Payload{"rule_id": ruleID, "outcome": outcome},
+	})
+}
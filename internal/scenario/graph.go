@@ -0,0 +1,232 @@
+package scenario
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+// graph indexes the steps of a version. Goto steps may form cycles.
+type graph struct {
+	steps    map[int]*Step
+	children map[int][]Step
+	root     *Step
+
+	// call performs the webhook of a step; without it the bot stops there.
+	call func(step *Step) string
+}
+
+type resolution struct {
+	path    []*Step
+	step    *Step
+	stack   pq.Int64Array
+	jumps   int
+	limited bool
+	failed  bool
+}
+
+func newGraph(steps []Step) *graph {
+	g := &graph{
+		steps:    make(map[int]*Step, len(steps)),
+		children: make(map[int][]Step, len(steps)),
+	}
+
+	for i, step := range steps {
+		g.steps[step.ID] = &steps[i]
+
+		if step.ParentID == nil {
+			if !step.Subflow && g.root == nil {
+				g.root = &steps[i]
+			}
+			continue
+		}
+		g.children[*step.ParentID] = append(g.children[*step.ParentID], step)
+	}
+
+	return g
+}
+
+// resolve enters the step and follows goto, call and webhook steps until a step
+// waits for an answer. When a called sub-flow reaches a leaf, the bot returns to
+// the default child of the call step. Jumps are counted per session, so cycles
+// stop at the limit.
+func (g *graph) resolve(step *Step, stack pq.Int64Array, jumps int) resolution {
+	res := resolution{stack: append(pq.Int64Array{}, stack...), jumps: jumps}
+
+	for {
+		res.path = append(res.path, step)
+		res.step = step
+
+		if step.Webhook != nil && g.call != nil {
+			found := findNext(g.children[step.ID], g.call(step))
+			if found.step == nil {
+				res.failed = true
+				return res
+			}
+
+			if res.jumps >= maxJumpsPerSession || len(res.path) >= maxChainSteps {
+				res.limited = true
+				return res
+			}
+
+			res.jumps++
+			step = g.steps[found.step.ID]
+			continue
+		}
+
+		if target := g.target(step); target != nil {
+			if res.jumps >= maxJumpsPerSession || len(res.path) >= maxChainSteps {
+				res.limited = true
+				return res
+			}
+
+			res.jumps++
+			if step.GotoMode == gotoCall {
+				res.stack = append(res.stack, int64(step.ID))
+			}
+			step = target
+			continue
+		}
+
+		if !g.isLeaf(step) || len(res.stack) == 0 {
+			return res
+		}
+
+		next, stack := g.unwind(res.stack)
+		res.stack = stack
+		if next == nil {
+			return res
+		}
+		step = next
+	}
+}
+
+func (g *graph) unwind(stack pq.Int64Array) (*Step, pq.Int64Array) {
+	if len(stack) == 0 {
+		return nil, stack
+	}
+
+	callID := int(stack[len(stack)-1])
+	stack = stack[:len(stack)-1]
+
+	for _, child := range g.children[callID] {
+		if child.Condition == nil {
+			return g.steps[child.ID], stack
+		}
+	}
+
+	return nil, stack
+}
+
+// trimStack drops the calls the step is no longer inside of, e.g. after the
+// customer went back out of a sub-flow.
+func (g *graph) trimStack(stack pq.Int64Array, stepID int) pq.Int64Array {
+	flow := g.flowRoot(stepID)
+
+	for len(stack) > 0 {
+		call, ok := g.steps[int(stack[len(stack)-1])]
+		if ok && call.GotoStepID != nil && g.flowRoot(*call.GotoStepID) == flow {
+			break
+		}
+		stack = stack[:len(stack)-1]
+	}
+
+	return stack
+}
+
+func (g *graph) flowRoot(stepID int) int {
+	for i := 0; i <= len(g.steps); i++ {
+		step, ok := g.steps[stepID]
+		if !ok || step.ParentID == nil {
+			return stepID
+		}
+		stepID = *step.ParentID
+	}
+	return stepID
+}
+
+func (g *graph) target(step *Step) *Step {
+	if step.GotoStepID == nil {
+		return nil
+	}
+	return g.steps[*step.GotoStepID]
+}
+
+func (g *graph) isLeaf(step *Step) bool {
+	return len(g.children[step.ID]) == 0 && g.target(step) == nil && step.Variable == nil
+}
+
+func (g *graph) unreachable() []int {
+	seen := make(map[int]bool, len(g.steps))
+
+	if g.root != nil {
+		queue := []int{g.root.ID}
+		seen[g.root.ID] = true
+
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+
+			next := make([]int, 0, len(g.children[id])+1)
+			for _, child := range g.children[id] {
+				next = append(next, child.ID)
+			}
+			if target := g.target(g.steps[id]); target != nil {
+				next = append(next, target.ID)
+			}
+
+			for _, n := range next {
+				if !seen[n] {
+					seen[n] = true
+					queue = append(queue, n)
+				}
+			}
+		}
+	}
+
+	var ids []int
+	for id := range g.steps {
+		if !seen[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	return ids
+}
+
+func validateGoto(step Step, children []Step, versionSteps map[int]*Step) error {
+	if step.GotoStepID == nil {
+		return nil
+	}
+
+	if step.GotoMode != gotoJump && step.GotoMode != gotoCall {
+		return fmt.Errorf("%w: goto_mode must be one of jump, call", ErrInvalidGoto)
+	}
+
+	if *step.GotoStepID == step.ID {
+		return fmt.Errorf("%w: step cannot jump to itself", ErrInvalidGoto)
+	}
+
+	target, ok := versionSteps[*step.GotoStepID]
+	if !ok || target.VersionID != step.VersionID {
+		return fmt.Errorf("%w: step %d not found in this version", ErrInvalidGoto, *step.GotoStepID)
+	}
+
+	if step.Variable != nil {
+		return fmt.Errorf("%w: goto step cannot capture a variable", ErrInvalidGoto)
+	}
+
+	if step.GotoMode == gotoJump && len(children) > 0 {
+		return fmt.Errorf("%w: jump step cannot have children", ErrInvalidGoto)
+	}
+
+	if step.GotoMode == gotoCall {
+		if len(children) > 1 || (len(children) == 1 && children[0].Condition != nil) {
+			return fmt.Errorf("%w: call step can only have a default child", ErrInvalidGoto)
+		}
+	}
+
+	return nil
+}
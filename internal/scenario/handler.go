@@ -3,10 +3,15 @@ package scenario
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/AzizovHikmatullo/j-support/internal/customfields"
 	"github.com/AzizovHikmatullo/j-support/internal/tickets"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -17,163 +22,1121 @@ type Service interface {
 	GetByID(ctx context.Context, id int) (Scenario, error)
 	GetAll(ctx context.Context) ([]Scenario, error)
 	Update(ctx context.Context, id int, req UpdateScenarioRequest) (Scenario, error)
+	GetCommands(ctx context.Context, scenarioID int) (Commands, error)
+	UpdateCommands(ctx context.Context, scenarioID int, commands *Commands) (Commands, error)
+	GetTimeout(ctx context.Context, scenarioID int) (Timeout, error)
+	UpdateTimeout(ctx context.Context, scenarioID int, timeout *Timeout) (Timeout, error)
+	GetTargeting(ctx context.Context, scenarioID int) (Targeting, error)
+	UpdateTargeting(ctx context.Context, scenarioID int, targeting *Targeting) (Targeting, error)
+	GetSchedule(ctx context.Context, scenarioID int) (Schedule, error)
+	UpdateSchedule(ctx context.Context, scenarioID int, schedule *Schedule) (Schedule, error)
 	Delete(ctx context.Context, id int) error
 
+	GetVersions(ctx context.Context, scenarioID int) ([]Version, error)
+	GetVersion(ctx context.Context, scenarioID, versionID int) (Version, error)
+	GetDraft(ctx context.Context, scenarioID int) (Version, error)
+	CreateDraft(ctx context.Context, scenarioID int) (Version, error)
+	DiscardDraft(ctx context.Context, scenarioID int) error
+	Publish(ctx context.Context, scenarioID int) (Version, error)
+	Rollback(ctx context.Context, scenarioID, versionID int) (Version, error)
+
+	Simulate(ctx context.Context, scenarioID int, req SimulateRequest) (Simulation, error)
+	Validate(ctx context.Context, scenarioID int, version string) (ValidationReport, error)
+	Analytics(ctx context.Context, scenarioID int, req AnalyticsRequest) (Funnel, error)
+	Experiment(ctx context.Context, categoryID int, name string, req AnalyticsRequest) (Experiment, error)
+	Export(ctx context.Context, scenarioID int, version string) (Document, error)
+	Diagram(ctx context.Context, scenarioID int, version, format string) (string, error)
+	Import(ctx context.Context, doc Document, publish bool) (Version, error)
+	Clone(ctx context.Context, scenarioID int, req CloneScenarioRequest) (Version, error)
+	ImportInto(ctx context.Context, scenarioID int, doc Document, publish bool) (Version, error)
+
 	CreateStep(ctx context.Context, scenarioID int, req CreateStepRequest) (Step, error)
 	GetButtonsForCurrentStep(ctx context.Context, ticketID uuid.UUID) ([]string, error)
 	UpdateStep(ctx context.Context, scenarioID, stepID int, req UpdateStepRequest) (Step, error)
 	DeleteStep(ctx context.Context, scenarioID, stepID int) error
 
-	StartIfExists(ctx context.Context, ticketID uuid.UUID, categoryID int) (*tickets.Message, []string, error)
-	HandleMessage(ctx context.Context, ticketID uuid.UUID, answer string) (*string, error)
+	GetFAQRules(ctx context.Context, categoryID *int) ([]FAQRule, error)
+	CreateFAQRule(ctx context.Context, req CreateFAQRuleRequest) (FAQRule, error)
+	UpdateFAQRule(ctx context.Context, id int, req UpdateFAQRuleRequest) (FAQRule, error)
+	DeleteFAQRule(ctx context.Context, id int) error
+
+	StartIfExists(ctx context.Context, ticket tickets.Ticket, role string) (*tickets.Message, []string, error)
+	HandleMessage(ctx context.Context, ticketID uuid.UUID, answer string) (*string, error)
+	CheckTimeouts(ctx context.Context, now time.Time) error
+	CheckSchedules(ctx context.Context, now time.Time) error
+}
+
+var documentContentTypes = map[string]string{
+	FormatJSON: "application/json; charset=utf-8",
+	FormatYAML: "application/yaml; charset=utf-8",
+}
+
+var diagramContentTypes = map[string]string{
+	FormatMermaid: "text/vnd.mermaid; charset=utf-8",
+	FormatDOT:     "text/vnd.graphviz; charset=utf-8",
+}
+
+type handler struct {
+	service Service
+
+	logger *slog.Logger
+}
+
+func NewHandler(service Service, logger *slog.Logger) *handler {
+	return &handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// @Summary      Создать сценарий бота
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        body  body  scenario.CreateScenarioRequest  true  "Сценарий"
+// @Success      201   {object}  scenario.Scenario
+// @Failure      400   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios [post]
+func (h *handler) Create(c *gin.Context) {
+	var req CreateScenarioRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	scenario, err := h.service.CreateScenario(c.Request.Context(), req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, scenario)
+}
+
+// @Summary      Получить сценарий по ID
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   int   true   "ID сценария"
+// @Success      200   {object}  scenario.Scenario
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id} [get]
+func (h *handler) GetByID(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	scenario, err := h.service.GetByID(c.Request.Context(), scenarioID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, scenario)
+}
+
+// @Summary      Получить все сценарии
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Success      200  {array}  scenario.Scenario
+// @Failure      500  {object}  map[string]string
+// @Router       /scenarios [get]
+func (h *handler) GetAll(c *gin.Context) {
+	scenarios, err := h.service.GetAll(c.Request.Context())
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, scenarios)
+}
+
+// @Summary      Обновить сценарий
+// @Description  Меняет активность и приоритет выбора сценария. Сценарий активируется только если опубликованная версия проходит валидацию, иначе возвращается 422 с отчётом
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id    path   int                             true  "ID сценария"
+// @Param        body  body   scenario.UpdateScenarioRequest  true  "Обновление"
+// @Success      200   {object}  scenario.Scenario
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      422   {object}  map[string]any
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id} [patch]
+func (h *handler) Update(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	var req UpdateScenarioRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	scenario, err := h.service.Update(c.Request.Context(), scenarioID, req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, scenario)
+}
+
+// @Summary      Получить команды бота
+// @Description  Команды (оператор, назад, в начало) распознаются на любом шаге сценария
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   int   true   "ID сценария"
+// @Success      200   {object}  scenario.Commands
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/commands [get]
+func (h *handler) GetCommands(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	commands, err := h.service.GetCommands(c.Request.Context(), scenarioID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, commands)
+}
+
+// @Summary      Изменить команды бота
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id    path   int                true  "ID сценария"
+// @Param        body  body   scenario.Commands  true  "Команды"
+// @Success      200   {object}  scenario.Commands
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/commands [put]
+func (h *handler) UpdateCommands(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	var req Commands
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	commands, err := h.service.UpdateCommands(c.Request.Context(), scenarioID, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, commands)
+}
+
+// @Summary      Сбросить команды бота
+// @Description  Возвращает команды по умолчанию
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   int   true   "ID сценария"
+// @Success      200   {object}  scenario.Commands
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/commands [delete]
+func (h *handler) ResetCommands(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	commands, err := h.service.UpdateCommands(c.Request.Context(), scenarioID, nil)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, commands)
+}
+
+// @Summary      Получить таймаут неактивности
+// @Description  Через сколько секунд без ответа бот напоминает о себе и что делает по истечении таймаута
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   int   true   "ID сценария"
+// @Success      200   {object}  scenario.Timeout
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/timeout [get]
+func (h *handler) GetTimeout(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	timeout, err := h.service.GetTimeout(c.Request.Context(), scenarioID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, timeout)
+}
+
+// @Summary      Изменить таймаут неактивности
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id    path   int               true  "ID сценария"
+// @Param        body  body   scenario.Timeout  true  "Таймаут"
+// @Success      200   {object}  scenario.Timeout
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/timeout [put]
+func (h *handler) UpdateTimeout(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	var req Timeout
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	timeout, err := h.service.UpdateTimeout(c.Request.Context(), scenarioID, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, timeout)
+}
+
+// @Summary      Сбросить таймаут неактивности
+// @Description  Возвращает таймаут по умолчанию: закрытие тикета через 5 минут
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   int   true   "ID сценария"
+// @Success      200   {object}  scenario.Timeout
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/timeout [delete]
+func (h *handler) ResetTimeout(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	timeout, err := h.service.UpdateTimeout(c.Request.Context(), scenarioID, nil)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, timeout)
+}
+
+// @Summary      Получить таргетинг сценария
+// @Description  Канал, роль, атрибуты контакта и время суток, для которых запускается сценарий
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   int   true   "ID сценария"
+// @Success      200   {object}  scenario.Targeting
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/targeting [get]
+func (h *handler) GetTargeting(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	targeting, err := h.service.GetTargeting(c.Request.Context(), scenarioID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, targeting)
+}
+
+// @Summary      Изменить таргетинг сценария
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id    path   int                 true  "ID сценария"
+// @Param        body  body   scenario.Targeting  true  "Таргетинг"
+// @Success      200   {object}  scenario.Targeting
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/targeting [put]
+func (h *handler) UpdateTargeting(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	var req Targeting
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	targeting, err := h.service.UpdateTargeting(c.Request.Context(), scenarioID, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, targeting)
+}
+
+// @Summary      Сбросить таргетинг сценария
+// @Description  Сценарий запускается для всех тикетов категории
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   int   true   "ID сценария"
+// @Success      200   {object}  scenario.Targeting
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/targeting [delete]
+func (h *handler) ResetTargeting(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	targeting, err := h.service.UpdateTargeting(c.Request.Context(), scenarioID, nil)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, targeting)
+}
+
+// @Summary      Получить расписание сценария
+// @Description  Период и повторяющиеся окна по дням недели, в которые сценарий может запускаться
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   int   true   "ID сценария"
+// @Success      200   {object}  scenario.Schedule
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/schedule [get]
+func (h *handler) GetSchedule(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	schedule, err := h.service.GetSchedule(c.Request.Context(), scenarioID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// @Summary      Изменить расписание сценария
+// @Description  Расписание активного сценария не должно пересекаться с расписанием другого активного сценария категории с тем же приоритетом
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id    path   int                true  "ID сценария"
+// @Param        body  body   scenario.Schedule  true  "Расписание"
+// @Success      200   {object}  scenario.Schedule
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      409   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/schedule [put]
+func (h *handler) UpdateSchedule(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	var req Schedule
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	schedule, err := h.service.UpdateSchedule(c.Request.Context(), scenarioID, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// @Summary      Сбросить расписание сценария
+// @Description  Сценарий может запускаться в любое время
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   int   true   "ID сценария"
+// @Success      200   {object}  scenario.Schedule
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/schedule [delete]
+func (h *handler) ResetSchedule(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	schedule, err := h.service.UpdateSchedule(c.Request.Context(), scenarioID, nil)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// @Summary      Удалить сценарий
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   int   true   "ID сценария"
+// @Success      200   {object}  map[string]bool
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id} [delete]
+func (h *handler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), id); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// @Summary      История версий сценария
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   int   true   "ID сценария"
+// @Success      200   {array}   scenario.Version
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/versions [get]
+func (h *handler) GetVersions(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	versions, err := h.service.GetVersions(c.Request.Context(), scenarioID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+// @Summary      Получить версию сценария
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id         path   int   true   "ID сценария"
+// @Param        versionID  path   int   true   "ID версии"
+// @Success      200   {object}  scenario.Version
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/versions/{versionID} [get]
+func (h *handler) GetVersion(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	versionID, err := strconv.Atoi(c.Param("versionID"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid version id"})
+		return
+	}
+
+	version, err := h.service.GetVersion(c.Request.Context(), scenarioID, versionID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, version)
+}
+
+// @Summary      Получить черновик сценария
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   int   true   "ID сценария"
+// @Success      200   {object}  scenario.Version
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/draft [get]
+func (h *handler) GetDraft(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	draft, err := h.service.GetDraft(c.Request.Context(), scenarioID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, draft)
+}
+
+// @Summary      Создать черновик сценария
+// @Description  Черновик создаётся копией опубликованной версии. Если черновик уже есть, возвращается он
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   int   true   "ID сценария"
+// @Success      200   {object}  scenario.Version
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/draft [post]
+func (h *handler) CreateDraft(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	draft, err := h.service.CreateDraft(c.Request.Context(), scenarioID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, draft)
+}
+
+// @Summary      Удалить черновик сценария
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   int   true   "ID сценария"
+// @Success      200   {object}  map[string]bool
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/draft [delete]
+func (h *handler) DiscardDraft(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	if err := h.service.DiscardDraft(c.Request.Context(), scenarioID); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// @Summary      Опубликовать черновик сценария
+// @Description  Черновик становится опубликованной версией, предыдущая версия архивируется. Начатые сессии остаются на своей версии. Черновик с ошибками валидации не публикуется (422, report)
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   int   true   "ID сценария"
+// @Success      200   {object}  scenario.Version
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      422   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/publish [post]
+func (h *handler) Publish(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	version, err := h.service.Publish(c.Request.Context(), scenarioID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, version)
+}
+
+// @Summary      Откатить сценарий к архивной версии
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id         path   int   true   "ID сценария"
+// @Param        versionID  path   int   true   "ID версии"
+// @Success      200   {object}  scenario.Version
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      409   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/versions/{versionID}/rollback [post]
+func (h *handler) Rollback(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	versionID, err := strconv.Atoi(c.Param("versionID"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid version id"})
+		return
+	}
+
+	version, err := h.service.Rollback(c.Request.Context(), scenarioID, versionID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, version)
+}
+
+// @Summary      Прогнать сценарий без создания тикета
+// @Description  Возвращает переписку бота для заданных ответов: вопросы, кнопки, сработавшие условия и default-переходы, момент открытия тикета. Тикеты, сессии и лог активности не изменяются
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id    path   int                       true  "ID сценария"
+// @Param        body  body   scenario.SimulateRequest  true  "Версия (published, draft или ID) и ответы пользователя"
+// @Success      200   {object}  scenario.Simulation
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/simulate [post]
+func (h *handler) Simulate(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	var req SimulateRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	simulation, err := h.service.Simulate(c.Request.Context(), scenarioID, req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, simulation)
 }
 
-type handler struct {
-	service Service
+// @Summary      Проверить сценарий
+// @Description  Статическая проверка версии: ошибки (нет корня, недостижимые шаги, дубли условий, пустые вопросы, циклы переходов) блокируют публикацию и активацию, предупреждения указывают на вероятные ошибки
+// @Tags         scenarios
+// @Produce      json
+// @Security     Bearer
+// @Param        id       path   int     true   "ID сценария"
+// @Param        version  query  string  false  "published (по умолчанию), draft или ID версии"
+// @Success      200   {object}  scenario.ValidationReport
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/validate [get]
+func (h *handler) Validate(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
 
-	logger *slog.Logger
+	report, err := h.service.Validate(c.Request.Context(), scenarioID, c.Query("version"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
 }
 
-func NewHandler(service Service, logger *slog.Logger) *handler {
-	return &handler{
-		service: service,
-		logger:  logger,
+// @Summary      Воронка сценария
+// @Description  Посещения шагов, передачи оператору, закрытия по неактивности и самостоятельные решения по всем версиям сценария, а также популярность веток
+// @Tags         scenarios
+// @Produce      json
+// @Security     Bearer
+// @Param        id       path   int     true   "ID сценария"
+// @Param        from     query  string  false  "Начало периода (RFC3339 или YYYY-MM-DD), по умолчанию 30 дней назад"
+// @Param        to       query  string  false  "Конец периода (RFC3339 или YYYY-MM-DD)"
+// @Param        channel  query  string  false  "Канал тикета (source)"
+// @Success      200   {object}  scenario.Funnel
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/analytics [get]
+func (h *handler) Analytics(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	req, ok := analyticsRequest(c)
+	if !ok {
+		return
 	}
+
+	funnel, err := h.service.Analytics(c.Request.Context(), scenarioID, req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, funnel)
 }
 
-// @Summary      Создать сценарий бота
+// @Summary      Результаты A/B-теста
+// @Description  Сравнение вариантов эксперимента: доля передач оператору, самостоятельных решений, CSAT по оценкам тикетов и время до перехода в open
 // @Tags         scenarios
-// @Accept       json
 // @Produce      json
 // @Security     Bearer
-// @Param        body  body  scenario.CreateScenarioRequest  true  "Сценарий"
-// @Success      201   {object}  scenario.Scenario
+// @Param        category_id  query  int     true   "ID категории"
+// @Param        name         query  string  true   "Название эксперимента"
+// @Param        from         query  string  false  "Начало периода (RFC3339 или YYYY-MM-DD), по умолчанию 30 дней назад"
+// @Param        to           query  string  false  "Конец периода (RFC3339 или YYYY-MM-DD)"
+// @Param        channel      query  string  false  "Канал тикета (source)"
+// @Success      200   {object}  scenario.Experiment
 // @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
 // @Failure      500   {object}  map[string]string
-// @Router       /scenarios [post]
-func (h *handler) Create(c *gin.Context) {
-	var req CreateScenarioRequest
+// @Router       /scenarios/experiments [get]
+func (h *handler) Experiment(c *gin.Context) {
+	categoryID, err := strconv.Atoi(c.Query("category_id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid category id"})
+		return
+	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+	name := c.Query("name")
+	if name == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "name is required"})
 		return
 	}
 
-	scenario, err := h.service.CreateScenario(c.Request.Context(), req)
+	req, ok := analyticsRequest(c)
+	if !ok {
+		return
+	}
+
+	experiment, err := h.service.Experiment(c.Request.Context(), categoryID, name, req)
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, scenario)
+	c.JSON(http.StatusOK, experiment)
 }
 
-// @Summary      Получить сценарий по ID
+// @Summary      Экспортировать сценарий
+// @Description  Дерево шагов выгружается в JSON или YAML с символьными ключами шагов вместо ID
 // @Tags         scenarios
-// @Accept       json
 // @Produce      json
+// @Produce      application/yaml
 // @Security     Bearer
-// @Param        id   path   int   true   "ID сценария"
-// @Success      200   {object}  scenario.Scenario
+// @Param        id       path   int     true   "ID сценария"
+// @Param        format   query  string  false  "json (по умолчанию) или yaml"
+// @Param        version  query  string  false  "published (по умолчанию), draft или ID версии"
+// @Success      200   {object}  scenario.Document
 // @Failure      400   {object}  map[string]string
 // @Failure      404   {object}  map[string]string
 // @Failure      500   {object}  map[string]string
-// @Router       /scenarios/{id} [get]
-func (h *handler) GetByID(c *gin.Context) {
+// @Router       /scenarios/{id}/export [get]
+func (h *handler) Export(c *gin.Context) {
 	scenarioID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
 		return
 	}
 
-	scenario, err := h.service.GetByID(c.Request.Context(), scenarioID)
+	format := c.DefaultQuery("format", FormatJSON)
+	if format != FormatJSON && format != FormatYAML {
+		h.handleError(c, ErrInvalidFormat)
+		return
+	}
+
+	doc, err := h.service.Export(c.Request.Context(), scenarioID, c.Query("version"))
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, scenario)
+	data, err := EncodeDocument(doc, format)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	filename := fmt.Sprintf("scenario-%d.%s", scenarioID, format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, documentContentTypes[format], data)
 }
 
-// @Summary      Получить все сценарии
+// @Summary      Диаграмма сценария
+// @Description  Граф шагов в формате Mermaid flowchart или Graphviz DOT: рёбра подписаны условиями, default-переходы пунктирные, переходы goto жирные, листья ведут к передаче оператору
+// @Tags         scenarios
+// @Produce      plain
+// @Security     Bearer
+// @Param        id       path   int     true   "ID сценария"
+// @Param        format   query  string  false  "mermaid (по умолчанию) или dot"
+// @Param        version  query  string  false  "published (по умолчанию), draft или ID версии"
+// @Success      200   {string}  string
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/{id}/diagram [get]
+func (h *handler) Diagram(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
+		return
+	}
+
+	format := c.DefaultQuery("format", FormatMermaid)
+	if format != FormatMermaid && format != FormatDOT {
+		h.handleError(c, ErrInvalidDiagramFormat)
+		return
+	}
+
+	diagram, err := h.service.Diagram(c.Request.Context(), scenarioID, c.Query("version"), format)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Data(http.StatusOK, diagramContentTypes[format], []byte(diagram))
+}
+
+// @Summary      Импортировать новый сценарий
+// @Description  Создаёт сценарий для category_id из документа и всё дерево шагов в одной транзакции. Возвращает все ошибки документа сразу
 // @Tags         scenarios
 // @Accept       json
+// @Accept       application/yaml
 // @Produce      json
 // @Security     Bearer
-// @Success      200  {array}  scenario.Scenario
-// @Failure      500  {object}  map[string]string
-// @Router       /scenarios [get]
-func (h *handler) GetAll(c *gin.Context) {
-	scenarios, err := h.service.GetAll(c.Request.Context())
+// @Param        format   query  string             false  "json или yaml (по умолчанию по Content-Type)"
+// @Param        publish  query  bool               false  "Сразу опубликовать"
+// @Param        body     body   scenario.Document  true   "Документ сценария"
+// @Success      201   {object}  scenario.Version
+// @Failure      400   {object}  map[string]any
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/import [post]
+func (h *handler) Import(c *gin.Context) {
+	doc, ok := h.readDocument(c)
+	if !ok {
+		return
+	}
+
+	version, err := h.service.Import(c.Request.Context(), doc, c.Query("publish") == "true")
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, scenarios)
+	c.JSON(http.StatusCreated, version)
 }
 
-// @Summary      Обновить сценарий
+// @Summary      Импортировать дерево в черновик сценария
+// @Description  Заменяет черновик сценария деревом из документа в одной транзакции. category_id документа игнорируется
 // @Tags         scenarios
 // @Accept       json
+// @Accept       application/yaml
 // @Produce      json
 // @Security     Bearer
-// @Param        id    path   int                             true  "ID сценария"
-// @Param        body  body   scenario.UpdateScenarioRequest  true  "Обновление"
-// @Success      200   {object}  scenario.Scenario
-// @Failure      400   {object}  map[string]string
+// @Param        id       path   int                true   "ID сценария"
+// @Param        format   query  string             false  "json или yaml (по умолчанию по Content-Type)"
+// @Param        publish  query  bool               false  "Сразу опубликовать"
+// @Param        body     body   scenario.Document  true   "Документ сценария"
+// @Success      200   {object}  scenario.Version
+// @Failure      400   {object}  map[string]any
 // @Failure      404   {object}  map[string]string
 // @Failure      500   {object}  map[string]string
-// @Router       /scenarios/{id} [patch]
-func (h *handler) Update(c *gin.Context) {
+// @Router       /scenarios/{id}/import [post]
+func (h *handler) ImportInto(c *gin.Context) {
 	scenarioID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
 		return
 	}
 
-	var req UpdateScenarioRequest
-	if err = c.ShouldBindJSON(&req); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+	doc, ok := h.readDocument(c)
+	if !ok {
 		return
 	}
 
-	scenario, err := h.service.Update(c.Request.Context(), scenarioID, req)
+	version, err := h.service.ImportInto(c.Request.Context(), scenarioID, doc, c.Query("publish") == "true")
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, scenario)
+	c.JSON(http.StatusOK, version)
 }
 
-// @Summary      Удалить сценарий
+// @Summary      Клонировать сценарий
+// @Description  Копирует все шаги версии (по умолчанию опубликованной) в новый неактивный сценарий той же или другой категории в одной транзакции. Команды, таймаут, таргетинг, расписание и приоритет копируются, участие в A/B-тесте - нет
 // @Tags         scenarios
 // @Accept       json
 // @Produce      json
 // @Security     Bearer
-// @Param        id   path   int   true   "ID сценария"
-// @Success      200   {object}  map[string]bool
-// @Failure      400   {object}  map[string]string
+// @Param        id    path   int                            true  "ID сценария"
+// @Param        body  body   scenario.CloneScenarioRequest  true  "Категория, версия (published, draft или ID) и публикация копии"
+// @Success      201   {object}  scenario.Version
+// @Failure      400   {object}  map[string]any
 // @Failure      404   {object}  map[string]string
 // @Failure      500   {object}  map[string]string
-// @Router       /scenarios/{id} [delete]
-func (h *handler) Delete(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
+// @Router       /scenarios/{id}/clone [post]
+func (h *handler) Clone(c *gin.Context) {
+	scenarioID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid scenario id"})
 		return
 	}
 
-	if err := h.service.Delete(c.Request.Context(), id); err != nil {
+	var req CloneScenarioRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	version, err := h.service.Clone(c.Request.Context(), scenarioID, req)
+	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"ok": true})
+	c.JSON(http.StatusCreated, version)
+}
+
+func (h *handler) readDocument(c *gin.Context) (Document, bool) {
+	format := c.Query("format")
+	if format == "" {
+		format = FormatJSON
+		if strings.Contains(c.ContentType(), "yaml") {
+			format = FormatYAML
+		}
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxDocumentSize))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return Document{}, false
+	}
+
+	doc, err := DecodeDocument(data, format)
+	if err != nil {
+		h.handleError(c, err)
+		return Document{}, false
+	}
+
+	return doc, true
 }
 
-// @Summary      Добавить шаг в сценарий
+// @Summary      Добавить шаг в черновик сценария
+// @Description  Если черновика нет, он создаётся копией опубликованной версии. Шаг с goto_step_id переходит на любой шаг черновика (goto_mode=jump) или вызывает подсценарий (goto_mode=call) и возвращается к своему дочернему шагу по умолчанию. Подсценарий начинается с шага без родителя с subflow=true
 // @Tags         scenarios
 // @Accept       json
 // @Produce      json
@@ -206,7 +1169,7 @@ func (h *handler) CreateStep(c *gin.Context) {
 	c.JSON(http.StatusCreated, step)
 }
 
-// @Summary      Обновить шаг сценария
+// @Summary      Обновить шаг черновика сценария
 // @Tags         scenarios
 // @Accept       json
 // @Produce      json
@@ -247,7 +1210,7 @@ func (h *handler) UpdateStep(c *gin.Context) {
 	c.JSON(http.StatusOK, step)
 }
 
-// @Summary      Удалить шаг сценария
+// @Summary      Удалить шаг черновика сценария
 // @Tags         scenarios
 // @Accept       json
 // @Produce      json
@@ -280,12 +1243,161 @@ func (h *handler) DeleteStep(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
+// @Summary      Получить FAQ-правила
+// @Description  Без category_id возвращаются все правила, с category_id - правила категории и глобальные в порядке проверки
+// @Tags         scenarios
+// @Produce      json
+// @Security     Bearer
+// @Param        category_id  query  int  false  "ID категории"
+// @Success      200  {array}   scenario.FAQRule
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /scenarios/faq [get]
+func (h *handler) GetFAQRules(c *gin.Context) {
+	var categoryID *int
+	if raw := c.Query("category_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid category id"})
+			return
+		}
+		categoryID = &id
+	}
+
+	rules, err := h.service.GetFAQRules(c.Request.Context(), categoryID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// @Summary      Создать FAQ-правило
+// @Description  Правило отвечает на сообщение клиента до ветвления сценария. Без category_id правило глобальное
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        body  body  scenario.CreateFAQRuleRequest  true  "Правило"
+// @Success      201   {object}  scenario.FAQRule
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /scenarios/faq [post]
+func (h *handler) CreateFAQRule(c *gin.Context) {
+	var req CreateFAQRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	rule, err := h.service.CreateFAQRule(c.Request.Context(), req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// @Summary      Обновить FAQ-правило
+// @Description  category_id = 0 делает правило глобальным, пустые translations удаляют переводы
+// @Tags         scenarios
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        ruleID  path  int                            true  "ID правила"
+// @Param        body    body  scenario.UpdateFAQRuleRequest  true  "Обновление"
+// @Success      200     {object}  scenario.FAQRule
+// @Failure      400     {object}  map[string]string
+// @Failure      404     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /scenarios/faq/{ruleID} [patch]
+func (h *handler) UpdateFAQRule(c *gin.Context) {
+	ruleID, err := strconv.Atoi(c.Param("ruleID"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+		return
+	}
+
+	var req UpdateFAQRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	rule, err := h.service.UpdateFAQRule(c.Request.Context(), ruleID, req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// @Summary      Удалить FAQ-правило
+// @Tags         scenarios
+// @Produce      json
+// @Security     Bearer
+// @Param        ruleID  path  int  true  "ID правила"
+// @Success      200     {object}  map[string]bool
+// @Failure      400     {object}  map[string]string
+// @Failure      404     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /scenarios/faq/{ruleID} [delete]
+func (h *handler) DeleteFAQRule(c *gin.Context) {
+	ruleID, err := strconv.Atoi(c.Param("ruleID"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+		return
+	}
+
+	if err := h.service.DeleteFAQRule(c.Request.Context(), ruleID); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
 func (h *handler) handleError(c *gin.Context, err error) {
+	var fieldsErr customfields.ValidationError
+
+	var docErr DocumentError
+
+	var validationErr ValidationError
+
 	switch {
+	case errors.As(err, &docErr):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrInvalidDocument.Error(), "issues": docErr})
+	case errors.As(err, &validationErr):
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": validationErr.Error(), "report": validationErr.Report})
+	case errors.Is(err, ErrInvalidDocument), errors.Is(err, ErrInvalidFormat), errors.Is(err, ErrInvalidKey),
+		errors.Is(err, ErrTooManyAnswers), errors.Is(err, ErrInvalidCondition), errors.Is(err, ErrInvalidThreshold),
+		errors.Is(err, ErrInvalidVariable), errors.Is(err, ErrInvalidValidator), errors.Is(err, ErrInvalidAction),
+		errors.Is(err, ErrInvalidCommands), errors.Is(err, ErrInvalidGoto), errors.Is(err, ErrInvalidSubflow),
+		errors.Is(err, ErrQuestionRequired), errors.Is(err, ErrInvalidRange), errors.Is(err, ErrInvalidTimeout),
+		errors.Is(err, ErrInvalidTargeting), errors.Is(err, ErrInvalidExperiment), errors.Is(err, ErrInvalidTranslation),
+		errors.Is(err, ErrInvalidSchedule), errors.Is(err, ErrInvalidWebhook),
+		errors.Is(err, ErrInvalidDiagramFormat), errors.Is(err, ErrInvalidFAQRule):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrKeyExists):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": ErrKeyExists.Error()})
+	case errors.Is(err, ErrScheduleConflict):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &fieldsErr):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid fields", "fields": fieldsErr})
+	case errors.Is(err, ErrExperimentNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrExperimentNotFound.Error()})
+	case errors.Is(err, ErrCategoryNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrCategoryNotFound.Error()})
 	case errors.Is(err, ErrScenarioNotFound):
 		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrScenarioNotFound.Error()})
 	case errors.Is(err, ErrStepNotFound):
 		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrStepNotFound.Error()})
+	case errors.Is(err, ErrFAQRuleNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrFAQRuleNotFound.Error()})
 	case errors.Is(err, ErrSessionNotFound):
 		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrSessionNotFound.Error()})
 	case errors.Is(err, ErrParentNotFound):
@@ -294,6 +1406,16 @@ func (h *handler) handleError(c *gin.Context, err error) {
 		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": ErrRootAlreadyExists.Error()})
 	case errors.Is(err, ErrDefaultAlreadyExists):
 		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": ErrDefaultAlreadyExists.Error()})
+	case errors.Is(err, ErrVersionNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrVersionNotFound.Error()})
+	case errors.Is(err, ErrDraftNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrDraftNotFound.Error()})
+	case errors.Is(err, ErrStepNotInDraft):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": ErrStepNotInDraft.Error()})
+	case errors.Is(err, ErrNotArchived):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": ErrNotArchived.Error()})
+	case errors.Is(err, ErrEmptyDraft):
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": ErrEmptyDraft.Error()})
 	case errors.Is(err, ErrWrongScenario):
 		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
 	default:
@@ -301,3 +1423,42 @@ func (h *handler) handleError(c *gin.Context, err error) {
 		h.logger.Error("scenario error", "error", err.Error())
 	}
 }
+
+// analyticsRequest reads the period and channel of a report. The period
+// defaults to the last 30 days.
+func analyticsRequest(c *gin.Context) (AnalyticsRequest, bool) {
+	now := time.Now()
+
+	req := AnalyticsRequest{
+		From:    now.AddDate(0, 0, -30),
+		To:      now,
+		Channel: c.Query("channel"),
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := parseTime(from)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+			return req, false
+		}
+		req.From = t
+	}
+
+	if to := c.Query("to"); to != "" {
+		t, err := parseTime(to)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+			return req, false
+		}
+		req.To = t
+	}
+
+	return req, true
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
@@ -0,0 +1,241 @@
+package scenario
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/AzizovHikmatullo/j-support/internal/contacts"
+)
+
+// minDetectLetters is how many letters an answer needs before its language
+// is trusted; short answers like "ok" or "да" are often typed in any locale.
+const minDetectLetters = 4
+
+const tajikLetters = "ғӣқӯҳҷ"
+
+var defaultTimeoutMessages = map[string]string{
+	contacts.LocaleRussian: defaultTimeoutMessage,
+	contacts.LocaleTajik:   "Вақти интизории ҷавоб ба охир расид. Муроҷиати шумо пӯшида мешавад!",
+	contacts.LocaleEnglish: "The waiting time has expired. Your request will be closed!",
+}
+
+var defaultReminders = map[string]string{
+	contacts.LocaleRussian: "Вы ещё здесь? Ответьте, пожалуйста, чтобы продолжить",
+	contacts.LocaleTajik:   "Шумо ҳанӯз дар ин ҷо ҳастед? Лутфан, барои идома ҷавоб диҳед",
+	contacts.LocaleEnglish: "Are you still there? Please reply to continue",
+}
+
+var defaultErrorMessages = map[string]string{
+	contacts.LocaleRussian: defaultErrorMessage,
+	contacts.LocaleTajik:   "Қимати нодуруст, лутфан бори дигар кӯшиш кунед",
+	contacts.LocaleEnglish: "Invalid value, please try again",
+}
+
+var faqButtons = map[string][2]string{
+	contacts.LocaleRussian: {"Это помогло", "Позвать оператора"},
+	contacts.LocaleTajik:   {"Ин кӯмак кард", "Даъвати оператор"},
+	contacts.LocaleEnglish: {"This helped", "Talk to an operator"},
+}
+
+var defaultFAQResolved = map[string]string{
+	contacts.LocaleRussian: "Рады, что смогли помочь! Обращение закрыто",
+	contacts.LocaleTajik:   "Шодем, ки кӯмак карда тавонистем! Муроҷиат пӯшида шуд",
+	contacts.LocaleEnglish: "Glad we could help! Your request is closed",
+}
+
+var defaultFAQHandoff = map[string]string{
+	contacts.LocaleRussian: "Передаём обращение оператору, он скоро ответит",
+	contacts.LocaleTajik:   "Муроҷиат ба оператор супорида шуд, ӯ ба зудӣ ҷавоб медиҳад",
+	contacts.LocaleEnglish: "Your request is passed to an operator, they will reply soon",
+}
+
+func sessionLocale(session Session) string {
+	if session.Locale == nil {
+		return contacts.DefaultLocale
+	}
+	return *session.Locale
+}
+
+func contactLocale(contact contacts.Contact) *string {
+	if contact.Locale == nil {
+		return nil
+	}
+	if locale, ok := contacts.NormalizeLocale(*contact.Locale); ok {
+		return &locale
+	}
+	return nil
+}
+
+func localizeSteps(steps []Step, locale string) []Step {
+	if locale == contacts.DefaultLocale {
+		return steps
+	}
+
+	localized := make([]Step, len(steps))
+	for i, step := range steps {
+		localized[i] = localize(step, locale)
+	}
+	return localized
+}
+
+func localize(step Step, locale string) Step {
+	t, ok := step.Translations[locale]
+	if !ok {
+		return step
+	}
+
+	if t.Question != "" {
+		step.Question = t.Question
+	}
+	if t.ButtonLabel != "" {
+		step.ButtonLabel = &t.ButtonLabel
+	}
+	if t.Condition != "" && step.Condition != nil {
+		step.Condition = &t.Condition
+	}
+	if t.ErrorMessage != "" {
+		step.ErrorMessage = &t.ErrorMessage
+	}
+
+	return step
+}
+
+// findLocalized matches the answer against the localized children first and
+// then against the conditions in the default locale, so a customer may answer
+// in either language. The default child is only used when neither matches.
+func findLocalized(children, base []Step, answer string) match {
+	found := findNext(children, answer)
+	if base == nil || (found.step != nil && !found.defaultUsed) {
+		return found
+	}
+
+	fallback := findNext(base, answer)
+	if fallback.step == nil || fallback.defaultUsed {
+		return found
+	}
+
+	for i := range children {
+		if children[i].ID == fallback.step.ID {
+			fallback.step = &children[i]
+			break
+		}
+	}
+
+	return fallback
+}
+
+// detectLocale guesses the locale by the letters of the text. It returns an
+// empty string when the text is too short to tell.
+func detectLocale(text string) string {
+	var cyrillic, latin int
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case strings.ContainsRune(tajikLetters, r):
+			return contacts.LocaleTajik
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	switch {
+	case cyrillic+latin < minDetectLetters:
+		return ""
+	case cyrillic >= latin:
+		return contacts.LocaleRussian
+	default:
+		return contacts.LocaleEnglish
+	}
+}
+
+func validateTranslations(translations Translations, mode string, condition *string) error {
+	for locale, t := range translations {
+		if err := checkLocale(locale); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidTranslation, err.Error())
+		}
+
+		if t.Condition == "" {
+			continue
+		}
+
+		if condition == nil {
+			return fmt.Errorf("%w: %s: default step cannot have a condition", ErrInvalidTranslation, locale)
+		}
+
+		if err := validateCondition(mode, &t.Condition); err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidTranslation, locale, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func checkLocale(locale string) error {
+	if locale == contacts.DefaultLocale {
+		return fmt.Errorf("%s is the default locale", locale)
+	}
+	if !slices.Contains(contacts.Locales, locale) {
+		return fmt.Errorf("unknown locale %q, must be one of %s", locale, strings.Join(contacts.Locales, ", "))
+	}
+	return nil
+}
+
+func normalizeTranslations(translations Translations) Translations {
+	normalized := make(Translations, len(translations))
+	for locale, t := range translations {
+		t = Translation{
+			Question:     strings.TrimSpace(t.Question),
+			ButtonLabel:  strings.TrimSpace(t.ButtonLabel),
+			Condition:    strings.TrimSpace(t.Condition),
+			ErrorMessage: strings.TrimSpace(t.ErrorMessage),
+		}
+		if t != (Translation{}) {
+			normalized[strings.ToLower(strings.TrimSpace(locale))] = t
+		}
+	}
+
+	if len(normalized) == 0 {
+		return nil
+	}
+	return normalized
+}
+
+// texts returns the reminder and the final message of the timeout in the
+// locale. Texts missing in the locale fall back to the base texts; a missing
+// reminder falls back to the default reminder of the locale.
+func (t Timeout) texts(locale string) (string, string) {
+	reminder, message := t.Reminder, t.Message
+
+	if tr, ok := t.Translations[locale]; ok {
+		if tr.Reminder != "" {
+			reminder = tr.Reminder
+		}
+		if tr.Message != "" {
+			message = tr.Message
+		}
+	}
+
+	if reminder == "" {
+		reminder = defaultReminders[locale]
+	}
+
+	return reminder, message
+}
+
+func baseChildren(steps []Step, stepID int, locale string) []Step {
+	if locale == contacts.DefaultLocale {
+		return nil
+	}
+
+	var children []Step
+	for _, step := range steps {
+		if step.ParentID != nil && *step.ParentID == stepID {
+			children = append(children, step)
+		}
+	}
+	return children
+}
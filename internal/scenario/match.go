@@ -0,0 +1,360 @@
+package scenario
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// conditionPatterns caches compiled regex conditions by their source.
+var conditionPatterns sync.Map
+
+type match struct {
+	step        *Step
+	confidence  float64
+	defaultUsed bool
+}
+
+// findNext picks the first child certainly matched by the answer, then the best
+// fuzzy match above its threshold, then the default child.
+func findNext(children []Step, answer string) match {
+	var defaultStep *Step
+	var best match
+
+	for i, ch := range children {
+		if ch.Condition == nil {
+			defaultStep = &children[i]
+			continue
+		}
+
+		confidence := score(ch, answer)
+		if confidence >= 1 {
+			return match{step: &children[i], confidence: 1}
+		}
+
+		if ch.MatchMode == matchFuzzy && confidence >= threshold(ch) && confidence > best.confidence {
+			best = match{step: &children[i], confidence: confidence}
+		}
+	}
+
+	if best.step != nil {
+		return best
+	}
+
+	if defaultStep != nil {
+		return match{step: defaultStep, defaultUsed: true}
+	}
+
+	return match{}
+}
+
+// score returns 1 or 0 for the strict modes and the similarity for fuzzy mode.
+// Pressing a button sends its label, so the label always matches.
+func score(step Step, answer string) float64 {
+	if step.Condition == nil {
+		return 0
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	if step.ButtonLabel != nil && answer == strings.ToLower(strings.TrimSpace(*step.ButtonLabel)) {
+		return 1
+	}
+
+	// Lowercasing a pattern changes escapes like \D and \P{Lu}, so regex
+	// conditions are kept as written and matched case-insensitively by (?i).
+	if step.MatchMode == matchRegex {
+		re, err := compileCondition(strings.TrimSpace(*step.Condition))
+		if err == nil && re.MatchString(answer) {
+			return 1
+		}
+		return 0
+	}
+
+	condition := strings.ToLower(strings.TrimSpace(*step.Condition))
+
+	if step.MatchMode == matchFuzzy {
+		return fuzzyScore(answer, condition)
+	}
+
+	if matches(step.MatchMode, condition, answer) {
+		return 1
+	}
+	return 0
+}
+
+func matches(mode, condition, answer string) bool {
+	switch mode {
+	case matchExact:
+		return answer == condition
+	case matchWord:
+		return containsWords(answer, condition)
+	case matchOneOf:
+		for _, option := range splitOptions(condition) {
+			if containsWords(answer, option) {
+				return true
+			}
+		}
+		return false
+	case matchRange:
+		min, max, err := parseRange(condition)
+		if err != nil {
+			return false
+		}
+		value, err := parseNumber(answer)
+		if err != nil {
+			return false
+		}
+		return (min == nil || value >= *min) && (max == nil || value <= *max)
+	default:
+		return strings.Contains(answer, condition)
+	}
+}
+
+func buttonLabel(step Step) string {
+	if step.ButtonLabel != nil && *step.ButtonLabel != "" {
+		return *step.ButtonLabel
+	}
+
+	if step.Condition == nil {
+		return ""
+	}
+
+	switch step.MatchMode {
+	case matchRegex, matchRange:
+		return ""
+	case matchOneOf, matchFuzzy:
+		if options := splitOptions(*step.Condition); len(options) > 0 {
+			return options[0]
+		}
+		return ""
+	default:
+		return *step.Condition
+	}
+}
+
+func validateCondition(mode string, condition *string) error {
+	if !matchModes[mode] {
+		return fmt.Errorf("%w: unknown match mode %q", ErrInvalidCondition, mode)
+	}
+
+	if condition == nil {
+		return nil
+	}
+
+	if strings.TrimSpace(*condition) == "" {
+		return fmt.Errorf("%w: condition is empty", ErrInvalidCondition)
+	}
+
+	switch mode {
+	case matchRegex:
+		if _, err := compileCondition(strings.TrimSpace(*condition)); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidCondition, err.Error())
+		}
+	case matchOneOf, matchFuzzy:
+		if len(splitOptions(*condition)) == 0 {
+			return fmt.Errorf("%w: %s needs options separated by '|'", ErrInvalidCondition, mode)
+		}
+	case matchRange:
+		min, max, err := parseRange(*condition)
+		if err != nil {
+			return err
+		}
+		if min != nil && max != nil && *min > *max {
+			return fmt.Errorf("%w: range minimum is greater than maximum", ErrInvalidCondition)
+		}
+	}
+
+	return nil
+}
+
+func compileCondition(pattern string) (*regexp.Regexp, error) {
+	if re, ok := conditionPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	conditionPatterns.Store(pattern, re)
+	return re, nil
+}
+
+func validateThreshold(value *float64) error {
+	if value != nil && (*value <= 0 || *value > 1) {
+		return ErrInvalidThreshold
+	}
+	return nil
+}
+
+func threshold(step Step) float64 {
+	if step.MatchThreshold != nil {
+		return *step.MatchThreshold
+	}
+	return defaultThreshold
+}
+
+// fuzzyScore compares every option of the condition with runs of as many words
+// of the answer, both transliterated to Latin, so "oplata" and "оплота" still
+// match "оплата".
+func fuzzyScore(answer, condition string) float64 {
+	words := splitWords(transliterate(answer))
+	best := 0.0
+
+	for _, option := range splitOptions(condition) {
+		target := splitWords(transliterate(option))
+		if len(target) == 0 {
+			continue
+		}
+
+		phrase := strings.Join(target, " ")
+
+		if len(words) < len(target) {
+			best = max(best, similarity(strings.Join(words, " "), phrase))
+			continue
+		}
+
+		for i := 0; i+len(target) <= len(words); i++ {
+			best = max(best, similarity(strings.Join(words[i:i+len(target)], " "), phrase))
+		}
+	}
+
+	return best
+}
+
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "sch", 'ъ': "",
+	'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'ғ': "gh", 'ӣ': "i", 'қ': "q", 'ӯ': "u", 'ҳ': "h", 'ҷ': "j",
+}
+
+func transliterate(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if latin, ok := translit[r]; ok {
+			b.WriteString(latin)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func splitOptions(condition string) []string {
+	var options []string
+	for _, option := range strings.Split(condition, "|") {
+		if option = strings.TrimSpace(option); option != "" {
+			options = append(options, option)
+		}
+	}
+	return options
+}
+
+func containsWords(text, phrase string) bool {
+	words := splitWords(text)
+	target := splitWords(phrase)
+
+	if len(target) == 0 {
+		return false
+	}
+
+	for i := 0; i+len(target) <= len(words); i++ {
+		found := true
+		for j := range target {
+			if words[i+j] != target[j] {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+
+	return false
+}
+
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// parseRange parses "min..max" where either bound may be omitted.
+func parseRange(condition string) (*float64, *float64, error) {
+	lower, upper, ok := strings.Cut(condition, "..")
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: range must look like min..max", ErrInvalidCondition)
+	}
+
+	var min, max *float64
+
+	if lower = strings.TrimSpace(lower); lower != "" {
+		value, err := parseNumber(lower)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: invalid range minimum", ErrInvalidCondition)
+		}
+		min = &value
+	}
+
+	if upper = strings.TrimSpace(upper); upper != "" {
+		value, err := parseNumber(upper)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: invalid range maximum", ErrInvalidCondition)
+		}
+		max = &value
+	}
+
+	if min == nil && max == nil {
+		return nil, nil, fmt.Errorf("%w: range needs at least one bound", ErrInvalidCondition)
+	}
+
+	return min, max, nil
+}
+
+func parseNumber(value string) (float64, error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), ",", ".")
+	return strconv.ParseFloat(value, 64)
+}
@@ -0,0 +1,129 @@
+package scenario
+
+import "testing"
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		condition string
+		label     string
+		answer    string
+		want      bool
+	}{
+		{"contains", matchContains, "оплата", "", "Проблема с ОПЛАТОЙ и оплата не прошла", true},
+		{"contains miss", matchContains, "оплата", "", "доставка", false},
+		{"contains label", matchContains, "оплата", "Оплата заказа", "оплата заказа", true},
+
+		{"exact", matchExact, "да", "", " Да ", true},
+		{"exact miss", matchExact, "да", "", "да, конечно", false},
+		{"exact label", matchExact, "да", "Согласен", "согласен", true},
+
+		{"word", matchWord, "нет", "", "нет, спасибо", true},
+		{"word miss", matchWord, "нет", "", "интернет", false},
+		{"word label", matchWord, "нет", "Отказаться", "Отказаться", true},
+
+		{"regex", matchRegex, `^\d{6}$`, "", "123456", true},
+		{"regex miss", matchRegex, `^\d{6}$`, "", "12345", false},
+		{"regex keeps escapes", matchRegex, `^\D+$`, "", "abc", true},
+		{"regex keeps unicode classes", matchRegex, `^\p{Cyrillic}+$`, "", "Заказ", true},
+		{"regex ignores case", matchRegex, `^ORDER-\d+$`, "", "order-12", true},
+		{"regex label", matchRegex, `^\d{6}$`, "Ввести номер заказа", "ввести номер заказа", true},
+
+		{"one_of", matchOneOf, "возврат|вернуть деньги", "", "хочу вернуть деньги", true},
+		{"one_of miss", matchOneOf, "возврат|вернуть деньги", "", "вернуть товар", false},
+		{"one_of label", matchOneOf, "возврат|вернуть деньги", "Возврат средств", "Возврат средств", true},
+
+		{"range", matchRange, "1..5", "", "3,5", true},
+		{"range miss", matchRange, "1..5", "", "7", false},
+		{"range label", matchRange, "1..5", "Оценить", "оценить", true},
+
+		{"fuzzy", matchFuzzy, "оплата", "", "оплота", true},
+		{"fuzzy transliterated", matchFuzzy, "оплата", "", "oplata", true},
+		{"fuzzy miss", matchFuzzy, "оплата", "", "доставка", false},
+		{"fuzzy label", matchFuzzy, "оплата", "Деньги списались", "деньги списались", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step := Step{MatchMode: tt.mode, Condition: &tt.condition}
+			if tt.label != "" {
+				step.ButtonLabel = &tt.label
+			}
+
+			if err := validateCondition(step.MatchMode, step.Condition); err != nil {
+				t.Fatalf("validate condition: %v", err)
+			}
+
+			got := score(step, tt.answer) >= threshold(step)
+			if got != tt.want {
+				t.Fatalf("score(%q) = %v, want match %v", tt.answer, score(step, tt.answer), tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreMatchesOwnButton(t *testing.T) {
+	label := "Другой вопрос"
+
+	for mode := range matchModes {
+		for _, withLabel := range []bool{false, true} {
+			condition := "заказ|доставка"
+			switch mode {
+			case matchRegex:
+				condition = `^\d+$`
+			case matchRange:
+				condition = "1..10"
+			}
+
+			step := Step{MatchMode: mode, Condition: &condition}
+			if withLabel {
+				step.ButtonLabel = &label
+			}
+
+			button := buttonLabel(step)
+			if button == "" {
+				if withLabel || (mode != matchRegex && mode != matchRange) {
+					t.Errorf("%s, label %v: no button", mode, withLabel)
+				}
+				continue
+			}
+
+			if got := score(step, button); got < 1 {
+				t.Errorf("%s, label %v: score of button %q = %v, want 1", mode, withLabel, button, got)
+			}
+		}
+	}
+}
+
+func TestFindNext(t *testing.T) {
+	yes, no, fuzzy := "да", "нет", "оплата"
+	children := []Step{
+		{ID: 1, MatchMode: matchExact, Condition: &yes},
+		{ID: 2, MatchMode: matchWord, Condition: &no},
+		{ID: 3, MatchMode: matchFuzzy, Condition: &fuzzy},
+		{ID: 4},
+	}
+
+	tests := []struct {
+		answer      string
+		want        int
+		defaultUsed bool
+	}{
+		{"да", 1, false},
+		{"нет, спасибо", 2, false},
+		{"оплота", 3, false},
+		{"что-то другое", 4, true},
+	}
+
+	for _, tt := range tests {
+		got := findNext(children, tt.answer)
+		if got.step == nil || got.step.ID != tt.want || got.defaultUsed != tt.defaultUsed {
+			t.Errorf("findNext(%q) = %+v, want step %d (default %v)", tt.answer, got, tt.want, tt.defaultUsed)
+		}
+	}
+
+	if got := findNext(children[:3], "что-то другое"); got.step != nil {
+		t.Errorf("without default: step %d, want none", got.step.ID)
+	}
+}
@@ -1,35 +1,503 @@
 package scenario
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
+	"github.com/AzizovHikmatullo/j-support/internal/customfields"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type Scenario struct {
-	ID         int        `json:"id" db:"id"`
-	CategoryID int        `json:"category_id" db:"category_id"`
-	IsActive   bool       `json:"is_active" db:"is_active"`
-	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
-	BotSteps   []StepNode `json:"steps" db:"-"`
+	ID                 int        `json:"id" db:"id"`
+	CategoryID         int        `json:"category_id" db:"category_id"`
+	IsActive           bool       `json:"is_active" db:"is_active"`
+	Priority           int        `json:"priority" db:"priority"`
+	Targeting          *Targeting `json:"targeting,omitempty" db:"targeting"`
+	Schedule           *Schedule  `json:"schedule,omitempty" db:"schedule"`
+	ScheduleActive     *bool      `json:"schedule_active,omitempty" db:"schedule_active"`
+	Experiment         *string    `json:"experiment,omitempty" db:"experiment"`
+	Weight             int        `json:"weight" db:"weight"`
+	PublishedVersionID *int       `json:"published_version_id" db:"published_version_id"`
+	Commands           *Commands  `json:"commands,omitempty" db:"commands"`
+	Timeout            *Timeout   `json:"timeout,omitempty" db:"timeout"`
+	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
+	BotSteps           []StepNode `json:"steps" db:"-"`
+}
+
+// Version is a snapshot of a scenario tree. Only the draft version can be
+// edited; published and archived versions are immutable, so sessions started
+// on them are never affected by later edits.
+type Version struct {
+	ID          int        `json:"id" db:"id"`
+	ScenarioID  int        `json:"scenario_id" db:"scenario_id"`
+	Number      int        `json:"number" db:"number"`
+	Status      string     `json:"status" db:"status"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	PublishedAt *time.Time `json:"published_at" db:"published_at"`
+	BotSteps    []StepNode `json:"steps,omitempty" db:"-"`
 }
 
 type Step struct {
-	ID         int       `json:"id" db:"id"`
-	ScenarioID int       `json:"scenario_id" db:"scenario_id"`
-	ParentID   *int      `json:"parent_id" db:"parent_id"`
-	Condition  *string   `json:"condition" db:"condition"`
-	Question   string    `json:"question" db:"question"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	ID               int                 `json:"id" db:"id"`
+	ScenarioID       int                 `json:"scenario_id" db:"scenario_id"`
+	VersionID        int                 `json:"version_id" db:"version_id"`
+	ParentID         *int                `json:"parent_id" db:"parent_id"`
+	Key              *string             `json:"key,omitempty" db:"key"`
+	Condition        *string             `json:"condition" db:"condition"`
+	MatchMode        string              `json:"match_mode" db:"match_mode"`
+	MatchThreshold   *float64            `json:"match_threshold,omitempty" db:"match_threshold"`
+	ButtonLabel      *string             `json:"button_label,omitempty" db:"button_label"`
+	Question         string              `json:"question" db:"question"`
+	SetFields        customfields.Values `json:"set_fields,omitempty" db:"set_fields"`
+	Variable         *string             `json:"variable,omitempty" db:"variable"`
+	Validator        *string             `json:"validator,omitempty" db:"validator"`
+	ValidatorPattern *string             `json:"validator_pattern,omitempty" db:"validator_pattern"`
+	ErrorMessage     *string             `json:"error_message,omitempty" db:"error_message"`
+	Actions          Actions             `json:"actions,omitempty" db:"actions"`
+	GotoStepID       *int                `json:"goto_step_id,omitempty" db:"goto_step_id"`
+	GotoMode         string              `json:"goto_mode" db:"goto_mode"`
+	Subflow          bool                `json:"subflow" db:"subflow"`
+	Timeout          *Timeout            `json:"timeout,omitempty" db:"timeout"`
+	Translations     Translations        `json:"translations,omitempty" db:"translations"`
+	Webhook          *Webhook            `json:"webhook,omitempty" db:"webhook"`
+	CreatedAt        time.Time           `json:"created_at" db:"created_at"`
 }
 
 type Session struct {
-	TicketID       uuid.UUID `json:"ticket_id" db:"ticket_id"`
-	ScenarioID     int       `json:"scenario_id" db:"scenario_id"`
-	CurrentStepID  int       `json:"current_step_id" db:"current_step_id"`
-	CreatedAt      time.Time `json:"created_at" db:"created_at"`
-	LastActivityAt time.Time `json:"last_activity_at" db:"last_activity_at"`
+	TicketID       uuid.UUID     `json:"ticket_id" db:"ticket_id"`
+	ScenarioID     int           `json:"scenario_id" db:"scenario_id"`
+	VersionID      int           `json:"version_id" db:"version_id"`
+	CurrentStepID  int           `json:"current_step_id" db:"current_step_id"`
+	Variables      Variables     `json:"variables" db:"variables"`
+	History        pq.Int64Array `json:"history" db:"history"`
+	CallStack      pq.Int64Array `json:"call_stack" db:"call_stack"`
+	Jumps          int           `json:"jumps" db:"jumps"`
+	StepEnteredAt  time.Time     `json:"step_entered_at" db:"step_entered_at"`
+	RemindedAt     *time.Time    `json:"reminded_at" db:"reminded_at"`
+	Locale         *string       `json:"locale" db:"locale"`
+	FAQRuleID      *int          `json:"faq_rule_id" db:"faq_rule_id"`
+	CreatedAt      time.Time     `json:"created_at" db:"created_at"`
+	LastActivityAt time.Time     `json:"last_activity_at" db:"last_activity_at"`
+}
+
+// Transition is one move of a bot session. A move between steps has both
+// step IDs; the end of a session (handoff, timeout or resolution) only has
+// the step it ended on. DurationMs is the time the customer spent on the
+// from step before answering.
+type Transition struct {
+	ID               int64     `json:"id" db:"id"`
+	TicketID         uuid.UUID `json:"ticket_id" db:"ticket_id"`
+	ScenarioID       int       `json:"scenario_id" db:"scenario_id"`
+	VersionID        int       `json:"version_id" db:"version_id"`
+	Event            string    `json:"event" db:"event"`
+	FromStepID       *int      `json:"from_step_id" db:"from_step_id"`
+	ToStepID         *int      `json:"to_step_id" db:"to_step_id"`
+	MatchedCondition *string   `json:"matched_condition" db:"matched_condition"`
+	DefaultUsed      bool      `json:"default_used" db:"default_used"`
+	Reason           *string   `json:"reason" db:"reason"`
+	DurationMs       int64     `json:"duration_ms" db:"duration_ms"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+type AnalyticsRequest struct {
+	From    time.Time
+	To      time.Time
+	Channel string
+}
+
+// Funnel shows how sessions of a scenario went: how they ended, how often
+// each step was visited and left, and which branches customers took.
+type Funnel struct {
+	ScenarioID int       `json:"scenario_id"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Channel    string    `json:"channel,omitempty"`
+	FunnelSummary
+	Steps    []StepStats   `json:"steps"`
+	Branches []BranchStats `json:"branches"`
+}
+
+type FunnelSummary struct {
+	Sessions  int `json:"sessions" db:"sessions"`
+	Resolved  int `json:"resolved" db:"resolved"`
+	HandedOff int `json:"handed_off" db:"handed_off"`
+	TimedOut  int `json:"timed_out" db:"timed_out"`
+	NoMatch   int `json:"handed_off_no_match" db:"no_match"`
+	Leaf      int `json:"handed_off_leaf" db:"leaf"`
+	Operator  int `json:"handed_off_operator" db:"operator"`
+	LoopLimit int `json:"handed_off_loop_limit" db:"loop_limit"`
+}
+
+// StepStats counts visits of a step and the sessions that ended on it.
+// AvgTimeMs is the average time customers spent on the step before reacting.
+type StepStats struct {
+	StepID      int     `json:"step_id" db:"step_id"`
+	VersionID   *int    `json:"version_id" db:"version_id"`
+	Key         *string `json:"key,omitempty" db:"key"`
+	Question    *string `json:"question,omitempty" db:"question"`
+	Visits      int     `json:"visits" db:"visits"`
+	Sessions    int     `json:"sessions" db:"sessions"`
+	Handoffs    int     `json:"exits_to_operator" db:"handoffs"`
+	Timeouts    int     `json:"timeouts" db:"timeouts"`
+	Resolutions int     `json:"resolutions" db:"resolutions"`
+	AvgTimeMs   int64   `json:"avg_time_ms" db:"avg_time_ms"`
+}
+
+type BranchStats struct {
+	FromStepID  int `json:"from_step_id" db:"from_step_id"`
+	ToStepID    int `json:"to_step_id" db:"to_step_id"`
+	Count       int `json:"count" db:"count"`
+	DefaultUsed int `json:"default_used" db:"default_used"`
+	Gotos       int `json:"gotos" db:"gotos"`
+}
+
+// Experiment compares the variants of an A/B test: the active scenarios of
+// a category sharing the experiment name. Rates are shares of the sessions of
+// the variant; CSAT is the average rating of its tickets.
+type Experiment struct {
+	CategoryID int            `json:"category_id"`
+	Name       string         `json:"name"`
+	From       time.Time      `json:"from"`
+	To         time.Time      `json:"to"`
+	Channel    string         `json:"channel,omitempty"`
+	Variants   []VariantStats `json:"variants"`
+}
+
+type VariantStats struct {
+	ScenarioID         int      `json:"scenario_id" db:"scenario_id"`
+	IsActive           bool     `json:"is_active" db:"is_active"`
+	Weight             int      `json:"weight" db:"weight"`
+	Sessions           int      `json:"sessions" db:"sessions"`
+	HandedOff          int      `json:"handed_off" db:"handed_off"`
+	HandoffRate        float64  `json:"handoff_rate" db:"-"`
+	SelfResolved       int      `json:"self_resolved" db:"self_resolved"`
+	SelfResolutionRate float64  `json:"self_resolution_rate" db:"-"`
+	Ratings            int      `json:"ratings" db:"ratings"`
+	CSAT               *float64 `json:"csat" db:"csat"`
+	AvgTimeToOpenMs    *int64   `json:"avg_time_to_open_ms" db:"avg_time_to_open_ms"`
+}
+
+// Commands are recognized at any step of a scenario, before the answer is
+// matched against the step conditions.
+type Commands struct {
+	Operator Command `json:"operator"`
+	Back     Command `json:"back"`
+	Restart  Command `json:"restart"`
+}
+
+// Command is triggered by any of its keywords or by its button. An empty
+// button hides the command from the button list; a disabled command is not
+// recognized at all.
+type Command struct {
+	Keywords []string `json:"keywords"`
+	Button   string   `json:"button,omitempty"`
+	Reply    string   `json:"reply,omitempty"`
+	Disabled bool     `json:"disabled,omitempty"`
+}
+
+func (c Commands) Value() (driver.Value, error) {
+	b, err := json.Marshal(c)
+	return string(b), err
+}
+
+func (c *Commands) Scan(src any) error {
+	switch s := src.(type) {
+	case []byte:
+		return json.Unmarshal(s, c)
+	case string:
+		return json.Unmarshal([]byte(s), c)
+	default:
+		return fmt.Errorf("unsupported type: %T", src)
+	}
+}
+
+// Targeting limits the tickets a scenario starts for. Empty lists match
+// everyone; attributes map a contact custom field to the accepted values.
+// The hours window may cross midnight, e.g. from 22:00 to 06:00.
+type Targeting struct {
+	Channels   []string            `json:"channels,omitempty"`
+	Roles      []string            `json:"roles,omitempty"`
+	Attributes map[string][]string `json:"attributes,omitempty"`
+	Hours      *Hours              `json:"hours,omitempty"`
+}
+
+type Hours struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+func (t Targeting) Value() (driver.Value, error) {
+	b, err := json.Marshal(t)
+	return string(b), err
+}
+
+func (t *Targeting) Scan(src any) error {
+	switch s := src.(type) {
+	case []byte:
+		return json.Unmarshal(s, t)
+	case string:
+		return json.Unmarshal([]byte(s), t)
+	default:
+		return fmt.Errorf("unsupported type: %T", src)
+	}
+}
+
+// Schedule limits when a scenario may start. From and To bound the whole
+// schedule; windows repeat every week on the given days in the timezone of
+// the schedule and may cross midnight, e.g. from 22:00 to 06:00. A window
+// crossing midnight belongs to the day it starts on.
+type Schedule struct {
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"`
+	Windows  []Window   `json:"windows,omitempty"`
+	Timezone string     `json:"timezone,omitempty"`
+}
+
+type Window struct {
+	Days []string `json:"days,omitempty"`
+	From string   `json:"from"`
+	To   string   `json:"to"`
+}
+
+func (s Schedule) Value() (driver.Value, error) {
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+func (s *Schedule) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("unsupported type: %T", src)
+	}
+}
+
+// Webhook makes the step call an HTTP endpoint when the bot enters it. The
+// URL and header values may use {{variable}} placeholders. Only the variables
+// used by the placeholders and those listed in Variables are sent: POST sends
+// them as JSON, GET as query parameters. Response maps a variable to a
+// dot-separated path in the JSON response, e.g. "order.status".
+// The bot continues with the child whose condition is "success" or
+// "failure", or with the default child.
+type Webhook struct {
+	URL       string            `json:"url"`
+	Method    string            `json:"method,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Timeout   int               `json:"timeout_ms,omitempty"`
+	Retries   int               `json:"retries,omitempty"`
+	Variables []string          `json:"variables,omitempty"`
+	Response  map[string]string `json:"response,omitempty"`
+}
+
+func (w Webhook) Value() (driver.Value, error) {
+	b, err := json.Marshal(w)
+	return string(b), err
+}
+
+func (w *Webhook) Scan(src any) error {
+	switch s := src.(type) {
+	case []byte:
+		return json.Unmarshal(s, w)
+	case string:
+		return json.Unmarshal([]byte(s), w)
+	default:
+		return fmt.Errorf("unsupported type: %T", src)
+	}
+}
+
+// Translations hold the step texts in other locales, keyed by locale. Empty
+// fields fall back to the step fields in the default locale.
+type Translations map[string]Translation
+
+type Translation struct {
+	Question     string `json:"question,omitempty"`
+	ButtonLabel  string `json:"button_label,omitempty"`
+	Condition    string `json:"condition,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+func (t Translations) Value() (driver.Value, error) {
+	if t == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(t)
+	return string(b), err
+}
+
+func (t *Translations) Scan(src any) error {
+	if src == nil {
+		*t = nil
+		return nil
+	}
+
+	switch s := src.(type) {
+	case []byte:
+		return json.Unmarshal(s, t)
+	case string:
+		return json.Unmarshal([]byte(s), t)
+	default:
+		return fmt.Errorf("unsupported type: %T", src)
+	}
+}
+
+// FAQRule answers a common question before the bot goes on with the
+// scenario tree. The condition is matched like a step condition; a rule
+// without a category applies to every category.
+type FAQRule struct {
+	ID             int             `json:"id" db:"id"`
+	CategoryID     *int            `json:"category_id" db:"category_id"`
+	Condition      string          `json:"condition" db:"condition"`
+	MatchMode      string          `json:"match_mode" db:"match_mode"`
+	MatchThreshold *float64        `json:"match_threshold,omitempty" db:"match_threshold"`
+	Answer         string          `json:"answer" db:"answer"`
+	Translations   FAQTranslations `json:"translations,omitempty" db:"translations"`
+	Priority       int             `json:"priority" db:"priority"`
+	IsActive       bool            `json:"is_active" db:"is_active"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// FAQTranslations hold the condition and the answer of a rule in other
+// locales, keyed by locale. Empty fields fall back to the rule fields.
+type FAQTranslations map[string]FAQTranslation
+
+type FAQTranslation struct {
+	Condition string `json:"condition,omitempty"`
+	Answer    string `json:"answer,omitempty"`
+}
+
+func (t FAQTranslations) Value() (driver.Value, error) {
+	if t == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(t)
+	return string(b), err
+}
+
+func (t *FAQTranslations) Scan(src any) error {
+	if src == nil {
+		*t = nil
+		return nil
+	}
+
+	switch s := src.(type) {
+	case []byte:
+		return json.Unmarshal(s, t)
+	case string:
+		return json.Unmarshal([]byte(s), t)
+	default:
+		return fmt.Errorf("unsupported type: %T", src)
+	}
+}
+
+// Timeout controls what happens when the customer stops answering. After
+// remind_after seconds of inactivity the reminder is sent; after "after"
+// seconds the bot sends the message and closes the ticket, hands it off to
+// an operator or jumps to the step with the goto key.
+type Timeout struct {
+	After       int    `json:"after"`
+	RemindAfter int    `json:"remind_after,omitempty"`
+	Reminder    string `json:"reminder,omitempty"`
+	Message     string `json:"message,omitempty"`
+	Outcome     string `json:"outcome"`
+	Goto        string `json:"goto,omitempty"`
+
+	Translations map[string]TimeoutTranslation `json:"translations,omitempty"`
+}
+
+type TimeoutTranslation struct {
+	Reminder string `json:"reminder,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+func (t Timeout) Value() (driver.Value, error) {
+	b, err := json.Marshal(t)
+	return string(b), err
+}
+
+func (t *Timeout) Scan(src any) error {
+	switch s := src.(type) {
+	case []byte:
+		return json.Unmarshal(s, t)
+	case string:
+		return json.Unmarshal([]byte(s), t)
+	default:
+		return fmt.Errorf("unsupported type: %T", src)
+	}
+}
+
+// Variables holds the answers collected by capture steps, keyed by variable
+// name.
+type Variables map[string]string
+
+func (v Variables) Value() (driver.Value, error) {
+	if v == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func (v *Variables) Scan(src any) error {
+	if src == nil {
+		*v = nil
+		return nil
+	}
+
+	switch s := src.(type) {
+	case []byte:
+		return json.Unmarshal(s, v)
+	case string:
+		return json.Unmarshal([]byte(s), v)
+	default:
+		return fmt.Errorf("unsupported type: %T", src)
+	}
+}
+
+// Action is performed on the ticket when the bot enters the step. Only the
+// parameters of the action type are used.
+type Action struct {
+	Type       string              `json:"type"`
+	Priority   string              `json:"priority,omitempty"`
+	Tag        string              `json:"tag,omitempty"`
+	CategoryID int                 `json:"category_id,omitempty"`
+	Team       string              `json:"team,omitempty"`
+	Fields     customfields.Values `json:"fields,omitempty"`
+}
+
+type Actions []Action
+
+func (a Actions) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(a)
+	return string(b), err
+}
+
+func (a *Actions) Scan(src any) error {
+	if src == nil {
+		*a = nil
+		return nil
+	}
+
+	switch s := src.(type) {
+	case []byte:
+		return json.Unmarshal(s, a)
+	case string:
+		return json.Unmarshal([]byte(s), a)
+	default:
+		return fmt.Errorf("unsupported type: %T", src)
+	}
 }
 
 type StepNode struct {
@@ -41,21 +509,346 @@ type CreateScenarioRequest struct {
 	CategoryID int `json:"category_id" binding:"required"`
 }
 
+// CloneScenarioRequest copies the published version by default. Without a
+// category the clone stays in the category of the source scenario.
+type CloneScenarioRequest struct {
+	CategoryID *int   `json:"category_id"`
+	Version    string `json:"version"`
+	Publish    bool   `json:"publish"`
+}
+
 type UpdateScenarioRequest struct {
-	IsActive bool `json:"is_active"`
+	IsActive   *bool   `json:"is_active"`
+	Priority   *int    `json:"priority"`
+	Experiment *string `json:"experiment"`
+	Weight     *int    `json:"weight"`
+}
+
+type CreateFAQRuleRequest struct {
+	CategoryID     *int            `json:"category_id"`
+	Condition      string          `json:"condition" binding:"required"`
+	MatchMode      string          `json:"match_mode"`
+	MatchThreshold *float64        `json:"match_threshold"`
+	Answer         string          `json:"answer" binding:"required"`
+	Translations   FAQTranslations `json:"translations"`
+	Priority       int             `json:"priority"`
+	IsActive       *bool           `json:"is_active"`
+}
+
+// UpdateFAQRuleRequest changes the given fields of a rule. A category_id of 0
+// makes the rule global.
+type UpdateFAQRuleRequest struct {
+	CategoryID     *int            `json:"category_id"`
+	Condition      *string         `json:"condition"`
+	MatchMode      *string         `json:"match_mode"`
+	MatchThreshold *float64        `json:"match_threshold"`
+	Answer         *string         `json:"answer"`
+	Translations   FAQTranslations `json:"translations"`
+	Priority       *int            `json:"priority"`
+	IsActive       *bool           `json:"is_active"`
 }
 
 type CreateStepRequest struct {
-	ParentID  *int    `json:"parent_id" db:"parent_id"`
-	Condition *string `json:"condition" db:"condition"`
-	Question  string  `json:"question" binding:"required" db:"question"`
+	ParentID         *int                `json:"parent_id" db:"parent_id"`
+	Key              *string             `json:"key" db:"key"`
+	Condition        *string             `json:"condition" db:"condition"`
+	MatchMode        string              `json:"match_mode" db:"match_mode"`
+	MatchThreshold   *float64            `json:"match_threshold" db:"match_threshold"`
+	ButtonLabel      *string             `json:"button_label" db:"button_label"`
+	Question         string              `json:"question" db:"question"`
+	SetFields        customfields.Values `json:"set_fields" db:"set_fields"`
+	Variable         *string             `json:"variable" db:"variable"`
+	Validator        *string             `json:"validator" db:"validator"`
+	ValidatorPattern *string             `json:"validator_pattern" db:"validator_pattern"`
+	ErrorMessage     *string             `json:"error_message" db:"error_message"`
+	Actions          Actions             `json:"actions" db:"actions"`
+	GotoStepID       *int                `json:"goto_step_id" db:"goto_step_id"`
+	GotoMode         string              `json:"goto_mode" db:"goto_mode"`
+	Subflow          bool                `json:"subflow" db:"subflow"`
+	Timeout          *Timeout            `json:"timeout" db:"timeout"`
+	Translations     Translations        `json:"translations" db:"translations"`
+	Webhook          *Webhook            `json:"webhook" db:"webhook"`
 }
 
 type UpdateStepRequest struct {
-	Condition *string `json:"condition" db:"condition"`
-	Question  *string `json:"question" db:"question"`
+	Key              *string             `json:"key" db:"key"`
+	Condition        *string             `json:"condition" db:"condition"`
+	MatchMode        *string             `json:"match_mode" db:"match_mode"`
+	MatchThreshold   *float64            `json:"match_threshold" db:"match_threshold"`
+	ButtonLabel      *string             `json:"button_label" db:"button_label"`
+	Question         *string             `json:"question" db:"question"`
+	SetFields        customfields.Values `json:"set_fields" db:"set_fields"`
+	Variable         *string             `json:"variable" db:"variable"`
+	Validator        *string             `json:"validator" db:"validator"`
+	ValidatorPattern *string             `json:"validator_pattern" db:"validator_pattern"`
+	ErrorMessage     *string             `json:"error_message" db:"error_message"`
+	Actions          Actions             `json:"actions" db:"actions"`
+	GotoStepID       *int                `json:"goto_step_id" db:"goto_step_id"`
+	GotoMode         *string             `json:"goto_mode" db:"goto_mode"`
+	Timeout          *Timeout            `json:"timeout" db:"timeout"`
+	Translations     Translations        `json:"translations" db:"translations"`
+	Webhook          *Webhook            `json:"webhook" db:"webhook"`
+}
+
+// Document is a portable representation of a scenario tree. Steps are
+// referenced by symbolic keys instead of database IDs, so a document can be
+// kept in git and imported into another environment.
+type Document struct {
+	CategoryID int             `json:"category_id,omitempty"`
+	Root       *DocumentStep   `json:"root"`
+	Subflows   []*DocumentStep `json:"subflows,omitempty"`
 }
 
+type DocumentStep struct {
+	Key              string              `json:"key"`
+	Condition        *string             `json:"condition,omitempty"`
+	MatchMode        string              `json:"match_mode,omitempty"`
+	MatchThreshold   *float64            `json:"match_threshold,omitempty"`
+	ButtonLabel      *string             `json:"button_label,omitempty"`
+	Question         string              `json:"question"`
+	SetFields        customfields.Values `json:"set_fields,omitempty"`
+	Variable         *string             `json:"variable,omitempty"`
+	Validator        *string             `json:"validator,omitempty"`
+	ValidatorPattern *string             `json:"validator_pattern,omitempty"`
+	ErrorMessage     *string             `json:"error_message,omitempty"`
+	Actions          Actions             `json:"actions,omitempty"`
+	Goto             string              `json:"goto,omitempty"`
+	GotoMode         string              `json:"goto_mode,omitempty"`
+	Timeout          *Timeout            `json:"timeout,omitempty"`
+	Translations     Translations        `json:"translations,omitempty"`
+	Webhook          *Webhook            `json:"webhook,omitempty"`
+	Children         []*DocumentStep     `json:"children,omitempty"`
+}
+
+type DocumentIssue struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// DocumentError lists every problem found in an imported document.
+type DocumentError []DocumentIssue
+
+func (e DocumentError) Error() string {
+	return fmt.Sprintf("invalid document: %d issue(s)", len(e))
+}
+
+// ValidationReport lists the problems found in a scenario version. Issues
+// that are not tied to a step have no step_id.
+type ValidationReport struct {
+	ScenarioID int               `json:"scenario_id"`
+	VersionID  int               `json:"version_id,omitempty"`
+	Valid      bool              `json:"valid"`
+	Errors     []ValidationIssue `json:"errors"`
+	Warnings   []ValidationIssue `json:"warnings"`
+}
+
+type ValidationIssue struct {
+	StepID  *int    `json:"step_id,omitempty"`
+	StepKey *string `json:"step_key,omitempty"`
+	Code    string  `json:"code"`
+	Message string  `json:"message"`
+}
+
+// ValidationError is returned when a version with validation errors is
+// published or activated.
+type ValidationError struct {
+	Report ValidationReport
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("scenario has %d validation error(s)", len(e.Report.Errors))
+}
+
+type SimulateRequest struct {
+	Version string   `json:"version"`
+	Locale  string   `json:"locale"`
+	Answers []string `json:"answers"`
+
+	// WebhookOutcome is the result every webhook step gets in the dry run:
+	// success (the default) or failure.
+	WebhookOutcome string `json:"webhook_outcome"`
+}
+
+// Simulation is the transcript of a dry run. Nothing is written to tickets,
+// sessions or the activity log while it is built.
+type Simulation struct {
+	ScenarioID    int              `json:"scenario_id"`
+	VersionID     int              `json:"version_id"`
+	Locale        string           `json:"locale"`
+	Transcript    []SimulationTurn `json:"transcript"`
+	TicketOpened  bool             `json:"ticket_opened"`
+	OpenedAfter   *int             `json:"opened_after_answers,omitempty"`
+	OpenReason    string           `json:"open_reason,omitempty"`
+	TicketClosed  bool             `json:"ticket_closed"`
+	ClosedAfter   *int             `json:"closed_after_answers,omitempty"`
+	Variables     Variables        `json:"variables,omitempty"`
+	UnusedAnswers []string         `json:"unused_answers,omitempty"`
+}
+
+// SimulationTurn is one user answer and the bot reaction to it. The first
+// turn has no answer and holds the root question.
+type SimulationTurn struct {
+	Answer           *string             `json:"answer,omitempty"`
+	Command          string              `json:"command,omitempty"`
+	MatchedCondition *string             `json:"matched_condition,omitempty"`
+	Confidence       float64             `json:"confidence,omitempty"`
+	DefaultUsed      bool                `json:"default_used"`
+	StepID           *int                `json:"step_id,omitempty"`
+	StepKey          *string             `json:"step_key,omitempty"`
+	Path             []int               `json:"path,omitempty"`
+	Question         string              `json:"question,omitempty"`
+	Buttons          []string            `json:"buttons,omitempty"`
+	SetFields        customfields.Values `json:"set_fields,omitempty"`
+	Actions          Actions             `json:"actions,omitempty"`
+	Captured         Variables           `json:"captured,omitempty"`
+	InvalidInput     bool                `json:"invalid_input,omitempty"`
+	TicketOpened     bool                `json:"ticket_opened"`
+	TicketClosed     bool                `json:"ticket_closed,omitempty"`
+}
+
+// Match modes of step conditions. In one_of and fuzzy modes the condition
+// lists synonyms separated by "|", in range mode it is "min..max".
+const (
+	matchExact    = "exact"
+	matchContains = "contains"
+	matchWord     = "word"
+	matchRegex    = "regex"
+	matchOneOf    = "one_of"
+	matchRange    = "range"
+	matchFuzzy    = "fuzzy"
+
+	defaultThreshold = 0.8
+)
+
+var matchModes = map[string]bool{
+	matchExact:    true,
+	matchContains: true,
+	matchWord:     true,
+	matchRegex:    true,
+	matchOneOf:    true,
+	matchRange:    true,
+	matchFuzzy:    true,
+}
+
+// A capture step without a validator accepts any non-empty answer.
+const (
+	validatorText   = "text"
+	validatorRegex  = "regex"
+	validatorNumber = "number"
+	validatorPhone  = "phone"
+	validatorDate   = "date"
+
+	maxVariableLength   = 64
+	defaultErrorMessage = "Некорректное значение, попробуйте ещё раз"
+)
+
+var validators = map[string]bool{
+	validatorText:   true,
+	validatorRegex:  true,
+	validatorNumber: true,
+	validatorPhone:  true,
+	validatorDate:   true,
+}
+
+const (
+	commandOperator = "operator"
+	commandBack     = "back"
+	commandRestart  = "restart"
+
+	maxCommandKeywords = 20
+	maxCommandLength   = 64
+)
+
+const (
+	actionSetPriority = "set_priority"
+	actionAddTag      = "add_tag"
+	actionTransfer    = "transfer"
+	actionAssignTeam  = "assign_team"
+	actionSetFields   = "set_fields"
+	actionClose       = "close"
+
+	maxActions = 10
+)
+
+// A call runs the target as a sub-flow and comes back to the default child of
+// the call step when the sub-flow reaches a leaf.
+const (
+	gotoJump = "jump"
+	gotoCall = "call"
+
+	maxJumpsPerSession = 50
+	maxChainSteps      = 20
+)
+
+const (
+	timeoutClose   = "close"
+	timeoutHandoff = "handoff"
+	timeoutGoto    = "goto"
+
+	minTimeout            = 60
+	maxTimeout            = 7 * 24 * 60 * 60
+	defaultTimeout        = 5 * 60
+	defaultTimeoutMessage = "Время ожидания ответа истекло. Ваше обращение будет закрыто!"
+)
+
+const (
+	EventStart    = "start"
+	EventAnswer   = "answer"
+	EventGoto     = "goto"
+	EventBack     = "back"
+	EventRestart  = "restart"
+	EventHandoff  = "handoff"
+	EventTimeout  = "timeout"
+	EventResolved = "resolved"
+)
+
+const (
+	issueNoVersion          = "no_published_version"
+	issueNoRoot             = "no_root"
+	issueUnreachable        = "unreachable"
+	issueEmptyQuestion      = "empty_question"
+	issueInvalidCondition   = "invalid_condition"
+	issueInvalidGoto        = "invalid_goto"
+	issueGotoCycle          = "goto_cycle"
+	issueMultipleDefaults   = "multiple_defaults"
+	issueDuplicateCondition = "duplicate_condition"
+	issueDuplicateButton    = "duplicate_button"
+	issueNoDefault          = "no_default"
+	issueLeafQuestion       = "leaf_question"
+	issueInvalidTimeout     = "invalid_timeout"
+	issueInvalidTranslation = "invalid_translation"
+	issueInvalidWebhook     = "invalid_webhook"
+)
+
+const (
+	openNoRoot    = "no_root"
+	openNoMatch   = "no_match"
+	openLeaf      = "leaf"
+	openOperator  = "operator"
+	openLoopLimit = "loop_limit"
+	openWebhook   = "webhook"
+	openFAQ       = "faq"
+
+	maxSimulationAnswers = 100
+)
+
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+
+	FormatMermaid = "mermaid"
+	FormatDOT     = "dot"
+
+	maxKeyLength    = 64
+	maxDocumentSize = 1 << 20
+)
+
+const (
+	versionDraft     = "draft"
+	versionPublished = "published"
+	versionArchived  = "archived"
+)
+
 var (
 	ErrScenarioNotFound     = errors.New("scenario not found")
 	ErrStepNotFound         = errors.New("step not found")
@@ -64,4 +857,36 @@ var (
 	ErrRootAlreadyExists    = errors.New("scenario already has a root step")
 	ErrDefaultAlreadyExists = errors.New("parent already has a default transition")
 	ErrWrongScenario        = errors.New("parent step belongs to different scenario")
+	ErrVersionNotFound      = errors.New("scenario version not found")
+	ErrDraftNotFound        = errors.New("scenario has no draft")
+	ErrStepNotInDraft       = errors.New("step does not belong to the scenario draft")
+	ErrEmptyDraft           = errors.New("draft has no steps")
+	ErrNotArchived          = errors.New("only archived versions can be restored")
+	ErrInvalidKey           = errors.New("key must be 1-64 letters, digits, '_', '-' or '.'")
+	ErrKeyExists            = errors.New("step with this key already exists")
+	ErrInvalidFormat        = errors.New("format must be one of json, yaml")
+	ErrInvalidDiagramFormat = errors.New("format must be one of mermaid, dot")
+	ErrInvalidDocument      = errors.New("invalid document")
+	ErrTooManyAnswers       = errors.New("too many answers")
+	ErrInvalidCondition     = errors.New("invalid condition")
+	ErrInvalidThreshold     = errors.New("match_threshold must be greater than 0 and at most 1")
+	ErrInvalidVariable      = errors.New("variable must be 1-64 latin letters, digits or '_' and start with a letter")
+	ErrInvalidValidator     = errors.New("invalid validator")
+	ErrInvalidAction        = errors.New("invalid action")
+	ErrInvalidCommands      = errors.New("invalid commands")
+	ErrInvalidGoto          = errors.New("invalid goto")
+	ErrInvalidSubflow       = errors.New("only a step without a parent can start a sub-flow")
+	ErrQuestionRequired     = errors.New("question is required for a step without goto")
+	ErrInvalidRange         = errors.New("invalid date range")
+	ErrInvalidTimeout       = errors.New("invalid timeout")
+	ErrInvalidTargeting     = errors.New("invalid targeting")
+	ErrInvalidExperiment    = errors.New("invalid experiment")
+	ErrInvalidTranslation   = errors.New("invalid translation")
+	ErrExperimentNotFound   = errors.New("experiment not found")
+	ErrCategoryNotFound     = errors.New("category not found")
+	ErrInvalidSchedule      = errors.New("invalid schedule")
+	ErrScheduleConflict     = errors.New("schedule overlaps with another scenario")
+	ErrInvalidWebhook       = errors.New("invalid webhook")
+	ErrFAQRuleNotFound      = errors.New("faq rule not found")
+	ErrInvalidFAQRule       = errors.New("invalid faq rule")
 )
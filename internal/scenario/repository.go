@@ -4,11 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type postgresRepo struct {
@@ -21,16 +23,37 @@ func NewRepository(db *sqlx.DB) Repository {
 	}
 }
 
-func (r *postgresRepo) CreateScenario(ctx context.Context, categoryID int) (Scenario, error) {
+func (r *postgresRepo) CreateScenario(ctx context.Context, tx *sqlx.Tx, categoryID int, isActive bool) (Scenario, error) {
 	var scenario Scenario
 
 	query := `
-		INSERT INTO bot_scenarios(category_id)
-		VALUES ($1)
+		INSERT INTO bot_scenarios(category_id, is_active)
+		VALUES ($1, $2)
 		RETURNING *
 	`
 
-	err := r.db.QueryRowxContext(ctx, query, categoryID).StructScan(&scenario)
+	err := tx.QueryRowxContext(ctx, query, categoryID, isActive).StructScan(&scenario)
+
+	return scenario, err
+}
+
+// CloneScenario creates an inactive scenario in the category with the
+// commands, timeout, targeting, schedule and priority of the source scenario.
+func (r *postgresRepo) CloneScenario(ctx context.Context, tx *sqlx.Tx, sourceID, categoryID int) (Scenario, error) {
+	var scenario Scenario
+
+	query := `
+		INSERT INTO bot_scenarios(category_id, is_active, priority, commands, timeout, targeting, schedule)
+		SELECT $2, false, priority, commands, timeout, targeting, schedule
+		FROM bot_scenarios
+		WHERE id = $1
+		RETURNING *
+	`
+
+	err := tx.QueryRowxContext(ctx, query, sourceID, categoryID).StructScan(&scenario)
+	if errors.Is(err, sql.ErrNoRows) {
+		return scenario, ErrScenarioNotFound
+	}
 
 	return scenario, err
 }
@@ -69,17 +92,56 @@ func (r *postgresRepo) GetAll(ctx context.Context) ([]Scenario, error) {
 func (r *postgresRepo) Update(ctx context.Context, scenarioID int, req UpdateScenarioRequest) (Scenario, error) {
 	var scenario Scenario
 
+	if req.IsActive == nil && req.Priority == nil && req.Experiment == nil && req.Weight == nil {
+		return r.GetByID(ctx, scenarioID)
+	}
+
+	builder := squirrel.Update("bot_scenarios").
+		PlaceholderFormat(squirrel.Dollar).
+		Where(squirrel.Eq{"id": scenarioID})
+
+	if req.IsActive != nil {
+		builder = builder.Set("is_active", req.IsActive)
+	}
+
+	if req.Priority != nil {
+		builder = builder.Set("priority", req.Priority)
+	}
+
+	if req.Experiment != nil {
+		builder = builder.Set("experiment", squirrel.Expr("nullif(?, '')", *req.Experiment))
+	}
+
+	if req.Weight != nil {
+		builder = builder.Set("weight", req.Weight)
+	}
+
+	builder = builder.Suffix("RETURNING *")
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return Scenario{}, err
+	}
+
+	err = r.db.QueryRowxContext(ctx, query, args...).StructScan(&scenario)
+	if errors.Is(err, sql.ErrNoRows) {
+		return scenario, ErrScenarioNotFound
+	}
+
+	return scenario, err
+}
+
+func (r *postgresRepo) UpdateCommands(ctx context.Context, scenarioID int, commands *Commands) (Scenario, error) {
+	var scenario Scenario
+
 	query := `
         UPDATE bot_scenarios
-        SET is_active = $2
+        SET commands = $2
         WHERE id = $1
         RETURNING *
     `
 
-	err := r.db.QueryRowxContext(ctx, query,
-		scenarioID,
-		req.IsActive,
-	).StructScan(&scenario)
+	err := r.db.QueryRowxContext(ctx, query, scenarioID, commands).StructScan(&scenario)
 	if errors.Is(err, sql.ErrNoRows) {
 		return scenario, ErrScenarioNotFound
 	}
@@ -87,6 +149,88 @@ func (r *postgresRepo) Update(ctx context.Context, scenarioID int, req UpdateSce
 	return scenario, err
 }
 
+func (r *postgresRepo) UpdateTimeout(ctx context.Context, scenarioID int, timeout *Timeout) (Scenario, error) {
+	var scenario Scenario
+
+	query := `
+        UPDATE bot_scenarios
+        SET timeout = $2
+        WHERE id = $1
+        RETURNING *
+    `
+
+	err := r.db.QueryRowxContext(ctx, query, scenarioID, timeout).StructScan(&scenario)
+	if errors.Is(err, sql.ErrNoRows) {
+		return scenario, ErrScenarioNotFound
+	}
+
+	return scenario, err
+}
+
+func (r *postgresRepo) UpdateTargeting(ctx context.Context, scenarioID int, targeting *Targeting) (Scenario, error) {
+	var scenario Scenario
+
+	query := `
+        UPDATE bot_scenarios
+        SET targeting = $2
+        WHERE id = $1
+        RETURNING *
+    `
+
+	err := r.db.QueryRowxContext(ctx, query, scenarioID, targeting).StructScan(&scenario)
+	if errors.Is(err, sql.ErrNoRows) {
+		return scenario, ErrScenarioNotFound
+	}
+
+	return scenario, err
+}
+
+// UpdateSchedule forgets the last schedule state, so the scheduler logs the
+// state of the new schedule.
+func (r *postgresRepo) UpdateSchedule(ctx context.Context, scenarioID int, schedule *Schedule) (Scenario, error) {
+	var scenario Scenario
+
+	query := `
+        UPDATE bot_scenarios
+        SET schedule = $2, schedule_active = null
+        WHERE id = $1
+        RETURNING *
+    `
+
+	err := r.db.QueryRowxContext(ctx, query, scenarioID, schedule).StructScan(&scenario)
+	if errors.Is(err, sql.ErrNoRows) {
+		return scenario, ErrScenarioNotFound
+	}
+
+	return scenario, err
+}
+
+func (r *postgresRepo) GetScheduledScenarios(ctx context.Context) ([]Scenario, error) {
+	var scenarios []Scenario
+
+	query := `
+		SELECT *
+		FROM bot_scenarios
+		WHERE is_active = true AND schedule IS NOT NULL
+		ORDER BY category_id, priority DESC, id
+	`
+
+	err := r.db.SelectContext(ctx, &scenarios, query)
+
+	return scenarios, err
+}
+
+func (r *postgresRepo) SetScheduleActive(ctx context.Context, scenarioID int, active bool) error {
+	query := `
+        UPDATE bot_scenarios
+        SET schedule_active = $2
+        WHERE id = $1
+    `
+
+	_, err := r.db.ExecContext(ctx, query, scenarioID, active)
+	return err
+}
+
 func (r *postgresRepo) Delete(ctx context.Context, id int) error {
 	query := `DELETE FROM bot_scenarios WHERE id = $1`
 
@@ -94,53 +238,309 @@ func (r *postgresRepo) Delete(ctx context.Context, id int) error {
 	return err
 }
 
-func (r *postgresRepo) GetActiveScenario(ctx context.Context, categoryID int) (Scenario, error) {
-	var scenario Scenario
+// GetActiveScenarios returns the active scenarios of a category in the order
+// they are tried: higher priority first, then the older scenario.
+func (r *postgresRepo) GetActiveScenarios(ctx context.Context, categoryID int) ([]Scenario, error) {
+	var scenarios []Scenario
 
 	query := `
 		SELECT *
 		FROM bot_scenarios
 		WHERE category_id = $1 AND is_active = true
+		ORDER BY priority DESC, id
 	`
 
-	err := r.db.GetContext(ctx, &scenario, query, categoryID)
+	err := r.db.SelectContext(ctx, &scenarios, query, categoryID)
+
+	return scenarios, err
+}
+
+func (r *postgresRepo) BeginTxx(ctx context.Context) (*sqlx.Tx, error) {
+	return r.db.BeginTxx(ctx, nil)
+}
+
+func (r *postgresRepo) GetVersions(ctx context.Context, scenarioID int) ([]Version, error) {
+	versions := make([]Version, 0)
+
+	query := `
+		SELECT *
+		FROM bot_scenario_versions
+		WHERE scenario_id = $1
+		ORDER BY number DESC
+	`
+
+	err := r.db.SelectContext(ctx, &versions, query, scenarioID)
+
+	return versions, err
+}
+
+func (r *postgresRepo) GetVersion(ctx context.Context, versionID int) (Version, error) {
+	var version Version
+
+	query := `
+		SELECT *
+		FROM bot_scenario_versions
+		WHERE id = $1
+	`
+
+	err := r.db.GetContext(ctx, &version, query, versionID)
 	if errors.Is(err, sql.ErrNoRows) {
-		return scenario, ErrScenarioNotFound
+		return version, ErrVersionNotFound
 	}
 
-	return scenario, err
+	return version, err
 }
 
-func (r *postgresRepo) CreateStep(ctx context.Context, scenarioID int, req CreateStepRequest) (Step, error) {
+func (r *postgresRepo) GetDraft(ctx context.Context, scenarioID int) (Version, error) {
+	var version Version
+
+	query := `
+		SELECT *
+		FROM bot_scenario_versions
+		WHERE scenario_id = $1 AND status = 'draft'
+	`
+
+	err := r.db.GetContext(ctx, &version, query, scenarioID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return version, ErrDraftNotFound
+	}
+
+	return version, err
+}
+
+func (r *postgresRepo) CreateDraft(ctx context.Context, tx *sqlx.Tx, scenarioID int) (Version, error) {
+	var version Version
+
+	query := `
+		INSERT INTO bot_scenario_versions(scenario_id, number, status)
+		SELECT $1, coalesce(max(number), 0) + 1, 'draft'
+		FROM bot_scenario_versions
+		WHERE scenario_id = $1
+		RETURNING *
+	`
+
+	err := tx.QueryRowxContext(ctx, query, scenarioID).StructScan(&version)
+
+	return version, err
+}
+
+// CopySteps copies all steps of one version into another, remapping parent
+// and goto IDs to the new steps. The steps are inserted first and linked once
+// every step exists, so the copy does not depend on the order of the rows.
+// The target version may belong to another scenario.
+func (r *postgresRepo) CopySteps(ctx context.Context, tx *sqlx.Tx, fromVersionID int, to Version) error {
+	var steps []Step
+
+	query := `
+		SELECT *
+		FROM bot_steps
+		WHERE version_id = $1
+		ORDER BY id
+	`
+
+	if err := tx.SelectContext(ctx, &steps, query, fromVersionID); err != nil {
+		return err
+	}
+
+	ids := make(map[int]int, len(steps))
+
+	for _, step := range steps {
+		step.ScenarioID = to.ScenarioID
+		step.VersionID = to.ID
+		step.ParentID = nil
+		step.GotoStepID = nil
+
+		created, err := r.InsertStep(ctx, tx, step)
+		if err != nil {
+			return err
+		}
+		ids[step.ID] = created.ID
+	}
+
+	linkQuery := `UPDATE bot_steps SET parent_id = $2, goto_step_id = $3 WHERE id = $1`
+
+	for _, step := range steps {
+		if step.ParentID == nil && step.GotoStepID == nil {
+			continue
+		}
+
+		parentID, err := remap(ids, step.ParentID)
+		if err != nil {
+			return fmt.Errorf("step %d: parent: %w", step.ID, err)
+		}
+
+		gotoStepID, err := remap(ids, step.GotoStepID)
+		if err != nil {
+			return fmt.Errorf("step %d: goto: %w", step.ID, err)
+		}
+
+		if _, err = tx.ExecContext(ctx, linkQuery, ids[step.ID], parentID, gotoStepID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func remap(ids map[int]int, id *int) (*int, error) {
+	if id == nil {
+		return nil, nil
+	}
+
+	newID, ok := ids[*id]
+	if !ok {
+		return nil, fmt.Errorf("step %d is not in the version", *id)
+	}
+
+	return &newID, nil
+}
+
+func (r *postgresRepo) Publish(ctx context.Context, tx *sqlx.Tx, scenarioID, versionID int) (Version, error) {
+	var version Version
+
+	archiveQuery := `
+		UPDATE bot_scenario_versions
+		SET status = 'archived'
+		WHERE scenario_id = $1 AND status = 'published'
+	`
+
+	if _, err := tx.ExecContext(ctx, archiveQuery, scenarioID); err != nil {
+		return version, err
+	}
+
+	publishQuery := `
+		UPDATE bot_scenario_versions
+		SET status = 'published', published_at = now()
+		WHERE id = $1
+		RETURNING *
+	`
+
+	if err := tx.QueryRowxContext(ctx, publishQuery, versionID).StructScan(&version); err != nil {
+		return version, err
+	}
+
+	pointerQuery := `
+		UPDATE bot_scenarios
+		SET published_version_id = $2
+		WHERE id = $1
+	`
+
+	_, err := tx.ExecContext(ctx, pointerQuery, scenarioID, versionID)
+
+	return version, err
+}
+
+func (r *postgresRepo) DeleteDraft(ctx context.Context, tx *sqlx.Tx, scenarioID int) error {
+	query := `DELETE FROM bot_scenario_versions WHERE scenario_id = $1 AND status = 'draft'`
+
+	_, err := tx.ExecContext(ctx, query, scenarioID)
+
+	return err
+}
+
+func (r *postgresRepo) DeleteVersion(ctx context.Context, versionID int) error {
+	query := `DELETE FROM bot_scenario_versions WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, versionID)
+
+	return err
+}
+
+func (r *postgresRepo) CreateStep(ctx context.Context, scenarioID, versionID int, req CreateStepRequest) (Step, error) {
 	var step Step
 
 	query := `
-        INSERT INTO bot_steps(scenario_id, parent_id, condition, question)
-        VALUES ($1, $2, $3, $4)
+        INSERT INTO bot_steps(scenario_id, version_id, parent_id, key, condition, match_mode, match_threshold, button_label, question, set_fields,
+                              variable, validator, validator_pattern, error_message, actions, goto_step_id, goto_mode, subflow, timeout,
+                              translations, webhook)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
         RETURNING *
     `
 
 	err := r.db.QueryRowxContext(ctx, query,
 		scenarioID,
+		versionID,
 		req.ParentID,
+		req.Key,
 		req.Condition,
+		req.MatchMode,
+		req.MatchThreshold,
+		req.ButtonLabel,
 		req.Question,
+		req.SetFields,
+		req.Variable,
+		req.Validator,
+		req.ValidatorPattern,
+		req.ErrorMessage,
+		req.Actions,
+		req.GotoStepID,
+		req.GotoMode,
+		req.Subflow,
+		req.Timeout,
+		req.Translations,
+		req.Webhook,
 	).StructScan(&step)
 
 	return step, err
 }
 
-func (r *postgresRepo) GetAllSteps(ctx context.Context, scenarioID int) ([]Step, error) {
+func (r *postgresRepo) InsertStep(ctx context.Context, tx *sqlx.Tx, step Step) (Step, error) {
+	var created Step
+
+	query := `
+        INSERT INTO bot_steps(scenario_id, version_id, parent_id, key, condition, match_mode, match_threshold, button_label, question, set_fields,
+                              variable, validator, validator_pattern, error_message, actions, goto_step_id, goto_mode, subflow, timeout,
+                              translations, webhook)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+        RETURNING *
+    `
+
+	err := tx.QueryRowxContext(ctx, query,
+		step.ScenarioID,
+		step.VersionID,
+		step.ParentID,
+		step.Key,
+		step.Condition,
+		step.MatchMode,
+		step.MatchThreshold,
+		step.ButtonLabel,
+		step.Question,
+		step.SetFields,
+		step.Variable,
+		step.Validator,
+		step.ValidatorPattern,
+		step.ErrorMessage,
+		step.Actions,
+		step.GotoStepID,
+		step.GotoMode,
+		step.Subflow,
+		step.Timeout,
+		step.Translations,
+		step.Webhook,
+	).StructScan(&created)
+
+	return created, err
+}
+
+func (r *postgresRepo) SetGoto(ctx context.Context, tx *sqlx.Tx, stepID, gotoStepID int) error {
+	query := `UPDATE bot_steps SET goto_step_id = $2 WHERE id = $1`
+
+	_, err := tx.ExecContext(ctx, query, stepID, gotoStepID)
+
+	return err
+}
+
+func (r *postgresRepo) GetAllSteps(ctx context.Context, versionID int) ([]Step, error) {
 	var steps []Step
 
 	query := `
 		SELECT *
 		FROM bot_steps
-		WHERE scenario_id = $1
+		WHERE version_id = $1
 		ORDER BY id
 	`
 
-	err := r.db.SelectContext(ctx, &steps, query, scenarioID)
+	err := r.db.SelectContext(ctx, &steps, query, versionID)
 
 	return steps, err
 }
@@ -162,16 +562,16 @@ func (r *postgresRepo) GetStep(ctx context.Context, stepID int) (Step, error) {
 	return step, err
 }
 
-func (r *postgresRepo) GetRootStep(ctx context.Context, scenarioID int) (Step, error) {
+func (r *postgresRepo) GetRootStep(ctx context.Context, versionID int) (Step, error) {
 	var step Step
 
 	query := `
         SELECT *
         FROM bot_steps
-        WHERE scenario_id = $1 AND parent_id IS NULL
+        WHERE version_id = $1 AND parent_id IS NULL AND NOT subflow
     `
 
-	err := r.db.GetContext(ctx, &step, query, scenarioID)
+	err := r.db.GetContext(ctx, &step, query, versionID)
 	if errors.Is(err, sql.ErrNoRows) {
 		return step, ErrStepNotFound
 	}
@@ -186,6 +586,7 @@ func (r *postgresRepo) GetChildren(ctx context.Context, parentID int) ([]Step, e
         SELECT *
         FROM bot_steps
         WHERE parent_id = $1
+        ORDER BY id
     `
 
 	err := r.db.SelectContext(ctx, &steps, query, parentID)
@@ -200,14 +601,86 @@ func (r *postgresRepo) UpdateStep(ctx context.Context, stepID int, req UpdateSte
 		PlaceholderFormat(squirrel.Dollar).
 		Where(squirrel.Eq{"id": stepID})
 
+	if req.Key != nil {
+		builder = builder.Set("key", req.Key)
+	}
+
 	if req.Condition != nil {
 		builder = builder.Set("condition", req.Condition)
 	}
 
+	if req.MatchMode != nil {
+		builder = builder.Set("match_mode", req.MatchMode)
+	}
+
+	if req.MatchThreshold != nil {
+		builder = builder.Set("match_threshold", req.MatchThreshold)
+	}
+
+	if req.ButtonLabel != nil {
+		builder = builder.Set("button_label", squirrel.Expr("nullif(?, '')", *req.ButtonLabel))
+	}
+
 	if req.Question != nil {
 		builder = builder.Set("question", req.Question)
 	}
 
+	if req.SetFields != nil {
+		builder = builder.Set("set_fields", req.SetFields)
+	}
+
+	if req.Actions != nil {
+		builder = builder.Set("actions", req.Actions)
+	}
+
+	if req.Variable != nil {
+		builder = builder.Set("variable", squirrel.Expr("nullif(?, '')", *req.Variable))
+	}
+
+	if req.Validator != nil {
+		builder = builder.Set("validator", squirrel.Expr("nullif(?, '')", *req.Validator))
+	}
+
+	if req.ValidatorPattern != nil {
+		builder = builder.Set("validator_pattern", squirrel.Expr("nullif(?, '')", *req.ValidatorPattern))
+	}
+
+	if req.ErrorMessage != nil {
+		builder = builder.Set("error_message", squirrel.Expr("nullif(?, '')", *req.ErrorMessage))
+	}
+
+	if req.GotoStepID != nil {
+		builder = builder.Set("goto_step_id", squirrel.Expr("nullif(?, 0)", *req.GotoStepID))
+	}
+
+	if req.GotoMode != nil {
+		builder = builder.Set("goto_mode", req.GotoMode)
+	}
+
+	if req.Translations != nil {
+		if len(req.Translations) == 0 {
+			builder = builder.Set("translations", nil)
+		} else {
+			builder = builder.Set("translations", req.Translations)
+		}
+	}
+
+	if req.Webhook != nil {
+		if req.Webhook.URL == "" {
+			builder = builder.Set("webhook", nil)
+		} else {
+			builder = builder.Set("webhook", *req.Webhook)
+		}
+	}
+
+	if req.Timeout != nil {
+		if req.Timeout.After == 0 {
+			builder = builder.Set("timeout", nil)
+		} else {
+			builder = builder.Set("timeout", *req.Timeout)
+		}
+	}
+
 	builder = builder.Suffix("RETURNING *")
 
 	query, args, err := builder.ToSql()
@@ -231,13 +704,22 @@ func (r *postgresRepo) DeleteStep(ctx context.Context, stepID int) error {
 	return err
 }
 
-func (r *postgresRepo) CreateSession(ctx context.Context, ticketID uuid.UUID, scenarioID, stepID int) error {
+func (r *postgresRepo) CreateSession(ctx context.Context, session Session) error {
 	query := `
-		INSERT INTO bot_sessions(ticket_id, scenario_id, current_step_id)
-		VALUES ($1, $2, $3)
+		INSERT INTO bot_sessions(ticket_id, scenario_id, version_id, current_step_id, call_stack, jumps, locale, variables)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`
 
-	_, err := r.db.ExecContext(ctx, query, ticketID, scenarioID, stepID)
+	_, err := r.db.ExecContext(ctx, query,
+		session.TicketID,
+		session.ScenarioID,
+		session.VersionID,
+		session.CurrentStepID,
+		session.CallStack,
+		session.Jumps,
+		session.Locale,
+		session.Variables,
+	)
 
 	return err
 }
@@ -261,7 +743,9 @@ func (r *postgresRepo) GetSession(ctx context.Context, ticketID uuid.UUID) (Sess
 
 func (r *postgresRepo) GetInactiveSessions(ctx context.Context, cutoff time.Time) ([]Session, error) {
 	query := `
-        SELECT bs.ticket_id, bs.scenario_id, bs.current_step_id, bs.created_at, bs.last_activity_at
+        SELECT bs.ticket_id, bs.scenario_id, bs.version_id, bs.current_step_id, bs.variables, bs.history,
+               bs.call_stack, bs.jumps, bs.step_entered_at, bs.reminded_at, bs.locale, bs.faq_rule_id,
+               bs.created_at, bs.last_activity_at
 		FROM bot_sessions bs
 		JOIN tickets t ON t.id = bs.ticket_id
 		WHERE t.status = 'pending' AND bs.last_activity_at < $1;
@@ -274,14 +758,15 @@ func (r *postgresRepo) GetInactiveSessions(ctx context.Context, cutoff time.Time
 	return sessions, err
 }
 
-func (r *postgresRepo) UpdateSession(ctx context.Context, ticketID uuid.UUID, nextStepID int) error {
+func (r *postgresRepo) UpdateSession(ctx context.Context, ticketID uuid.UUID, nextStepID int, callStack pq.Int64Array, jumps int) error {
 	query := `
         UPDATE bot_sessions
-        SET current_step_id = $2
+        SET current_step_id = $2, history = array_append(history, current_step_id), call_stack = $3, jumps = $4,
+            step_entered_at = now(), faq_rule_id = NULL
         WHERE ticket_id = $1
     `
 
-	_, err := r.db.ExecContext(ctx, query, ticketID, nextStepID)
+	_, err := r.db.ExecContext(ctx, query, ticketID, nextStepID, callStack, jumps)
 	if errors.Is(err, sql.ErrNoRows) {
 		return ErrSessionNotFound
 	}
@@ -289,6 +774,63 @@ func (r *postgresRepo) UpdateSession(ctx context.Context, ticketID uuid.UUID, ne
 	return err
 }
 
+func (r *postgresRepo) StepBack(ctx context.Context, ticketID uuid.UUID) (int, error) {
+	var stepID int
+
+	query := `
+        UPDATE bot_sessions
+        SET current_step_id = history[cardinality(history)],
+            history = history[1:cardinality(history) - 1],
+            step_entered_at = now(), faq_rule_id = NULL
+        WHERE ticket_id = $1 AND cardinality(history) > 0
+        RETURNING current_step_id
+    `
+
+	err := r.db.QueryRowxContext(ctx, query, ticketID).Scan(&stepID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrSessionNotFound
+	}
+
+	return stepID, err
+}
+
+func (r *postgresRepo) ResetSession(ctx context.Context, ticketID uuid.UUID, stepID int, callStack pq.Int64Array) error {
+	query := `
+        UPDATE bot_sessions
+        SET current_step_id = $2, history = '{}', call_stack = $3, variables = '{}'::jsonb, step_entered_at = now(),
+            faq_rule_id = NULL
+        WHERE ticket_id = $1
+    `
+
+	_, err := r.db.ExecContext(ctx, query, ticketID, stepID, callStack)
+
+	return err
+}
+
+func (r *postgresRepo) SetCallStack(ctx context.Context, ticketID uuid.UUID, callStack pq.Int64Array) error {
+	query := `
+        UPDATE bot_sessions
+        SET call_stack = $2
+        WHERE ticket_id = $1
+    `
+
+	_, err := r.db.ExecContext(ctx, query, ticketID, callStack)
+
+	return err
+}
+
+func (r *postgresRepo) SetVariable(ctx context.Context, ticketID uuid.UUID, name, value string) error {
+	query := `
+        UPDATE bot_sessions
+        SET variables = variables || jsonb_build_object($2::text, $3::text)
+        WHERE ticket_id = $1
+    `
+
+	_, err := r.db.ExecContext(ctx, query, ticketID, name, value)
+
+	return err
+}
+
 func (r *postgresRepo) UpdateLastActivity(ctx context.Context, ticketID uuid.UUID) error {
 	query := `
         UPDATE bot_sessions
@@ -300,3 +842,352 @@ func (r *postgresRepo) UpdateLastActivity(ctx context.Context, ticketID uuid.UUI
 
 	return err
 }
+
+// SetLocale also stores the locale on the contact when it has none yet, so the
+// next tickets start in the same language.
+func (r *postgresRepo) SetLocale(ctx context.Context, ticketID uuid.UUID, locale string) error {
+	query := `
+        WITH session AS (
+            UPDATE bot_sessions
+            SET locale = $2
+            WHERE ticket_id = $1
+            RETURNING ticket_id
+        )
+        UPDATE contacts c
+        SET locale = $2
+        FROM tickets t
+        JOIN session s ON s.ticket_id = t.id
+        WHERE c.id = t.contact_id AND c.locale IS NULL
+    `
+
+	_, err := r.db.ExecContext(ctx, query, ticketID, locale)
+
+	return err
+}
+
+func (r *postgresRepo) SetSessionFAQ(ctx context.Context, ticketID uuid.UUID, ruleID *int) error {
+	query := `
+        UPDATE bot_sessions
+        SET faq_rule_id = $2
+        WHERE ticket_id = $1
+    `
+
+	_, err := r.db.ExecContext(ctx, query, ticketID, ruleID)
+
+	return err
+}
+
+func (r *postgresRepo) MarkReminded(ctx context.Context, ticketID uuid.UUID) error {
+	query := `
+        UPDATE bot_sessions
+        SET reminded_at = now()
+        WHERE ticket_id = $1
+    `
+
+	_, err := r.db.ExecContext(ctx, query, ticketID)
+
+	return err
+}
+
+func (r *postgresRepo) RecordTransitions(ctx context.Context, transitions []Transition) error {
+	if len(transitions) == 0 {
+		return nil
+	}
+
+	builder := squirrel.Insert("bot_transitions").
+		PlaceholderFormat(squirrel.Dollar).
+		Columns("ticket_id", "scenario_id", "version_id", "event", "from_step_id", "to_step_id",
+			"matched_condition", "default_used", "reason", "duration_ms")
+
+	for _, t := range transitions {
+		builder = builder.Values(t.TicketID, t.ScenarioID, t.VersionID, t.Event, t.FromStepID, t.ToStepID,
+			t.MatchedCondition, t.DefaultUsed, t.Reason, t.DurationMs)
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, query, args...)
+
+	return err
+}
+
+const transitionsFilter = `
+		SELECT bt.*
+		FROM bot_transitions bt
+		JOIN tickets t ON t.id = bt.ticket_id
+		WHERE bt.scenario_id = $1 AND bt.created_at >= $2 AND bt.created_at < $3 AND ($4 = '' OR t.source = $4)
+`
+
+func (r *postgresRepo) GetFunnelSummary(ctx context.Context, scenarioID int, req AnalyticsRequest) (FunnelSummary, error) {
+	var summary FunnelSummary
+
+	query := `
+		WITH filtered AS (` + transitionsFilter + `)
+		SELECT count(*) FILTER (WHERE event = 'start') AS sessions,
+		       count(*) FILTER (WHERE event = 'resolved') AS resolved,
+		       count(*) FILTER (WHERE event = 'handoff') AS handed_off,
+		       count(*) FILTER (WHERE event = 'timeout') AS timed_out,
+		       count(*) FILTER (WHERE event = 'handoff' AND reason = 'no_match') AS no_match,
+		       count(*) FILTER (WHERE event = 'handoff' AND reason = 'leaf') AS leaf,
+		       count(*) FILTER (WHERE event = 'handoff' AND reason = 'operator') AS operator,
+		       count(*) FILTER (WHERE event = 'handoff' AND reason = 'loop_limit') AS loop_limit
+		FROM filtered
+	`
+
+	err := r.db.GetContext(ctx, &summary, query, scenarioID, req.From, req.To, req.Channel)
+
+	return summary, err
+}
+
+// Time to open runs from the start of the session to the first handoff to an
+// operator.
+func (r *postgresRepo) GetExperimentStats(ctx context.Context, categoryID int, name string, req AnalyticsRequest) ([]VariantStats, error) {
+	stats := make([]VariantStats, 0)
+
+	query := `
+		WITH sessions AS (
+			SELECT bs.ticket_id, bs.scenario_id, bs.created_at
+			FROM bot_sessions bs
+			JOIN bot_scenarios s ON s.id = bs.scenario_id
+			JOIN tickets t ON t.id = bs.ticket_id
+			WHERE s.category_id = $1 AND s.experiment = $2 AND bs.created_at >= $3 AND bs.created_at < $4
+			  AND ($5 = '' OR t.source = $5)
+		),
+		handoffs AS (
+			SELECT bt.ticket_id, min(bt.created_at) AS opened_at
+			FROM bot_transitions bt
+			JOIN sessions ss ON ss.ticket_id = bt.ticket_id
+			WHERE bt.event = 'handoff' OR (bt.event = 'timeout' AND bt.reason = 'handoff')
+			GROUP BY bt.ticket_id
+		),
+		resolved AS (
+			SELECT DISTINCT bt.ticket_id
+			FROM bot_transitions bt
+			JOIN sessions ss ON ss.ticket_id = bt.ticket_id
+			WHERE bt.event = 'resolved'
+		)
+		SELECT s.id AS scenario_id, s.is_active, s.weight,
+		       count(ss.ticket_id) AS sessions,
+		       count(h.ticket_id) AS handed_off,
+		       count(r.ticket_id) AS self_resolved,
+		       count(tr.score) AS ratings,
+		       avg(tr.score)::float8 AS csat,
+		       (avg(extract(epoch FROM h.opened_at - ss.created_at)) * 1000)::bigint AS avg_time_to_open_ms
+		FROM bot_scenarios s
+		LEFT JOIN sessions ss ON ss.scenario_id = s.id
+		LEFT JOIN handoffs h ON h.ticket_id = ss.ticket_id
+		LEFT JOIN resolved r ON r.ticket_id = ss.ticket_id
+		LEFT JOIN ticket_ratings tr ON tr.ticket_id = ss.ticket_id
+		WHERE s.category_id = $1 AND s.experiment = $2
+		GROUP BY s.id
+		ORDER BY s.id
+	`
+
+	err := r.db.SelectContext(ctx, &stats, query, categoryID, name, req.From, req.To, req.Channel)
+
+	return stats, err
+}
+
+// The time spent is only averaged over moves made by the customer or the
+// inactivity job, since the bot passes goto and leaf steps immediately.
+func (r *postgresRepo) GetStepStats(ctx context.Context, scenarioID int, req AnalyticsRequest) ([]StepStats, error) {
+	stats := make([]StepStats, 0)
+
+	query := `
+		WITH filtered AS (` + transitionsFilter + `),
+		visits AS (
+			SELECT to_step_id AS step_id, count(*) AS visits, count(DISTINCT ticket_id) AS sessions
+			FROM filtered
+			WHERE to_step_id IS NOT NULL
+			GROUP BY to_step_id
+		),
+		exits AS (
+			SELECT from_step_id AS step_id,
+			       count(*) FILTER (WHERE event = 'handoff') AS handoffs,
+			       count(*) FILTER (WHERE event = 'timeout') AS timeouts,
+			       count(*) FILTER (WHERE event = 'resolved') AS resolutions,
+			       coalesce(avg(duration_ms) FILTER (
+			           WHERE event IN ('answer', 'back', 'restart', 'timeout') OR reason IN ('no_match', 'operator')
+			       ), 0)::bigint AS avg_time_ms
+			FROM filtered
+			WHERE from_step_id IS NOT NULL
+			GROUP BY from_step_id
+		)
+		SELECT coalesce(v.step_id, e.step_id) AS step_id, s.version_id, s.key, s.question,
+		       coalesce(v.visits, 0) AS visits, coalesce(v.sessions, 0) AS sessions,
+		       coalesce(e.handoffs, 0) AS handoffs, coalesce(e.timeouts, 0) AS timeouts,
+		       coalesce(e.resolutions, 0) AS resolutions, coalesce(e.avg_time_ms, 0) AS avg_time_ms
+		FROM visits v
+		FULL JOIN exits e ON e.step_id = v.step_id
+		LEFT JOIN bot_steps s ON s.id = coalesce(v.step_id, e.step_id)
+		ORDER BY visits DESC, step_id
+	`
+
+	err := r.db.SelectContext(ctx, &stats, query, scenarioID, req.From, req.To, req.Channel)
+
+	return stats, err
+}
+
+func (r *postgresRepo) GetBranchStats(ctx context.Context, scenarioID int, req AnalyticsRequest) ([]BranchStats, error) {
+	stats := make([]BranchStats, 0)
+
+	query := `
+		WITH filtered AS (` + transitionsFilter + `)
+		SELECT from_step_id, to_step_id, count(*) AS count,
+		       count(*) FILTER (WHERE default_used) AS default_used,
+		       count(*) FILTER (WHERE event = 'goto') AS gotos
+		FROM filtered
+		WHERE event IN ('answer', 'goto')
+		GROUP BY from_step_id, to_step_id
+		ORDER BY count DESC, from_step_id, to_step_id
+	`
+
+	err := r.db.SelectContext(ctx, &stats, query, scenarioID, req.From, req.To, req.Channel)
+
+	return stats, err
+}
+
+func (r *postgresRepo) GetFAQRules(ctx context.Context, categoryID *int) ([]FAQRule, error) {
+	rules := make([]FAQRule, 0)
+
+	query := `
+		SELECT *
+		FROM faq_rules
+		WHERE $1::int IS NULL OR category_id = $1 OR category_id IS NULL
+		ORDER BY category_id IS NULL, priority DESC, id
+	`
+
+	err := r.db.SelectContext(ctx, &rules, query, categoryID)
+
+	return rules, err
+}
+
+// GetActiveFAQRules returns the rules in the order they are tried: rules of the
+// category first, then higher priority, then the older rule.
+func (r *postgresRepo) GetActiveFAQRules(ctx context.Context, categoryID int) ([]FAQRule, error) {
+	var rules []FAQRule
+
+	query := `
+		SELECT *
+		FROM faq_rules
+		WHERE is_active = true AND (category_id = $1 OR category_id IS NULL)
+		ORDER BY category_id IS NULL, priority DESC, id
+	`
+
+	err := r.db.SelectContext(ctx, &rules, query, categoryID)
+
+	return rules, err
+}
+
+func (r *postgresRepo) GetFAQRule(ctx context.Context, id int) (FAQRule, error) {
+	var rule FAQRule
+
+	query := `SELECT * FROM faq_rules WHERE id = $1`
+
+	err := r.db.GetContext(ctx, &rule, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rule, ErrFAQRuleNotFound
+	}
+
+	return rule, err
+}
+
+func (r *postgresRepo) CreateFAQRule(ctx context.Context, req CreateFAQRuleRequest) (FAQRule, error) {
+	var rule FAQRule
+
+	query := `
+		INSERT INTO faq_rules(category_id, condition, match_mode, match_threshold, answer, translations, priority, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING *
+	`
+
+	err := r.db.QueryRowxContext(ctx, query,
+		req.CategoryID,
+		req.Condition,
+		req.MatchMode,
+		req.MatchThreshold,
+		req.Answer,
+		req.Translations,
+		req.Priority,
+		*req.IsActive,
+	).StructScan(&rule)
+
+	return rule, err
+}
+
+func (r *postgresRepo) UpdateFAQRule(ctx context.Context, id int, req UpdateFAQRuleRequest) (FAQRule, error) {
+	var rule FAQRule
+
+	builder := squirrel.Update("faq_rules").
+		PlaceholderFormat(squirrel.Dollar).
+		Set("updated_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"id": id})
+
+	if req.CategoryID != nil {
+		builder = builder.Set("category_id", squirrel.Expr("nullif(?, 0)", *req.CategoryID))
+	}
+
+	if req.Condition != nil {
+		builder = builder.Set("condition", *req.Condition)
+	}
+
+	if req.MatchMode != nil {
+		builder = builder.Set("match_mode", *req.MatchMode)
+	}
+
+	if req.MatchThreshold != nil {
+		builder = builder.Set("match_threshold", *req.MatchThreshold)
+	}
+
+	if req.Answer != nil {
+		builder = builder.Set("answer", *req.Answer)
+	}
+
+	if req.Translations != nil {
+		if len(req.Translations) == 0 {
+			builder = builder.Set("translations", nil)
+		} else {
+			builder = builder.Set("translations", req.Translations)
+		}
+	}
+
+	if req.Priority != nil {
+		builder = builder.Set("priority", *req.Priority)
+	}
+
+	if req.IsActive != nil {
+		builder = builder.Set("is_active", *req.IsActive)
+	}
+
+	builder = builder.Suffix("RETURNING *")
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return FAQRule{}, err
+	}
+
+	err = r.db.QueryRowxContext(ctx, query, args...).StructScan(&rule)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rule, ErrFAQRuleNotFound
+	}
+
+	return rule, err
+}
+
+func (r *postgresRepo) DeleteFAQRule(ctx context.Context, id int) error {
+	query := `DELETE FROM faq_rules WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrFAQRuleNotFound
+	}
+
+	return nil
+}
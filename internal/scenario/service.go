@@ -2,59 +2,136 @@ package scenario
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/AzizovHikmatullo/j-support/internal/activity_log"
+	"github.com/AzizovHikmatullo/j-support/internal/categories"
+	"github.com/AzizovHikmatullo/j-support/internal/contacts"
+	"github.com/AzizovHikmatullo/j-support/internal/customfields"
+	"github.com/AzizovHikmatullo/j-support/internal/tags"
 	"github.com/AzizovHikmatullo/j-support/internal/tickets"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type Repository interface {
-	CreateScenario(ctx context.Context, categoryID int) (Scenario, error)
+	CreateScenario(ctx context.Context, tx *sqlx.Tx, categoryID int, isActive bool) (Scenario, error)
+	CloneScenario(ctx context.Context, tx *sqlx.Tx, sourceID, categoryID int) (Scenario, error)
 	GetByID(ctx context.Context, id int) (Scenario, error)
 	GetAll(ctx context.Context) ([]Scenario, error)
 	Update(ctx context.Context, scenarioID int, req UpdateScenarioRequest) (Scenario, error)
+	UpdateCommands(ctx context.Context, scenarioID int, commands *Commands) (Scenario, error)
+	UpdateTimeout(ctx context.Context, scenarioID int, timeout *Timeout) (Scenario, error)
+	UpdateTargeting(ctx context.Context, scenarioID int, targeting *Targeting) (Scenario, error)
+	UpdateSchedule(ctx context.Context, scenarioID int, schedule *Schedule) (Scenario, error)
+	GetScheduledScenarios(ctx context.Context) ([]Scenario, error)
+	SetScheduleActive(ctx context.Context, scenarioID int, active bool) error
 	Delete(ctx context.Context, id int) error
-	GetActiveScenario(ctx context.Context, categoryID int) (Scenario, error)
-
-	CreateStep(ctx context.Context, scenarioID int, req CreateStepRequest) (Step, error)
-	GetAllSteps(ctx context.Context, scenarioID int) ([]Step, error)
+	GetActiveScenarios(ctx context.Context, categoryID int) ([]Scenario, error)
+
+	BeginTxx(ctx context.Context) (*sqlx.Tx, error)
+	GetVersions(ctx context.Context, scenarioID int) ([]Version, error)
+	GetVersion(ctx context.Context, versionID int) (Version, error)
+	GetDraft(ctx context.Context, scenarioID int) (Version, error)
+	CreateDraft(ctx context.Context, tx *sqlx.Tx, scenarioID int) (Version, error)
+	CopySteps(ctx context.Context, tx *sqlx.Tx, fromVersionID int, to Version) error
+	Publish(ctx context.Context, tx *sqlx.Tx, scenarioID, versionID int) (Version, error)
+	DeleteDraft(ctx context.Context, tx *sqlx.Tx, scenarioID int) error
+	DeleteVersion(ctx context.Context, versionID int) error
+
+	CreateStep(ctx context.Context, scenarioID, versionID int, req CreateStepRequest) (Step, error)
+	InsertStep(ctx context.Context, tx *sqlx.Tx, step Step) (Step, error)
+	SetGoto(ctx context.Context, tx *sqlx.Tx, stepID, gotoStepID int) error
+	GetAllSteps(ctx context.Context, versionID int) ([]Step, error)
 	GetStep(ctx context.Context, stepID int) (Step, error)
-	GetRootStep(ctx context.Context, scenarioID int) (Step, error)
+	GetRootStep(ctx context.Context, versionID int) (Step, error)
 	GetChildren(ctx context.Context, parentID int) ([]Step, error)
 	UpdateStep(ctx context.Context, stepID int, req UpdateStepRequest) (Step, error)
 	DeleteStep(ctx context.Context, stepID int) error
 
-	CreateSession(ctx context.Context, ticketID uuid.UUID, scenarioID, stepID int) error
+	CreateSession(ctx context.Context, session Session) error
 	GetSession(ctx context.Context, ticketID uuid.UUID) (Session, error)
 	GetInactiveSessions(ctx context.Context, cutoff time.Time) ([]Session, error)
-	UpdateSession(ctx context.Context, ticketID uuid.UUID, nextStepID int) error
+	UpdateSession(ctx context.Context, ticketID uuid.UUID, nextStepID int, callStack pq.Int64Array, jumps int) error
+	SetCallStack(ctx context.Context, ticketID uuid.UUID, callStack pq.Int64Array) error
+	SetVariable(ctx context.Context, ticketID uuid.UUID, name, value string) error
+	StepBack(ctx context.Context, ticketID uuid.UUID) (int, error)
+	ResetSession(ctx context.Context, ticketID uuid.UUID, stepID int, callStack pq.Int64Array) error
 	UpdateLastActivity(ctx context.Context, ticketID uuid.UUID) error
+	MarkReminded(ctx context.Context, ticketID uuid.UUID) error
+	SetLocale(ctx context.Context, ticketID uuid.UUID, locale string) error
+	SetSessionFAQ(ctx context.Context, ticketID uuid.UUID, ruleID *int) error
+
+	GetFAQRules(ctx context.Context, categoryID *int) ([]FAQRule, error)
+	GetActiveFAQRules(ctx context.Context, categoryID int) ([]FAQRule, error)
+	GetFAQRule(ctx context.Context, id int) (FAQRule, error)
+	CreateFAQRule(ctx context.Context, req CreateFAQRuleRequest) (FAQRule, error)
+	UpdateFAQRule(ctx context.Context, id int, req UpdateFAQRuleRequest) (FAQRule, error)
+	DeleteFAQRule(ctx context.Context, id int) error
+
+	RecordTransitions(ctx context.Context, transitions []Transition) error
+	GetFunnelSummary(ctx context.Context, scenarioID int, req AnalyticsRequest) (FunnelSummary, error)
+	GetStepStats(ctx context.Context, scenarioID int, req AnalyticsRequest) ([]StepStats, error)
+	GetBranchStats(ctx context.Context, scenarioID int, req AnalyticsRequest) ([]BranchStats, error)
+	GetExperimentStats(ctx context.Context, categoryID int, name string, req AnalyticsRequest) ([]VariantStats, error)
 }
 
 type service struct {
 	repo          Repository
 	ticketService tickets.Service
+	fieldRepo     customfields.Repository
+	tagRepo       tags.Repository
+	categoryRepo  categories.Repository
+	contactRepo   contacts.Repository
+	activityLog   activity_log.Service
+	webhooks      *webhookClient
 
 	logger *slog.Logger
 }
 
-func NewService(repo Repository, ticketService tickets.Service, logger *slog.Logger) Service {
+func NewService(repo Repository, ticketService tickets.Service, fieldRepo customfields.Repository, tagRepo tags.Repository, categoryRepo categories.Repository, contactRepo contacts.Repository, al activity_log.Service, webhooks *webhookClient, logger *slog.Logger) Service {
 	return &service{
 		repo:          repo,
 		ticketService: ticketService,
+		fieldRepo:     fieldRepo,
+		tagRepo:       tagRepo,
+		categoryRepo:  categoryRepo,
+		contactRepo:   contactRepo,
+		activityLog:   al,
+		webhooks:      webhooks,
 		logger:        logger,
 	}
 }
 
 func (s *service) CreateScenario(ctx context.Context, req CreateScenarioRequest) (Scenario, error) {
-	scenario, err := s.repo.CreateScenario(ctx, req.CategoryID)
+	tx, err := s.repo.BeginTxx(ctx)
+	if err != nil {
+		return Scenario{}, fmt.Errorf("create scenario: begin tx: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	scenario, err := s.repo.CreateScenario(ctx, tx, req.CategoryID, true)
 	if err != nil {
 		return Scenario{}, fmt.Errorf("create scenario: %w", err)
 	}
+
+	if err = tx.Commit(); err != nil {
+		return Scenario{}, fmt.Errorf("create scenario: tx commit: %w", err)
+	}
+
+	scenario.BotSteps = []StepNode{}
 	s.logger.Info("scenario created", "id", scenario.ID)
 	return scenario, nil
 }
@@ -65,13 +142,11 @@ func (s *service) GetByID(ctx context.Context, id int) (Scenario, error) {
 		return Scenario{}, fmt.Errorf("get scenario by id: %w", err)
 	}
 
-	steps, err := s.repo.GetAllSteps(ctx, id)
+	scenario.BotSteps, err = s.publishedTree(ctx, scenario)
 	if err != nil {
 		return Scenario{}, fmt.Errorf("get steps for scenario: %w", err)
 	}
 
-	scenario.BotSteps = buildTree(steps)
-
 	return scenario, nil
 }
 
@@ -82,32 +157,100 @@ func (s *service) GetAll(ctx context.Context) ([]Scenario, error) {
 	}
 
 	for i, sc := range scenarios {
-		steps, err := s.repo.GetAllSteps(ctx, sc.ID)
+		scenarios[i].BotSteps, err = s.publishedTree(ctx, sc)
 		if err != nil {
 			return nil, fmt.Errorf("get all steps: %w", err)
 		}
-		scenarios[i].BotSteps = buildTree(steps)
 	}
 
 	return scenarios, nil
 }
 
 func (s *service) Update(ctx context.Context, id int, req UpdateScenarioRequest) (Scenario, error) {
+	if req.Experiment != nil {
+		*req.Experiment = strings.TrimSpace(*req.Experiment)
+		if *req.Experiment != "" && !validKey(*req.Experiment) {
+			return Scenario{}, fmt.Errorf("%w: name must be latin letters, digits, '_', '-' or '.'", ErrInvalidExperiment)
+		}
+	}
+
+	if req.Weight != nil && (*req.Weight < 0 || *req.Weight > maxWeight) {
+		return Scenario{}, fmt.Errorf("%w: weight must be between 0 and %d", ErrInvalidExperiment, maxWeight)
+	}
+
+	if req.IsActive != nil && *req.IsActive {
+		report, err := s.Validate(ctx, id, versionPublished)
+		if err != nil {
+			return Scenario{}, err
+		}
+
+		if !report.Valid {
+			return Scenario{}, ValidationError{Report: report}
+		}
+	}
+
+	if req.IsActive != nil || req.Priority != nil || req.Experiment != nil {
+		scenario, err := s.repo.GetByID(ctx, id)
+		if err != nil {
+			return Scenario{}, fmt.Errorf("get scenario by id: %w", err)
+		}
+
+		if req.IsActive != nil {
+			scenario.IsActive = *req.IsActive
+		}
+		if req.Priority != nil {
+			scenario.Priority = *req.Priority
+		}
+		if req.Experiment != nil {
+			scenario.Experiment = nonEmpty(req.Experiment)
+		}
+
+		if err = s.checkScheduleConflicts(ctx, scenario); err != nil {
+			return Scenario{}, err
+		}
+	}
+
 	scenario, err := s.repo.Update(ctx, id, req)
 	if err != nil {
 		return Scenario{}, fmt.Errorf("update scenario: %w", err)
 	}
 
-	steps, err := s.repo.GetAllSteps(ctx, id)
+	scenario.BotSteps, err = s.publishedTree(ctx, scenario)
 	if err != nil {
 		return Scenario{}, fmt.Errorf("get all steps: %w", err)
 	}
 	s.logger.Info("scenario updated", "id", scenario.ID)
 
-	scenario.BotSteps = buildTree(steps)
 	return scenario, nil
 }
 
+func (s *service) GetCommands(ctx context.Context, scenarioID int) (Commands, error) {
+	scenario, err := s.repo.GetByID(ctx, scenarioID)
+	if err != nil {
+		return Commands{}, fmt.Errorf("get scenario by id: %w", err)
+	}
+
+	return scenarioCommands(scenario), nil
+}
+
+func (s *service) UpdateCommands(ctx context.Context, scenarioID int, commands *Commands) (Commands, error) {
+	if commands != nil {
+		normalized, err := normalizeCommands(*commands)
+		if err != nil {
+			return Commands{}, err
+		}
+		commands = &normalized
+	}
+
+	scenario, err := s.repo.UpdateCommands(ctx, scenarioID, commands)
+	if err != nil {
+		return Commands{}, fmt.Errorf("update commands: %w", err)
+	}
+
+	s.logger.Info("scenario commands updated", "id", scenarioID)
+	return scenarioCommands(scenario), nil
+}
+
 func (s *service) Delete(ctx context.Context, id int) error {
 	_, err := s.repo.GetByID(ctx, id)
 	if err != nil {
@@ -123,18 +266,121 @@ func (s *service) Delete(ctx context.Context, id int) error {
 }
 
 func (s *service) CreateStep(ctx context.Context, scenarioID int, req CreateStepRequest) (Step, error) {
-	_, err := s.repo.GetByID(ctx, scenarioID)
+	scenario, err := s.repo.GetByID(ctx, scenarioID)
 	if err != nil {
 		return Step{}, fmt.Errorf("get by id: %w", err)
 	}
 
-	if req.ParentID == nil {
-		_, err = s.repo.GetRootStep(ctx, scenarioID)
-		if err == nil {
-			return Step{}, ErrRootAlreadyExists
+	req.SetFields, err = s.validateSetFields(ctx, scenario.CategoryID, req.SetFields)
+	if err != nil {
+		return Step{}, err
+	}
+
+	if req.MatchMode == "" {
+		req.MatchMode = matchContains
+	}
+
+	if req.GotoMode == "" {
+		req.GotoMode = gotoJump
+	}
+
+	if req.GotoStepID == nil && req.Webhook == nil && strings.TrimSpace(req.Question) == "" {
+		return Step{}, ErrQuestionRequired
+	}
+
+	if req.Subflow && req.ParentID != nil {
+		return Step{}, ErrInvalidSubflow
+	}
+
+	if err = validateCondition(req.MatchMode, req.Condition); err != nil {
+		return Step{}, err
+	}
+
+	if err = validateThreshold(req.MatchThreshold); err != nil {
+		return Step{}, err
+	}
+
+	if req.ButtonLabel != nil && strings.TrimSpace(*req.ButtonLabel) == "" {
+		req.ButtonLabel = nil
+	}
+
+	req.Translations = normalizeTranslations(req.Translations)
+	if err = validateTranslations(req.Translations, req.MatchMode, req.Condition); err != nil {
+		return Step{}, err
+	}
+
+	if req.Timeout != nil {
+		timeout, err := normalizeTimeout(*req.Timeout)
+		if err != nil {
+			return Step{}, err
+		}
+		req.Timeout = &timeout
+	}
+
+	req.Variable = nonEmpty(req.Variable)
+	req.Validator = nonEmpty(req.Validator)
+	req.ValidatorPattern = nonEmpty(req.ValidatorPattern)
+	req.ErrorMessage = nonEmpty(req.ErrorMessage)
+
+	if err = validateCapture(req.Variable, req.Validator, req.ValidatorPattern); err != nil {
+		return Step{}, err
+	}
+
+	if req.Webhook != nil {
+		webhook, err := s.webhooks.normalize(*req.Webhook)
+		if err != nil {
+			return Step{}, err
+		}
+		req.Webhook = &webhook
+	}
+
+	if err = validateWebhook(Step{Variable: req.Variable, GotoStepID: req.GotoStepID, Webhook: req.Webhook}, nil); err != nil {
+		return Step{}, err
+	}
+
+	if err = s.checkActions(ctx, scenario.CategoryID, req.Actions); err != nil {
+		return Step{}, err
+	}
+
+	draft, err := s.ensureDraft(ctx, scenario)
+	if err != nil {
+		return Step{}, fmt.Errorf("ensure draft: %w", err)
+	}
+
+	if req.Key != nil {
+		if err = s.checkKey(ctx, draft.ID, 0, *req.Key); err != nil {
+			return Step{}, err
 		}
-		if !errors.Is(err, ErrStepNotFound) {
-			return Step{}, ErrStepNotFound
+	}
+
+	steps, err := s.versionSteps(ctx, draft.ID)
+	if err != nil {
+		return Step{}, err
+	}
+
+	err = validateGoto(Step{
+		VersionID:  draft.ID,
+		Variable:   req.Variable,
+		GotoStepID: req.GotoStepID,
+		GotoMode:   req.GotoMode,
+	}, nil, steps)
+	if err != nil {
+		return Step{}, err
+	}
+
+	if err = checkTimeoutGoto(req.Timeout, steps); err != nil {
+		return Step{}, err
+	}
+
+	if req.ParentID == nil {
+		if !req.Subflow {
+			_, err = s.repo.GetRootStep(ctx, draft.ID)
+			if err == nil {
+				return Step{}, ErrRootAlreadyExists
+			}
+			if !errors.Is(err, ErrStepNotFound) {
+				return Step{}, ErrStepNotFound
+			}
 		}
 	} else {
 		parent, err := s.repo.GetStep(ctx, *req.ParentID)
@@ -146,21 +392,35 @@ func (s *service) CreateStep(ctx context.Context, scenarioID int, req CreateStep
 			return Step{}, ErrWrongScenario
 		}
 
-		if req.Condition == nil {
-			children, err := s.repo.GetChildren(ctx, *req.ParentID)
-			if err != nil {
-				return Step{}, fmt.Errorf("get children: %w", err)
-			}
+		if parent.VersionID != draft.ID {
+			return Step{}, ErrStepNotInDraft
+		}
+
+		children, err := s.repo.GetChildren(ctx, *req.ParentID)
+		if err != nil {
+			return Step{}, fmt.Errorf("get children: %w", err)
+		}
 
+		if req.Condition == nil {
 			for _, ch := range children {
 				if ch.Condition == nil {
 					return Step{}, ErrDefaultAlreadyExists
 				}
 			}
 		}
+
+		siblings := append(children, Step{Condition: req.Condition})
+
+		if err = validateGoto(parent, siblings, steps); err != nil {
+			return Step{}, err
+		}
+
+		if err = validateWebhook(parent, siblings); err != nil {
+			return Step{}, err
+		}
 	}
 
-	step, err := s.repo.CreateStep(ctx, scenarioID, req)
+	step, err := s.repo.CreateStep(ctx, scenarioID, draft.ID, req)
 	if err != nil {
 		return Step{}, fmt.Errorf("create step: %w", err)
 	}
@@ -168,167 +428,1569 @@ func (s *service) CreateStep(ctx context.Context, scenarioID int, req CreateStep
 	return step, nil
 }
 
-func (s *service) GetButtonsForCurrentStep(ctx context.Context, ticketID uuid.UUID) ([]string, error) {
-	session, err := s.repo.GetSession(ctx, ticketID)
-	if err != nil {
-		return nil, fmt.Errorf("get session: %w", err)
+func (s *service) GetVersions(ctx context.Context, scenarioID int) ([]Version, error) {
+	if _, err := s.repo.GetByID(ctx, scenarioID); err != nil {
+		return nil, fmt.Errorf("get scenario by id: %w", err)
 	}
 
-	children, err := s.repo.GetChildren(ctx, session.CurrentStepID)
+	versions, err := s.repo.GetVersions(ctx, scenarioID)
 	if err != nil {
-		return nil, fmt.Errorf("get children: %w", err)
+		return nil, fmt.Errorf("get versions: %w", err)
 	}
 
-	var buttons []string
-	for _, ch := range children {
-		if ch.Condition != nil && *ch.Condition != "" {
-			buttons = append(buttons, *ch.Condition)
-		}
-	}
-	return buttons, nil
+	return versions, nil
 }
 
-func (s *service) UpdateStep(ctx context.Context, scenarioID, stepID int, req UpdateStepRequest) (Step, error) {
-	step, err := s.repo.GetStep(ctx, stepID)
+func (s *service) GetVersion(ctx context.Context, scenarioID, versionID int) (Version, error) {
+	version, err := s.repo.GetVersion(ctx, versionID)
 	if err != nil {
-		return Step{}, fmt.Errorf("update step: get step: %w", err)
+		return Version{}, fmt.Errorf("get version: %w", err)
 	}
 
-	if step.ScenarioID != scenarioID {
-		return Step{}, ErrWrongScenario
+	if version.ScenarioID != scenarioID {
+		return Version{}, ErrVersionNotFound
 	}
 
-	if req.Condition == nil && step.ParentID != nil {
-		children, err := s.repo.GetChildren(ctx, *step.ParentID)
-		if err != nil {
-			return Step{}, fmt.Errorf("get children: %w", err)
-		}
-		for _, ch := range children {
-			if ch.Condition == nil && ch.ID != stepID {
-				return Step{}, ErrDefaultAlreadyExists
-			}
-		}
-	}
+	return s.withTree(ctx, version)
+}
 
-	updatedStep, err := s.repo.UpdateStep(ctx, stepID, req)
+func (s *service) GetDraft(ctx context.Context, scenarioID int) (Version, error) {
+	draft, err := s.repo.GetDraft(ctx, scenarioID)
 	if err != nil {
-		return Step{}, fmt.Errorf("update step: %w", err)
+		return Version{}, fmt.Errorf("get draft: %w", err)
 	}
-	s.logger.Info("step updated", "id", updatedStep.ID)
-	return updatedStep, nil
+
+	return s.withTree(ctx, draft)
 }
 
-func (s *service) DeleteStep(ctx context.Context, scenarioID, stepID int) error {
-	step, err := s.repo.GetStep(ctx, stepID)
+func (s *service) CreateDraft(ctx context.Context, scenarioID int) (Version, error) {
+	scenario, err := s.repo.GetByID(ctx, scenarioID)
 	if err != nil {
-		return fmt.Errorf("delete step: get step: %w", err)
+		return Version{}, fmt.Errorf("get scenario by id: %w", err)
 	}
 
-	if step.ScenarioID != scenarioID {
-		return ErrWrongScenario
+	draft, err := s.ensureDraft(ctx, scenario)
+	if err != nil {
+		return Version{}, fmt.Errorf("ensure draft: %w", err)
 	}
 
-	err = s.repo.DeleteStep(ctx, stepID)
+	return s.withTree(ctx, draft)
+}
+
+func (s *service) DiscardDraft(ctx context.Context, scenarioID int) error {
+	draft, err := s.repo.GetDraft(ctx, scenarioID)
 	if err != nil {
-		return fmt.Errorf("delete step: %w", err)
+		return fmt.Errorf("get draft: %w", err)
 	}
-	s.logger.Info("step deleted", "id", stepID)
+
+	if err = s.repo.DeleteVersion(ctx, draft.ID); err != nil {
+		return fmt.Errorf("delete draft: %w", err)
+	}
+
+	s.logger.Info("scenario draft discarded", "scenario id", scenarioID, "version id", draft.ID)
 	return nil
 }
 
-func (s *service) StartIfExists(ctx context.Context, ticketID uuid.UUID, categoryID int) (*tickets.Message, []string, error) {
-	scenario, err := s.repo.GetActiveScenario(ctx, categoryID)
-	if errors.Is(err, ErrScenarioNotFound) {
-		return nil, nil, s.ticketService.ChangeStatus(ctx, 0, "bot", ticketID, "open")
+func (s *service) Publish(ctx context.Context, scenarioID int) (Version, error) {
+	draft, err := s.repo.GetDraft(ctx, scenarioID)
+	if err != nil {
+		return Version{}, fmt.Errorf("get draft: %w", err)
 	}
 
+	steps, err := s.repo.GetAllSteps(ctx, draft.ID)
 	if err != nil {
-		return nil, nil, fmt.Errorf("scenario start: %w", err)
+		return Version{}, fmt.Errorf("get draft steps: %w", err)
 	}
 
-	rootStep, err := s.repo.GetRootStep(ctx, scenario.ID)
-	if errors.Is(err, ErrStepNotFound) {
-		return nil, nil, s.ticketService.ChangeStatus(ctx, 0, "bot", ticketID, "open")
+	if len(steps) == 0 {
+		return Version{}, ErrEmptyDraft
+	}
+
+	if report := validateSteps(steps); !report.Valid {
+		report.ScenarioID, report.VersionID = scenarioID, draft.ID
+		return Version{}, ValidationError{Report: report}
 	}
 
+	version, err := s.publish(ctx, scenarioID, draft.ID)
 	if err != nil {
-		return nil, nil, fmt.Errorf("get root step: %w", err)
+		return Version{}, err
 	}
 
-	err = s.repo.CreateSession(ctx, ticketID, scenario.ID, rootStep.ID)
+	version.BotSteps = buildTree(steps)
+
+	s.logger.Info("scenario published", "scenario id", scenarioID, "version", version.Number)
+	return version, nil
+}
+
+func (s *service) Rollback(ctx context.Context, scenarioID, versionID int) (Version, error) {
+	version, err := s.repo.GetVersion(ctx, versionID)
 	if err != nil {
-		return nil, nil, fmt.Errorf("create sessoin: %w", err)
+		return Version{}, fmt.Errorf("get version: %w", err)
 	}
 
-	if err := s.ticketService.ChangeStatus(ctx, 0, "bot", ticketID, "pending"); err != nil {
-		return nil, nil, err
+	if version.ScenarioID != scenarioID {
+		return Version{}, ErrVersionNotFound
 	}
 
-	buttons, err := s.GetButtonsForCurrentStep(ctx, ticketID)
+	if version.Status != versionArchived {
+		return Version{}, ErrNotArchived
+	}
+
+	// Validation rules may have been added since the version was published.
+	steps, err := s.repo.GetAllSteps(ctx, versionID)
 	if err != nil {
-		return nil, nil, fmt.Errorf("get button for current step: %w", err)
+		return Version{}, fmt.Errorf("get version steps: %w", err)
+	}
+
+	if report := validateSteps(steps); !report.Valid {
+		report.ScenarioID, report.VersionID = scenarioID, versionID
+		return Version{}, ValidationError{Report: report}
 	}
 
-	msg, err := s.ticketService.CreateMessageWithButtons(ctx, ticketID, 0, "bot", rootStep.Question, buttons)
+	version, err = s.publish(ctx, scenarioID, versionID)
 	if err != nil {
-		return nil, nil, err
+		return Version{}, err
 	}
-	s.logger.Info("scenario started", "ticket id", ticketID.String())
 
-	return msg, buttons, nil
+	s.logger.Info("scenario rolled back", "scenario id", scenarioID, "version", version.Number)
+	return s.withTree(ctx, version)
 }
 
-func (s *service) HandleMessage(ctx context.Context, ticketID uuid.UUID, answer string) (*string, error) {
-	session, err := s.repo.GetSession(ctx, ticketID)
+func (s *service) publish(ctx context.Context, scenarioID, versionID int) (Version, error) {
+	tx, err := s.repo.BeginTxx(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("get session: %w", err)
+		return Version{}, fmt.Errorf("publish: begin tx: %w", err)
 	}
 
-	if err = s.repo.UpdateLastActivity(ctx, ticketID); err != nil {
-		return nil, fmt.Errorf("update last activity: %w", err)
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	version, err := s.repo.Publish(ctx, tx, scenarioID, versionID)
+	if err != nil {
+		return Version{}, fmt.Errorf("publish version: %w", err)
 	}
 
-	children, err := s.repo.GetChildren(ctx, session.CurrentStepID)
+	if err = tx.Commit(); err != nil {
+		return Version{}, fmt.Errorf("publish: tx commit: %w", err)
+	}
+
+	return version, nil
+}
+
+func (s *service) ensureDraft(ctx context.Context, scenario Scenario) (Version, error) {
+	draft, err := s.repo.GetDraft(ctx, scenario.ID)
+	if err == nil || !errors.Is(err, ErrDraftNotFound) {
+		return draft, err
+	}
+
+	tx, err := s.repo.BeginTxx(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("get children: %w", err)
+		return Version{}, fmt.Errorf("create draft: begin tx: %w", err)
 	}
 
-	next := findNext(children, answer)
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
 
-	if next == nil {
-		return nil, s.ticketService.ChangeStatus(ctx, 0, "bot", ticketID, "open")
+	draft, err = s.repo.CreateDraft(ctx, tx, scenario.ID)
+	if err != nil {
+		return Version{}, fmt.Errorf("create draft: %w", err)
 	}
 
-	if err := s.repo.UpdateSession(ctx, ticketID, next.ID); err != nil {
-		return nil, fmt.Errorf("update session: %w", err)
+	if scenario.PublishedVersionID != nil {
+		if err = s.repo.CopySteps(ctx, tx, *scenario.PublishedVersionID, draft); err != nil {
+			return Version{}, fmt.Errorf("copy steps: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return Version{}, fmt.Errorf("create draft: tx commit: %w", err)
+	}
+
+	s.logger.Info("scenario draft created", "scenario id", scenario.ID, "version", draft.Number)
+	return draft, nil
+}
+
+func (s *service) checkKey(ctx context.Context, versionID, stepID int, key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
 	}
 
-	nextChildren, err := s.repo.GetChildren(ctx, next.ID)
+	steps, err := s.repo.GetAllSteps(ctx, versionID)
 	if err != nil {
-		return nil, fmt.Errorf("get next children: %w", err)
+		return fmt.Errorf("get steps: %w", err)
 	}
 
-	if len(nextChildren) == 0 {
-		if err := s.ticketService.ChangeStatus(ctx, 0, "bot", ticketID, "open"); err != nil {
-			return nil, err
+	for _, step := range steps {
+		if step.ID != stepID && step.Key != nil && *step.Key == key {
+			return ErrKeyExists
 		}
 	}
 
-	return &next.Question, nil
+	return nil
 }
 
-func findNext(children []Step, answer string) *Step {
-	var defaultStep *Step
-	for i, ch := range children {
-		if ch.Condition == nil {
-			defaultStep = &children[i]
-			continue
+func (s *service) checkStepGraph(ctx context.Context, step Step, req UpdateStepRequest) error {
+	updated := step
+	updated.Variable = updatedValue(step.Variable, req.Variable)
+	if req.GotoStepID != nil {
+		updated.GotoStepID = req.GotoStepID
+		if *req.GotoStepID == 0 {
+			updated.GotoStepID = nil
+		}
+	}
+	if req.GotoMode != nil {
+		updated.GotoMode = *req.GotoMode
+	}
+	if req.Question != nil {
+		updated.Question = *req.Question
+	}
+	if req.Webhook != nil {
+		updated.Webhook = req.Webhook
+		if req.Webhook.URL == "" {
+			updated.Webhook = nil
+		}
+	}
+
+	if updated.GotoStepID == nil && updated.Webhook == nil && strings.TrimSpace(updated.Question) == "" {
+		return ErrQuestionRequired
+	}
+
+	steps, err := s.versionSteps(ctx, step.VersionID)
+	if err != nil {
+		return err
+	}
+
+	children, err := s.repo.GetChildren(ctx, step.ID)
+	if err != nil {
+		return fmt.Errorf("get children: %w", err)
+	}
+
+	if err = validateGoto(updated, children, steps); err != nil {
+		return err
+	}
+
+	if err = validateWebhook(updated, children); err != nil {
+		return err
+	}
+
+	if req.Condition != nil && step.ParentID != nil {
+		parent, ok := steps[*step.ParentID]
+		if ok && parent.GotoStepID != nil && parent.GotoMode == gotoCall {
+			return fmt.Errorf("%w: call step can only have a default child", ErrInvalidGoto)
 		}
-		if strings.Contains(strings.ToLower(answer), strings.ToLower(*ch.Condition)) {
-			return &children[i]
+
+		if ok {
+			if err = validateWebhook(*parent, []Step{{Condition: req.Condition}}); err != nil {
+				return err
+			}
 		}
 	}
-	return defaultStep
+
+	return nil
+}
+
+func (s *service) versionSteps(ctx context.Context, versionID int) (map[int]*Step, error) {
+	steps, err := s.repo.GetAllSteps(ctx, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("get steps: %w", err)
+	}
+
+	return newGraph(steps).steps, nil
+}
+
+func (s *service) checkDraftStep(ctx context.Context, step Step) error {
+	draft, err := s.repo.GetDraft(ctx, step.ScenarioID)
+	if errors.Is(err, ErrDraftNotFound) {
+		return ErrStepNotInDraft
+	}
+	if err != nil {
+		return fmt.Errorf("get draft: %w", err)
+	}
+
+	if step.VersionID != draft.ID {
+		return ErrStepNotInDraft
+	}
+
+	return nil
+}
+
+func (s *service) publishedTree(ctx context.Context, scenario Scenario) ([]StepNode, error) {
+	if scenario.PublishedVersionID == nil {
+		return []StepNode{}, nil
+	}
+
+	steps, err := s.repo.GetAllSteps(ctx, *scenario.PublishedVersionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildTree(steps), nil
+}
+
+func (s *service) withTree(ctx context.Context, version Version) (Version, error) {
+	steps, err := s.repo.GetAllSteps(ctx, version.ID)
+	if err != nil {
+		return Version{}, fmt.Errorf("get version steps: %w", err)
+	}
+
+	version.BotSteps = buildTree(steps)
+	return version, nil
+}
+
+func (s *service) Export(ctx context.Context, scenarioID int, version string) (Document, error) {
+	scenario, err := s.repo.GetByID(ctx, scenarioID)
+	if err != nil {
+		return Document{}, fmt.Errorf("get scenario by id: %w", err)
+	}
+
+	versionID, err := s.resolveVersion(ctx, scenario, version)
+	if err != nil {
+		return Document{}, err
+	}
+
+	steps, err := s.repo.GetAllSteps(ctx, versionID)
+	if err != nil {
+		return Document{}, fmt.Errorf("get steps: %w", err)
+	}
+
+	return buildDocument(scenario.CategoryID, steps), nil
+}
+
+// resolveVersion maps "published" (or empty), "draft" or a version ID to a
+// version of the scenario.
+func (s *service) resolveVersion(ctx context.Context, scenario Scenario, version string) (int, error) {
+	switch version {
+	case "", versionPublished:
+		if scenario.PublishedVersionID == nil {
+			return 0, ErrVersionNotFound
+		}
+		return *scenario.PublishedVersionID, nil
+	case versionDraft:
+		draft, err := s.repo.GetDraft(ctx, scenario.ID)
+		if err != nil {
+			return 0, fmt.Errorf("get draft: %w", err)
+		}
+		return draft.ID, nil
+	default:
+		id, err := strconv.Atoi(version)
+		if err != nil {
+			return 0, ErrVersionNotFound
+		}
+
+		v, err := s.repo.GetVersion(ctx, id)
+		if err != nil {
+			return 0, fmt.Errorf("get version: %w", err)
+		}
+		if v.ScenarioID != scenario.ID {
+			return 0, ErrVersionNotFound
+		}
+		return v.ID, nil
+	}
+}
+
+// A scenario without a published version gets a report with a single error, so
+// it can be checked before activation the same way.
+func (s *service) Validate(ctx context.Context, scenarioID int, version string) (ValidationReport, error) {
+	scenario, err := s.repo.GetByID(ctx, scenarioID)
+	if err != nil {
+		return ValidationReport{}, fmt.Errorf("get scenario by id: %w", err)
+	}
+
+	if (version == "" || version == versionPublished) && scenario.PublishedVersionID == nil {
+		return ValidationReport{
+			ScenarioID: scenarioID,
+			Errors:     []ValidationIssue{{Code: issueNoVersion, Message: "scenario has no published version"}},
+			Warnings:   []ValidationIssue{},
+		}, nil
+	}
+
+	versionID, err := s.resolveVersion(ctx, scenario, version)
+	if err != nil {
+		return ValidationReport{}, err
+	}
+
+	steps, err := s.repo.GetAllSteps(ctx, versionID)
+	if err != nil {
+		return ValidationReport{}, fmt.Errorf("get steps: %w", err)
+	}
+
+	report := validateSteps(steps)
+	report.ScenarioID, report.VersionID = scenarioID, versionID
+
+	if err = checkTimeoutGoto(scenario.Timeout, newGraph(steps).steps); err != nil {
+		report.addError(nil, issueInvalidTimeout, "scenario timeout: "+err.Error())
+		report.Valid = false
+	}
+
+	return report, nil
+}
+
+func (s *service) Simulate(ctx context.Context, scenarioID int, req SimulateRequest) (Simulation, error) {
+	if len(req.Answers) > maxSimulationAnswers {
+		return Simulation{}, ErrTooManyAnswers
+	}
+
+	scenario, err := s.repo.GetByID(ctx, scenarioID)
+	if err != nil {
+		return Simulation{}, fmt.Errorf("get scenario by id: %w", err)
+	}
+
+	versionID, err := s.resolveVersion(ctx, scenario, req.Version)
+	if err != nil {
+		return Simulation{}, err
+	}
+
+	locale := contacts.DefaultLocale
+	if req.Locale != "" {
+		normalized, ok := contacts.NormalizeLocale(req.Locale)
+		if !ok {
+			return Simulation{}, fmt.Errorf("%w: unknown locale %q", ErrInvalidTranslation, req.Locale)
+		}
+		locale = normalized
+	}
+
+	steps, err := s.repo.GetAllSteps(ctx, versionID)
+	if err != nil {
+		return Simulation{}, fmt.Errorf("get steps: %w", err)
+	}
+
+	webhook := webhookSuccess
+	if req.WebhookOutcome != "" {
+		if req.WebhookOutcome != webhookSuccess && req.WebhookOutcome != webhookFailure {
+			return Simulation{}, fmt.Errorf("%w: webhook_outcome must be %s or %s", ErrInvalidWebhook, webhookSuccess, webhookFailure)
+		}
+		webhook = req.WebhookOutcome
+	}
+
+	return simulate(scenarioID, versionID, steps, locale, webhook, scenarioCommands(scenario), req.Answers), nil
+}
+
+// Clone copies a version of the scenario into a new inactive scenario. Field
+// values and actions are checked against the target category, since its custom
+// fields may differ.
+func (s *service) Clone(ctx context.Context, scenarioID int, req CloneScenarioRequest) (Version, error) {
+	scenario, err := s.repo.GetByID(ctx, scenarioID)
+	if err != nil {
+		return Version{}, fmt.Errorf("get scenario by id: %w", err)
+	}
+
+	versionID, err := s.resolveVersion(ctx, scenario, req.Version)
+	if err != nil {
+		return Version{}, err
+	}
+
+	steps, err := s.repo.GetAllSteps(ctx, versionID)
+	if err != nil {
+		return Version{}, fmt.Errorf("get steps: %w", err)
+	}
+
+	if req.Publish {
+		if len(steps) == 0 {
+			return Version{}, ErrEmptyDraft
+		}
+
+		if report := validateSteps(steps); !report.Valid {
+			report.ScenarioID, report.VersionID = scenarioID, versionID
+			return Version{}, ValidationError{Report: report}
+		}
+	}
+
+	categoryID := scenario.CategoryID
+	if req.CategoryID != nil && *req.CategoryID != categoryID {
+		categoryID = *req.CategoryID
+
+		if _, err = s.categoryRepo.GetByID(ctx, categoryID); errors.Is(err, sql.ErrNoRows) {
+			return Version{}, ErrCategoryNotFound
+		} else if err != nil {
+			return Version{}, fmt.Errorf("get category by id: %w", err)
+		}
+
+		if err = s.checkCategorySteps(ctx, categoryID, steps); err != nil {
+			return Version{}, err
+		}
+	}
+
+	tx, err := s.repo.BeginTxx(ctx)
+	if err != nil {
+		return Version{}, fmt.Errorf("clone: begin tx: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	clone, err := s.repo.CloneScenario(ctx, tx, scenarioID, categoryID)
+	if err != nil {
+		return Version{}, fmt.Errorf("clone scenario: %w", err)
+	}
+
+	version, err := s.repo.CreateDraft(ctx, tx, clone.ID)
+	if err != nil {
+		return Version{}, fmt.Errorf("create draft: %w", err)
+	}
+
+	if err = s.repo.CopySteps(ctx, tx, versionID, version); err != nil {
+		return Version{}, fmt.Errorf("copy steps: %w", err)
+	}
+
+	if req.Publish {
+		version, err = s.repo.Publish(ctx, tx, clone.ID, version.ID)
+		if err != nil {
+			return Version{}, fmt.Errorf("publish version: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return Version{}, fmt.Errorf("clone: tx commit: %w", err)
+	}
+
+	s.logger.Info("scenario cloned", "id", scenarioID, "clone id", clone.ID, "category id", categoryID, "published", req.Publish)
+	return s.withTree(ctx, version)
+}
+
+func (s *service) checkCategorySteps(ctx context.Context, categoryID int, steps []Step) error {
+	for _, step := range steps {
+		if _, err := s.validateSetFields(ctx, categoryID, step.SetFields); err != nil {
+			return fmt.Errorf("step %d: %w", step.ID, err)
+		}
+
+		if err := s.checkActions(ctx, categoryID, step.Actions); err != nil {
+			return fmt.Errorf("step %d: %w", step.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// Import creates a new inactive scenario with the document tree as its draft,
+// or as its published version when publish is set.
+func (s *service) Import(ctx context.Context, doc Document, publish bool) (Version, error) {
+	if doc.CategoryID <= 0 {
+		return Version{}, DocumentError{{Path: "category_id", Message: "is required"}}
+	}
+
+	return s.importDocument(ctx, nil, doc, publish)
+}
+
+func (s *service) ImportInto(ctx context.Context, scenarioID int, doc Document, publish bool) (Version, error) {
+	scenario, err := s.repo.GetByID(ctx, scenarioID)
+	if err != nil {
+		return Version{}, fmt.Errorf("get scenario by id: %w", err)
+	}
+
+	return s.importDocument(ctx, &scenario, doc, publish)
+}
+
+func (s *service) importDocument(ctx context.Context, scenario *Scenario, doc Document, publish bool) (Version, error) {
+	categoryID := doc.CategoryID
+	if scenario != nil {
+		categoryID = scenario.CategoryID
+	}
+
+	definitions, err := s.fieldRepo.GetForCategory(ctx, categoryID)
+	if err != nil {
+		return Version{}, fmt.Errorf("get custom fields: %w", err)
+	}
+
+	checkAction := func(action *Action) error {
+		return s.checkAction(ctx, definitions, action)
+	}
+
+	if issues := validateDocument(doc, definitions, checkAction, s.webhooks.normalize); issues != nil {
+		return Version{}, issues
+	}
+
+	tx, err := s.repo.BeginTxx(ctx)
+	if err != nil {
+		return Version{}, fmt.Errorf("import: begin tx: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if scenario == nil {
+		var created Scenario
+		// An imported scenario starts inactive and is switched on through
+		// Update, which validates it and checks schedule conflicts.
+		created, err = s.repo.CreateScenario(ctx, tx, categoryID, false)
+		if err != nil {
+			return Version{}, fmt.Errorf("create scenario: %w", err)
+		}
+		scenario = &created
+	} else if err = s.repo.DeleteDraft(ctx, tx, scenario.ID); err != nil {
+		return Version{}, fmt.Errorf("delete draft: %w", err)
+	}
+
+	version, err := s.repo.CreateDraft(ctx, tx, scenario.ID)
+	if err != nil {
+		return Version{}, fmt.Errorf("create draft: %w", err)
+	}
+
+	ids := make(map[string]int)
+
+	var insert func(docStep *DocumentStep, parentID *int, subflow bool) error
+	insert = func(docStep *DocumentStep, parentID *int, subflow bool) error {
+		key := docStep.Key
+		step, err := s.repo.InsertStep(ctx, tx, Step{
+			ScenarioID:       scenario.ID,
+			VersionID:        version.ID,
+			ParentID:         parentID,
+			Key:              &key,
+			Condition:        docStep.Condition,
+			MatchMode:        docStep.MatchMode,
+			MatchThreshold:   docStep.MatchThreshold,
+			ButtonLabel:      docStep.ButtonLabel,
+			Variable:         docStep.Variable,
+			Validator:        docStep.Validator,
+			ValidatorPattern: docStep.ValidatorPattern,
+			ErrorMessage:     docStep.ErrorMessage,
+			Actions:          docStep.Actions,
+			Question:         docStep.Question,
+			SetFields:        docStep.SetFields,
+			GotoMode:         docStep.GotoMode,
+			Subflow:          subflow,
+			Timeout:          docStep.Timeout,
+			Translations:     docStep.Translations,
+			Webhook:          docStep.Webhook,
+		})
+		if err != nil {
+			return err
+		}
+		ids[key] = step.ID
+
+		for _, child := range docStep.Children {
+			if err := insert(child, &step.ID, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err = insert(doc.Root, nil, false); err != nil {
+		return Version{}, fmt.Errorf("insert steps: %w", err)
+	}
+
+	for _, subflow := range doc.Subflows {
+		if err = insert(subflow, nil, true); err != nil {
+			return Version{}, fmt.Errorf("insert sub-flows: %w", err)
+		}
+	}
+
+	// Goto targets are set once every step exists, since a step may jump to
+	// a step defined later in the document.
+	var gotoErr error
+	walkDocument(doc, func(docStep *DocumentStep) {
+		if gotoErr == nil && docStep.Goto != "" {
+			gotoErr = s.repo.SetGoto(ctx, tx, ids[docStep.Key], ids[docStep.Goto])
+		}
+	})
+	if err = gotoErr; err != nil {
+		return Version{}, fmt.Errorf("set goto: %w", err)
+	}
+
+	if publish {
+		version, err = s.repo.Publish(ctx, tx, scenario.ID, version.ID)
+		if err != nil {
+			return Version{}, fmt.Errorf("publish version: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return Version{}, fmt.Errorf("import: tx commit: %w", err)
+	}
+
+	s.logger.Info("scenario imported", "scenario id", scenario.ID, "version", version.Number, "published", publish)
+	return s.withTree(ctx, version)
+}
+
+func (s *service) GetButtonsForCurrentStep(ctx context.Context, ticketID uuid.UUID) ([]string, error) {
+	session, err := s.repo.GetSession(ctx, ticketID)
+	if err != nil {
+		return nil, fmt.Errorf("get session: %w", err)
+	}
+
+	children, err := s.repo.GetChildren(ctx, session.CurrentStepID)
+	if err != nil {
+		return nil, fmt.Errorf("get children: %w", err)
+	}
+
+	// After an FAQ answer the bot waits for the feedback on it.
+	if session.FAQRuleID != nil {
+		return faqButtonLabels(sessionLocale(session)), nil
+	}
+
+	buttons := stepButtons(localizeSteps(children, sessionLocale(session)))
+
+	step, err := s.repo.GetStep(ctx, session.CurrentStepID)
+	if err != nil {
+		return nil, fmt.Errorf("get step: %w", err)
+	}
+
+	// A leaf without a variable opens the ticket, so the bot is done there.
+	if len(children) == 0 && step.Variable == nil {
+		return buttons, nil
+	}
+
+	scenario, err := s.repo.GetByID(ctx, session.ScenarioID)
+	if err != nil {
+		return nil, fmt.Errorf("get scenario by id: %w", err)
+	}
+
+	return append(buttons, scenarioCommands(scenario).buttons(len(session.History) > 0)...), nil
+}
+
+func (s *service) UpdateStep(ctx context.Context, scenarioID, stepID int, req UpdateStepRequest) (Step, error) {
+	step, err := s.repo.GetStep(ctx, stepID)
+	if err != nil {
+		return Step{}, fmt.Errorf("update step: get step: %w", err)
+	}
+
+	if step.ScenarioID != scenarioID {
+		return Step{}, ErrWrongScenario
+	}
+
+	if err = s.checkDraftStep(ctx, step); err != nil {
+		return Step{}, err
+	}
+
+	if req.Key != nil {
+		if err = s.checkKey(ctx, step.VersionID, step.ID, *req.Key); err != nil {
+			return Step{}, err
+		}
+	}
+
+	if req.MatchMode != nil || req.Condition != nil {
+		mode, condition := step.MatchMode, step.Condition
+		if req.MatchMode != nil {
+			mode = *req.MatchMode
+		}
+		if req.Condition != nil {
+			condition = req.Condition
+		}
+
+		if err = validateCondition(mode, condition); err != nil {
+			return Step{}, err
+		}
+	}
+
+	if err = validateThreshold(req.MatchThreshold); err != nil {
+		return Step{}, err
+	}
+
+	if req.Translations != nil || req.MatchMode != nil || req.Condition != nil {
+		translations, mode, condition := step.Translations, step.MatchMode, step.Condition
+		// Empty translations in the request clear the stored ones.
+		if req.Translations != nil {
+			translations = normalizeTranslations(req.Translations)
+			req.Translations = translations
+			if req.Translations == nil {
+				req.Translations = Translations{}
+			}
+		}
+		if req.MatchMode != nil {
+			mode = *req.MatchMode
+		}
+		if req.Condition != nil {
+			condition = req.Condition
+		}
+
+		if err = validateTranslations(translations, mode, condition); err != nil {
+			return Step{}, err
+		}
+	}
+
+	if req.Variable != nil || req.Validator != nil || req.ValidatorPattern != nil {
+		err = validateCapture(
+			updatedValue(step.Variable, req.Variable),
+			updatedValue(step.Validator, req.Validator),
+			updatedValue(step.ValidatorPattern, req.ValidatorPattern),
+		)
+		if err != nil {
+			return Step{}, err
+		}
+	}
+
+	// A webhook with an empty URL removes the call.
+	if req.Webhook != nil && strings.TrimSpace(req.Webhook.URL) == "" {
+		req.Webhook = &Webhook{}
+	} else if req.Webhook != nil {
+		webhook, err := s.webhooks.normalize(*req.Webhook)
+		if err != nil {
+			return Step{}, err
+		}
+		req.Webhook = &webhook
+	}
+
+	if req.GotoStepID != nil || req.GotoMode != nil || req.Variable != nil || req.Question != nil || req.Condition != nil || req.Webhook != nil {
+		if err = s.checkStepGraph(ctx, step, req); err != nil {
+			return Step{}, err
+		}
+	}
+
+	// A timeout with zero "after" removes the step's own settings.
+	if req.Timeout != nil && req.Timeout.After != 0 {
+		timeout, err := normalizeTimeout(*req.Timeout)
+		if err != nil {
+			return Step{}, err
+		}
+		req.Timeout = &timeout
+
+		steps, err := s.versionSteps(ctx, step.VersionID)
+		if err != nil {
+			return Step{}, err
+		}
+
+		if err = checkTimeoutGoto(req.Timeout, steps); err != nil {
+			return Step{}, err
+		}
+	}
+
+	if req.SetFields != nil || req.Actions != nil {
+		scenario, err := s.repo.GetByID(ctx, scenarioID)
+		if err != nil {
+			return Step{}, fmt.Errorf("update step: get scenario: %w", err)
+		}
+
+		req.SetFields, err = s.validateSetFields(ctx, scenario.CategoryID, req.SetFields)
+		if err != nil {
+			return Step{}, err
+		}
+
+		if err = s.checkActions(ctx, scenario.CategoryID, req.Actions); err != nil {
+			return Step{}, err
+		}
+	}
+
+	if req.Condition == nil && step.ParentID != nil {
+		children, err := s.repo.GetChildren(ctx, *step.ParentID)
+		if err != nil {
+			return Step{}, fmt.Errorf("get children: %w", err)
+		}
+		for _, ch := range children {
+			if ch.Condition == nil && ch.ID != stepID {
+				return Step{}, ErrDefaultAlreadyExists
+			}
+		}
+	}
+
+	updatedStep, err := s.repo.UpdateStep(ctx, stepID, req)
+	if err != nil {
+		return Step{}, fmt.Errorf("update step: %w", err)
+	}
+	s.logger.Info("step updated", "id", updatedStep.ID)
+	return updatedStep, nil
+}
+
+func (s *service) DeleteStep(ctx context.Context, scenarioID, stepID int) error {
+	step, err := s.repo.GetStep(ctx, stepID)
+	if err != nil {
+		return fmt.Errorf("delete step: get step: %w", err)
+	}
+
+	if step.ScenarioID != scenarioID {
+		return ErrWrongScenario
+	}
+
+	if err = s.checkDraftStep(ctx, step); err != nil {
+		return err
+	}
+
+	err = s.repo.DeleteStep(ctx, stepID)
+	if err != nil {
+		return fmt.Errorf("delete step: %w", err)
+	}
+	s.logger.Info("step deleted", "id", stepID)
+	return nil
+}
+
+// Without a matching scenario the ticket goes straight to operators.
+func (s *service) StartIfExists(ctx context.Context, ticket tickets.Ticket, role string) (*tickets.Message, []string, error) {
+	ticketID := ticket.ID
+
+	scenario, err := s.selectScenario(ctx, ticket, role)
+	if errors.Is(err, ErrScenarioNotFound) {
+		return nil, nil, s.ticketService.ChangeStatus(ctx, 0, "bot", ticketID, "open")
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("scenario start: %w", err)
+	}
+
+	versionID := *scenario.PublishedVersionID
+
+	steps, err := s.repo.GetAllSteps(ctx, versionID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get steps: %w", err)
+	}
+
+	var locale *string
+	contact, err := s.contactRepo.GetByID(ctx, ticket.ContactID)
+	if err != nil {
+		s.logger.Error("failed to get contact locale", "ticket id", ticketID.String(), "error", err.Error())
+	} else {
+		locale = contactLocale(contact)
+	}
+
+	session := Session{
+		TicketID:   ticketID,
+		ScenarioID: scenario.ID,
+		VersionID:  versionID,
+		Variables:  Variables{},
+		Locale:     locale,
+	}
+
+	g := newGraph(localizeSteps(steps, sessionLocale(session)))
+	if g.root == nil {
+		return nil, nil, s.ticketService.ChangeStatus(ctx, 0, "bot", ticketID, "open")
+	}
+
+	stop := s.callWebhooks(ctx, g, ticketID, session.Variables)
+	defer stop()
+	res := g.resolve(g.root, nil, 0)
+
+	session.CurrentStepID = res.step.ID
+	session.CallStack = res.stack
+	session.Jumps = res.jumps
+
+	if err = s.repo.CreateSession(ctx, session); err != nil {
+		return nil, nil, fmt.Errorf("create sessoin: %w", err)
+	}
+
+	question, closes := s.enter(ctx, ticketID, res.path, session.Variables)
+
+	s.recordTransitions(ctx, session, append([]Transition{{Event: EventStart, ToStepID: &g.root.ID}}, g.moves(res)...))
+
+	if closes {
+		msg, err := s.closeWithAnswer(ctx, ticketID, question, session.Variables)
+		return msg, nil, err
+	}
+
+	if res.limited || res.failed {
+		if res.limited {
+			s.logLoopLimit(ctx, ticketID, res)
+		}
+		s.saveVariables(ctx, ticketID, session.Variables)
+
+		var msg *tickets.Message
+		if question != "" {
+			if msg, err = s.ticketService.CreateMessage(ctx, ticketID, 0, "bot", question); err != nil {
+				return nil, nil, err
+			}
+		}
+		return msg, nil, s.ticketService.ChangeStatus(ctx, 0, "bot", ticketID, "open")
+	}
+
+	if err := s.ticketService.ChangeStatus(ctx, 0, "bot", ticketID, "pending"); err != nil {
+		return nil, nil, err
+	}
+
+	buttons, err := s.GetButtonsForCurrentStep(ctx, ticketID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get button for current step: %w", err)
+	}
+
+	msg, err := s.ticketService.CreateMessageWithButtons(ctx, ticketID, 0, "bot", question, buttons)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.logger.Info("scenario started", "ticket id", ticketID.String(), "scenario id", scenario.ID)
+
+	return msg, buttons, nil
+}
+
+func (s *service) HandleMessage(ctx context.Context, ticketID uuid.UUID, answer string) (*string, error) {
+	session, err := s.repo.GetSession(ctx, ticketID)
+	if err != nil {
+		return nil, fmt.Errorf("get session: %w", err)
+	}
+
+	if err = s.repo.UpdateLastActivity(ctx, ticketID); err != nil {
+		return nil, fmt.Errorf("update last activity: %w", err)
+	}
+
+	scenario, err := s.repo.GetByID(ctx, session.ScenarioID)
+	if err != nil {
+		return nil, fmt.Errorf("get scenario by id: %w", err)
+	}
+
+	steps, err := s.repo.GetAllSteps(ctx, session.VersionID)
+	if err != nil {
+		return nil, fmt.Errorf("get steps: %w", err)
+	}
+
+	// Without a known locale the language of the first answer long enough to
+	// tell is kept for the session and the contact.
+	if session.Locale == nil {
+		if detected := detectLocale(answer); detected != "" {
+			if err = s.repo.SetLocale(ctx, ticketID, detected); err != nil {
+				s.logger.Error("failed to set session locale", "ticket id", ticketID.String(), "error", err.Error())
+			}
+			session.Locale = &detected
+		}
+	}
+
+	locale := sessionLocale(session)
+	g := newGraph(localizeSteps(steps, locale))
+
+	if session.FAQRuleID != nil {
+		if handled, err := s.faqFeedback(ctx, session, answer, locale); handled || err != nil {
+			return nil, err
+		}
+	}
+
+	commands := scenarioCommands(scenario)
+	if command := commands.detect(answer); command != "" {
+		return s.runCommand(ctx, session, g, commands, command)
+	}
+
+	current, ok := g.steps[session.CurrentStepID]
+	if !ok {
+		return nil, fmt.Errorf("get current step: %w", ErrStepNotFound)
+	}
+
+	// FAQ rules are checked before branching, except for the answers a step
+	// expects: a captured value or a pressed button of the step.
+	if current.Variable == nil && !isStepButton(g.children[current.ID], answer) {
+		if answered, err := s.answerFAQ(ctx, session, scenario.CategoryID, answer, locale); answered || err != nil {
+			return nil, err
+		}
+	}
+
+	if current.Variable != nil {
+		value, ok := capture(*current, answer)
+		if !ok {
+			message := errorMessage(*current, locale)
+			return &message, nil
+		}
+
+		if err = s.repo.SetVariable(ctx, ticketID, *current.Variable, value); err != nil {
+			return nil, fmt.Errorf("set variable: %w", err)
+		}
+
+		if session.Variables == nil {
+			session.Variables = Variables{}
+		}
+		session.Variables[*current.Variable] = value
+	}
+
+	children := g.children[current.ID]
+	stack := session.CallStack
+
+	found := findLocalized(children, baseChildren(steps, current.ID, locale), answer)
+
+	// A capture step at the end of a sub-flow returns to the caller.
+	if found.step == nil && len(children) == 0 && len(stack) > 0 {
+		found.step, stack = g.unwind(stack)
+		found.defaultUsed = true
+	}
+
+	s.logMatch(ctx, ticketID, session.CurrentStepID, answer, found)
+
+	if found.step == nil {
+		s.recordTransitions(ctx, session, []Transition{handoff(current.ID, openNoMatch, waited(session))})
+		s.saveVariables(ctx, ticketID, session.Variables)
+		return nil, s.ticketService.ChangeStatus(ctx, 0, "bot", ticketID, "open")
+	}
+
+	if session.Variables == nil {
+		session.Variables = Variables{}
+	}
+
+	stop := s.callWebhooks(ctx, g, ticketID, session.Variables)
+	defer stop()
+	res := g.resolve(found.step, stack, session.Jumps)
+
+	if err := s.repo.UpdateSession(ctx, ticketID, res.step.ID, res.stack, res.jumps); err != nil {
+		return nil, fmt.Errorf("update session: %w", err)
+	}
+
+	question, closes := s.enter(ctx, ticketID, res.path, session.Variables)
+
+	answered := Transition{
+		Event:       EventAnswer,
+		FromStepID:  &current.ID,
+		ToStepID:    &found.step.ID,
+		DefaultUsed: found.defaultUsed,
+		DurationMs:  waited(session),
+	}
+	if !found.defaultUsed {
+		answered.MatchedCondition = found.step.Condition
+	}
+	s.recordTransitions(ctx, session, append([]Transition{answered}, g.moves(res)...))
+
+	if closes {
+		_, err = s.closeWithAnswer(ctx, ticketID, question, session.Variables)
+		return nil, err
+	}
+
+	if res.limited || res.failed || g.isLeaf(res.step) {
+		if res.limited {
+			s.logLoopLimit(ctx, ticketID, res)
+		}
+
+		s.saveVariables(ctx, ticketID, session.Variables)
+		if err := s.ticketService.ChangeStatus(ctx, 0, "bot", ticketID, "open"); err != nil {
+			return nil, err
+		}
+	}
+
+	if question == "" {
+		return nil, nil
+	}
+
+	return &question, nil
+}
+
+func (s *service) enter(ctx context.Context, ticketID uuid.UUID, path []*Step, vars Variables) (string, bool) {
+	var questions []string
+
+	for _, step := range path {
+		s.applyStepFields(ctx, ticketID, *step)
+		closes := s.runActions(ctx, ticketID, *step, vars)
+
+		if question := interpolate(step.Question, vars); strings.TrimSpace(question) != "" {
+			questions = append(questions, question)
+		}
+
+		if closes {
+			return strings.Join(questions, "\n\n"), true
+		}
+	}
+
+	return strings.Join(questions, "\n\n"), false
+}
+
+func (s *service) logLoopLimit(ctx context.Context, ticketID uuid.UUID, res resolution) {
+	s.logger.Warn("scenario jump limit reached", "ticket id", ticketID.String(), "step id", res.step.ID, "jumps", res.jumps)
+
+	s.activityLog.Log(ctx, activity_log.LogEntry{
+		TicketID:  ticketID,
+		ActorID:   0,
+		ActorType: "bot",
+		Action:    activity_log.ActionBotLoopLimit,
+		Payload:   activity_log.Payload{"step_id": res.step.ID, "jumps": res.jumps},
+	})
+}
+
+// Going back or restarting only moves the session: fields and actions of the
+// step are not applied again.
+func (s *service) runCommand(ctx context.Context, session Session, g *graph, commands Commands, command string) (*string, error) {
+	ticketID := session.TicketID
+
+	s.activityLog.Log(ctx, activity_log.LogEntry{
+		TicketID:  ticketID,
+		ActorID:   0,
+		ActorType: "bot",
+		Action:    activity_log.ActionBotCommand,
+		Payload:   activity_log.Payload{"command": command, "step_id": session.CurrentStepID},
+	})
+
+	var (
+		path []*Step
+		err  error
+	)
+
+	switch command {
+	case commandOperator:
+		s.recordTransitions(ctx, session, []Transition{handoff(session.CurrentStepID, openOperator, waited(session))})
+		s.saveVariables(ctx, ticketID, session.Variables)
+
+		if commands.Operator.Reply != "" {
+			if _, err = s.ticketService.CreateMessage(ctx, ticketID, 0, "bot", commands.Operator.Reply); err != nil {
+				return nil, err
+			}
+		}
+
+		s.logger.Info("bot handed off to operator", "ticket id", ticketID.String())
+		return nil, s.ticketService.ChangeStatus(ctx, 0, "bot", ticketID, "open")
+
+	case commandBack:
+		stepID := session.CurrentStepID
+		if len(session.History) > 0 {
+			stepID, err = s.repo.StepBack(ctx, ticketID)
+			if err != nil {
+				return nil, fmt.Errorf("step back: %w", err)
+			}
+		}
+
+		step, ok := g.steps[stepID]
+		if !ok {
+			return nil, fmt.Errorf("get step: %w", ErrStepNotFound)
+		}
+
+		if stack := g.trimStack(session.CallStack, stepID); len(stack) != len(session.CallStack) {
+			if err = s.repo.SetCallStack(ctx, ticketID, stack); err != nil {
+				return nil, fmt.Errorf("set call stack: %w", err)
+			}
+		}
+		path = []*Step{step}
+
+		s.recordTransitions(ctx, session, []Transition{{
+			Event:      EventBack,
+			FromStepID: &session.CurrentStepID,
+			ToStepID:   &step.ID,
+			DurationMs: waited(session),
+		}})
+
+	case commandRestart:
+		if g.root == nil {
+			return nil, fmt.Errorf("get root step: %w", ErrStepNotFound)
+		}
+
+		session.Variables = Variables{}
+		stop := s.callWebhooks(ctx, g, ticketID, session.Variables)
+		defer stop()
+
+		res := g.resolve(g.root, nil, session.Jumps)
+		if err = s.repo.ResetSession(ctx, ticketID, res.step.ID, res.stack); err != nil {
+			return nil, fmt.Errorf("reset session: %w", err)
+		}
+		path = res.path
+
+		// Resetting the session drops the values the webhooks have just
+		// mapped, so they are stored again.
+		for name, value := range session.Variables {
+			if err = s.repo.SetVariable(ctx, ticketID, name, value); err != nil {
+				return nil, fmt.Errorf("set variable: %w", err)
+			}
+		}
+
+		s.recordTransitions(ctx, session, append([]Transition{{
+			Event:      EventRestart,
+			FromStepID: &session.CurrentStepID,
+			ToStepID:   &g.root.ID,
+			DurationMs: waited(session),
+		}}, hops(res.path)...))
+	}
+
+	var questions []string
+	for _, step := range path {
+		if question := interpolate(step.Question, session.Variables); strings.TrimSpace(question) != "" {
+			questions = append(questions, question)
+		}
+	}
+
+	question := strings.Join(questions, "\n\n")
+	return &question, nil
+}
+
+func (s *service) closeWithAnswer(ctx context.Context, ticketID uuid.UUID, answer string, vars Variables) (*tickets.Message, error) {
+	s.saveVariables(ctx, ticketID, vars)
+
+	msg, err := s.ticketService.CreateMessage(ctx, ticketID, 0, "bot", answer)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.ticketService.ChangeStatus(ctx, 0, "bot", ticketID, "closed"); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("ticket closed by scenario", "ticket id", ticketID.String())
+	return msg, nil
+}
+
+// saveVariables copies the collected variables to the ticket metadata when the
+// session ends.
+func (s *service) saveVariables(ctx context.Context, ticketID uuid.UUID, vars Variables) {
+	if len(vars) == 0 {
+		return
+	}
+
+	if _, err := s.ticketService.SaveVariables(ctx, ticketID, vars); err != nil {
+		s.logger.Error("failed to save session variables", "ticket id", ticketID.String(), "error", err.Error())
+	}
+}
+
+func (s *service) logMatch(ctx context.Context, ticketID uuid.UUID, fromStepID int, answer string, found match) {
+	payload := activity_log.Payload{
+		"from_step_id": fromStepID,
+		"answer":       answer,
+		"matched":      found.step != nil,
+		"default_used": found.defaultUsed,
+	}
+
+	if found.step != nil {
+		payload["step_id"] = found.step.ID
+		if !found.defaultUsed {
+			payload["condition"] = *found.step.Condition
+			payload["match_mode"] = found.step.MatchMode
+			payload["confidence"] = found.confidence
+		}
+	}
+
+	s.activityLog.Log(ctx, activity_log.LogEntry{
+		TicketID:  ticketID,
+		ActorID:   0,
+		ActorType: "bot",
+		Action:    activity_log.ActionBotMatched,
+		Payload:   payload,
+	})
+}
+
+// applyStepFields writes the step's field values to the ticket. A failure
+// here must not stop the conversation, so it is only logged.
+func (s *service) applyStepFields(ctx context.Context, ticketID uuid.UUID, step Step) {
+	if len(step.SetFields) == 0 {
+		return
+	}
+
+	if _, err := s.ticketService.UpdateFields(ctx, 0, "bot", ticketID, step.SetFields); err != nil {
+		s.logger.Error("failed to apply step fields", "ticket id", ticketID.String(), "step id", step.ID, "error", err.Error())
+	}
+}
+
+func (s *service) validateSetFields(ctx context.Context, categoryID int, values customfields.Values) (customfields.Values, error) {
+	if len(values) == 0 {
+		return values, nil
+	}
+
+	definitions, err := s.fieldRepo.GetForCategory(ctx, categoryID)
+	if err != nil {
+		return nil, fmt.Errorf("get custom fields: %w", err)
+	}
+
+	return customfields.Validate(definitions, values, true)
+}
+
+func nonEmpty(value *string) *string {
+	if value == nil || *value == "" {
+		return nil
+	}
+	return value
+}
+
+func updatedValue(current, update *string) *string {
+	if update == nil {
+		return current
+	}
+	return nonEmpty(update)
+}
+
+func stepButtons(children []Step) []string {
+	var buttons []string
+	for _, ch := range children {
+		if label := buttonLabel(ch); label != "" {
+			buttons = append(buttons, label)
+		}
+	}
+	return buttons
+}
+
+func simulate(scenarioID, versionID int, steps []Step, locale, webhook string, commands Commands, answers []string) Simulation {
+	vars := Variables{}
+
+	result := Simulation{
+		ScenarioID: scenarioID,
+		VersionID:  versionID,
+		Locale:     locale,
+		Transcript: []SimulationTurn{},
+		Variables:  vars,
+	}
+
+	g := newGraph(localizeSteps(steps, locale))
+
+	// Webhooks are not called: every call gets the requested result.
+	g.call = func(*Step) string { return webhook }
+
+	open := func(turn *SimulationTurn, answered int, reason string) {
+		result.TicketOpened = true
+		result.OpenedAfter = &answered
+		result.OpenReason = reason
+		if turn != nil {
+			turn.TicketOpened = true
+		}
+		result.UnusedAnswers = answers[answered:]
+	}
+
+	closeTicket := func(turn *SimulationTurn, answered int) {
+		result.TicketClosed = true
+		result.ClosedAfter = &answered
+		turn.TicketClosed = true
+		turn.Buttons = nil
+		result.UnusedAnswers = answers[answered:]
+	}
+
+	var history []*Step
+
+	buttons := func(step *Step) []string {
+		if g.isLeaf(step) {
+			return nil
+		}
+		return append(stepButtons(g.children[step.ID]), commands.buttons(len(history) > 0)...)
+	}
+
+	enter := func(turn *SimulationTurn, res resolution) bool {
+		var questions []string
+
+		for i, step := range res.path {
+			if i < len(res.path)-1 {
+				turn.Path = append(turn.Path, step.ID)
+			}
+			if question := interpolate(step.Question, vars); strings.TrimSpace(question) != "" {
+				questions = append(questions, question)
+			}
+			for key, value := range step.SetFields {
+				if turn.SetFields == nil {
+					turn.SetFields = customfields.Values{}
+				}
+				turn.SetFields[key] = value
+			}
+			turn.Actions = append(turn.Actions, step.Actions...)
+
+			if step.Actions.closes() {
+				break
+			}
+		}
+
+		turn.StepID = &res.step.ID
+		turn.StepKey = res.step.Key
+		turn.Question = strings.Join(questions, "\n\n")
+		turn.Buttons = buttons(res.step)
+
+		return turn.Actions.closes()
+	}
+
+	if g.root == nil {
+		open(nil, 0, openNoRoot)
+		return result
+	}
+
+	res := g.resolve(g.root, nil, 0)
+	current, stack, jumps := res.step, res.stack, res.jumps
+
+	result.Transcript = append(result.Transcript, SimulationTurn{})
+	if enter(&result.Transcript[0], res) {
+		closeTicket(&result.Transcript[0], 0)
+		return result
+	}
+
+	if res.limited {
+		open(&result.Transcript[0], 0, openLoopLimit)
+		return result
+	}
+
+	if res.failed {
+		open(&result.Transcript[0], 0, openWebhook)
+		return result
+	}
+
+	for i, answer := range answers {
+		turn := SimulationTurn{Answer: &answers[i]}
+
+		if command := commands.detect(answer); command != "" {
+			turn.Command = command
+
+			switch command {
+			case commandOperator:
+				turn.Question = commands.Operator.Reply
+				result.Transcript = append(result.Transcript, turn)
+				open(&result.Transcript[len(result.Transcript)-1], i+1, openOperator)
+				return result
+			case commandBack:
+				if len(history) > 0 {
+					current = history[len(history)-1]
+					history = history[:len(history)-1]
+					stack = g.trimStack(stack, current.ID)
+				}
+				turn.StepID = &current.ID
+				turn.StepKey = current.Key
+				turn.Question = interpolate(current.Question, vars)
+				turn.Buttons = buttons(current)
+			case commandRestart:
+				history = nil
+				clear(vars)
+
+				res := g.resolve(g.root, nil, jumps)
+				current, stack = res.step, res.stack
+				enter(&turn, res)
+				turn.SetFields = nil
+				turn.Actions = nil
+			}
+
+			result.Transcript = append(result.Transcript, turn)
+			continue
+		}
+
+		if current.Variable != nil {
+			value, ok := capture(*current, answer)
+			if !ok {
+				turn.InvalidInput = true
+				turn.StepID = &current.ID
+				turn.StepKey = current.Key
+				turn.Question = errorMessage(*current, locale)
+				turn.Buttons = buttons(current)
+				result.Transcript = append(result.Transcript, turn)
+				continue
+			}
+
+			vars[*current.Variable] = value
+			turn.Captured = Variables{*current.Variable: value}
+		}
+
+		children := g.children[current.ID]
+		found := findLocalized(children, baseChildren(steps, current.ID, locale), answer)
+		if found.step == nil && len(children) == 0 && len(stack) > 0 {
+			found.step, stack = g.unwind(stack)
+			found.defaultUsed = true
+		}
+
+		if found.step == nil {
+			reason := openNoMatch
+			if len(children) == 0 {
+				reason = openLeaf
+			}
+
+			result.Transcript = append(result.Transcript, turn)
+			open(&result.Transcript[len(result.Transcript)-1], i+1, reason)
+			return result
+		}
+
+		history = append(history, current)
+
+		turn.MatchedCondition = found.step.Condition
+		turn.Confidence = found.confidence
+		turn.DefaultUsed = found.defaultUsed
+
+		res := g.resolve(found.step, stack, jumps)
+		current, stack, jumps = res.step, res.stack, res.jumps
+		closes := enter(&turn, res)
+
+		result.Transcript = append(result.Transcript, turn)
+
+		if closes {
+			closeTicket(&result.Transcript[len(result.Transcript)-1], i+1)
+			return result
+		}
+
+		if res.limited {
+			open(&result.Transcript[len(result.Transcript)-1], i+1, openLoopLimit)
+			return result
+		}
+
+		if res.failed {
+			open(&result.Transcript[len(result.Transcript)-1], i+1, openWebhook)
+			return result
+		}
+
+		if g.isLeaf(current) {
+			open(&result.Transcript[len(result.Transcript)-1], i+1, openLeaf)
+			return result
+		}
+	}
+
+	return result
 }
 
 func buildTree(steps []Step) []StepNode {
@@ -0,0 +1,267 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AzizovHikmatullo/j-support/internal/channel"
+	"github.com/AzizovHikmatullo/j-support/internal/contacts"
+	"github.com/AzizovHikmatullo/j-support/internal/tickets"
+)
+
+const hoursLayout = "15:04"
+
+var channels = []string{
+	channel.ChannelUserApp,
+	channel.ChannelDriverApp,
+	channel.ChannelWeb,
+	channel.ChannelTelegram,
+	channel.ChannelInstagram,
+	channel.ChannelFacebook,
+}
+
+// The contact is only loaded when a scenario targets attributes.
+type audience struct {
+	source  string
+	role    string
+	contact *contacts.Contact
+}
+
+func normalizeTargeting(t Targeting, contactFields []string) (*Targeting, error) {
+	t.Channels = normalizeList(t.Channels)
+	t.Roles = normalizeList(t.Roles)
+
+	for _, ch := range t.Channels {
+		if !slices.Contains(channels, ch) {
+			return nil, fmt.Errorf("%w: unknown channel %q, must be one of %s", ErrInvalidTargeting, ch, strings.Join(channels, ", "))
+		}
+	}
+
+	attributes := make(map[string][]string, len(t.Attributes))
+	for key, values := range t.Attributes {
+		key = strings.TrimSpace(key)
+		if !slices.Contains(contactFields, key) {
+			return nil, fmt.Errorf("%w: unknown contact field %q", ErrInvalidTargeting, key)
+		}
+
+		values = normalizeList(values)
+		if len(values) == 0 {
+			return nil, fmt.Errorf("%w: attribute %q needs at least one value", ErrInvalidTargeting, key)
+		}
+		attributes[key] = values
+	}
+	t.Attributes = attributes
+
+	if t.Hours != nil {
+		if _, _, err := t.Hours.parse(); err != nil {
+			return nil, err
+		}
+		if _, err := t.Hours.location(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(t.Channels) == 0 && len(t.Roles) == 0 && len(t.Attributes) == 0 && t.Hours == nil {
+		return nil, nil
+	}
+
+	if len(t.Attributes) == 0 {
+		t.Attributes = nil
+	}
+
+	return &t, nil
+}
+
+func normalizeList(values []string) []string {
+	var list []string
+	for _, v := range values {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v != "" && !slices.Contains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func (h Hours) parse() (time.Duration, time.Duration, error) {
+	from, err := time.Parse(hoursLayout, h.From)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: hours.from must be HH:MM", ErrInvalidTargeting)
+	}
+
+	to, err := time.Parse(hoursLayout, h.To)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: hours.to must be HH:MM", ErrInvalidTargeting)
+	}
+
+	if from.Equal(to) {
+		return 0, 0, fmt.Errorf("%w: hours window is empty", ErrInvalidTargeting)
+	}
+
+	return sinceMidnight(from), sinceMidnight(to), nil
+}
+
+func (h Hours) location() (*time.Location, error) {
+	if h.Timezone == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(h.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidTargeting, h.Timezone)
+	}
+
+	return loc, nil
+}
+
+func (h Hours) contains(now time.Time) bool {
+	from, to, err := h.parse()
+	if err != nil {
+		return false
+	}
+
+	loc, err := h.location()
+	if err != nil {
+		return false
+	}
+
+	at := sinceMidnight(now.In(loc))
+	if from < to {
+		return at >= from && at < to
+	}
+	return at >= from || at < to
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+}
+
+func (t *Targeting) matches(a audience, now time.Time) bool {
+	if t == nil {
+		return true
+	}
+
+	if len(t.Channels) > 0 && !slices.Contains(t.Channels, strings.ToLower(a.source)) {
+		return false
+	}
+
+	if len(t.Roles) > 0 && !slices.Contains(t.Roles, strings.ToLower(a.role)) {
+		return false
+	}
+
+	for key, values := range t.Attributes {
+		if a.contact == nil {
+			return false
+		}
+
+		value, ok := a.contact.Metadata[key]
+		if !ok || value == nil || !slices.Contains(values, strings.ToLower(fmt.Sprint(value))) {
+			return false
+		}
+	}
+
+	if t.Hours != nil && !t.Hours.contains(now) {
+		return false
+	}
+
+	return true
+}
+
+// selectScenario picks the first active, published scenario of the category
+// whose schedule is open and whose targeting matches, in priority order.
+func (s *service) selectScenario(ctx context.Context, ticket tickets.Ticket, role string) (Scenario, error) {
+	scenarios, err := s.repo.GetActiveScenarios(ctx, ticket.CategoryID)
+	if err != nil {
+		return Scenario{}, fmt.Errorf("get active scenarios: %w", err)
+	}
+
+	a := audience{source: ticket.Source, role: role}
+	now := time.Now()
+
+	var matched []Scenario
+
+	for _, scenario := range scenarios {
+		if scenario.PublishedVersionID == nil {
+			continue
+		}
+
+		if scenario.Experiment != nil && scenario.Weight == 0 {
+			continue
+		}
+
+		if !scenario.Schedule.contains(now) {
+			continue
+		}
+
+		if scenario.Targeting != nil && len(scenario.Targeting.Attributes) > 0 && a.contact == nil {
+			contact, err := s.contactRepo.GetByID(ctx, ticket.ContactID)
+			if err != nil && !errors.Is(err, contacts.ErrContactNotFound) {
+				return Scenario{}, fmt.Errorf("get contact: %w", err)
+			}
+			a.contact = &contact
+		}
+
+		if scenario.Targeting.matches(a, now) {
+			matched = append(matched, scenario)
+		}
+	}
+
+	if len(matched) == 0 {
+		return Scenario{}, ErrScenarioNotFound
+	}
+
+	if matched[0].Experiment == nil {
+		return matched[0], nil
+	}
+
+	return s.pickVariant(ctx, ticket, matched), nil
+}
+
+func (s *service) GetTargeting(ctx context.Context, scenarioID int) (Targeting, error) {
+	scenario, err := s.repo.GetByID(ctx, scenarioID)
+	if err != nil {
+		return Targeting{}, fmt.Errorf("get scenario by id: %w", err)
+	}
+
+	if scenario.Targeting == nil {
+		return Targeting{}, nil
+	}
+
+	return *scenario.Targeting, nil
+}
+
+func (s *service) UpdateTargeting(ctx context.Context, scenarioID int, targeting *Targeting) (Targeting, error) {
+	if targeting != nil {
+		fields, err := s.fieldRepo.GetForContacts(ctx)
+		if err != nil {
+			return Targeting{}, fmt.Errorf("get contact fields: %w", err)
+		}
+
+		keys := make([]string, 0, len(fields))
+		for _, field := range fields {
+			keys = append(keys, field.Key)
+		}
+
+		targeting, err = normalizeTargeting(*targeting, keys)
+		if err != nil {
+			return Targeting{}, err
+		}
+	}
+
+	scenario, err := s.repo.UpdateTargeting(ctx, scenarioID, targeting)
+	if err != nil {
+		return Targeting{}, fmt.Errorf("update targeting: %w", err)
+	}
+
+	s.logger.Info("scenario targeting updated", "id", scenarioID)
+
+	if scenario.Targeting == nil {
+		return Targeting{}, nil
+	}
+
+	return *scenario.Targeting, nil
+}
@@ -0,0 +1,338 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AzizovHikmatullo/j-support/internal/contacts"
+	"github.com/google/uuid"
+)
+
+const minReminder = 30
+
+func defaultTimeoutSettings() Timeout {
+	translations := make(map[string]TimeoutTranslation, len(defaultTimeoutMessages))
+	for locale, message := range defaultTimeoutMessages {
+		if locale != contacts.DefaultLocale {
+			translations[locale] = TimeoutTranslation{Message: message}
+		}
+	}
+
+	return Timeout{
+		After:        defaultTimeout,
+		Message:      defaultTimeoutMessage,
+		Outcome:      timeoutClose,
+		Translations: translations,
+	}
+}
+
+// effectiveTimeout returns the timeout of the step, falling back to the
+// scenario settings and then to the defaults.
+func effectiveTimeout(scenario Scenario, step *Step) Timeout {
+	if step != nil && step.Timeout != nil {
+		return *step.Timeout
+	}
+	if scenario.Timeout != nil {
+		return *scenario.Timeout
+	}
+	return defaultTimeoutSettings()
+}
+
+func scenarioTimeout(scenario Scenario) Timeout {
+	return effectiveTimeout(scenario, nil)
+}
+
+// The goto key is checked against the steps separately, since a scenario
+// timeout applies to every version.
+func normalizeTimeout(t Timeout) (Timeout, error) {
+	t.Reminder = strings.TrimSpace(t.Reminder)
+	t.Message = strings.TrimSpace(t.Message)
+	t.Outcome = strings.TrimSpace(t.Outcome)
+	t.Goto = strings.TrimSpace(t.Goto)
+
+	translations := make(map[string]TimeoutTranslation, len(t.Translations))
+	for locale, tr := range t.Translations {
+		locale = strings.ToLower(strings.TrimSpace(locale))
+		if err := checkLocale(locale); err != nil {
+			return t, fmt.Errorf("%w: %s", ErrInvalidTimeout, err.Error())
+		}
+
+		tr = TimeoutTranslation{Reminder: strings.TrimSpace(tr.Reminder), Message: strings.TrimSpace(tr.Message)}
+		if tr.Reminder != "" && t.RemindAfter == 0 {
+			return t, fmt.Errorf("%w: %s: reminder requires remind_after", ErrInvalidTimeout, locale)
+		}
+		if tr != (TimeoutTranslation{}) {
+			translations[locale] = tr
+		}
+	}
+
+	t.Translations = translations
+	if len(translations) == 0 {
+		t.Translations = nil
+	}
+
+	if t.Outcome == "" {
+		t.Outcome = timeoutClose
+	}
+
+	if t.After < minTimeout || t.After > maxTimeout {
+		return t, fmt.Errorf("%w: after must be between %d and %d seconds", ErrInvalidTimeout, minTimeout, maxTimeout)
+	}
+
+	if t.RemindAfter != 0 && (t.RemindAfter < minReminder || t.RemindAfter >= t.After) {
+		return t, fmt.Errorf("%w: remind_after must be at least %d seconds and less than after", ErrInvalidTimeout, minReminder)
+	}
+
+	if t.Reminder != "" && t.RemindAfter == 0 {
+		return t, fmt.Errorf("%w: reminder requires remind_after", ErrInvalidTimeout)
+	}
+
+	switch t.Outcome {
+	case timeoutClose, timeoutHandoff:
+		if t.Goto != "" {
+			return t, fmt.Errorf("%w: goto requires the goto outcome", ErrInvalidTimeout)
+		}
+	case timeoutGoto:
+		if t.Goto == "" {
+			return t, fmt.Errorf("%w: goto is required for the goto outcome", ErrInvalidTimeout)
+		}
+	default:
+		return t, fmt.Errorf("%w: outcome must be one of close, handoff, goto", ErrInvalidTimeout)
+	}
+
+	return t, nil
+}
+
+func checkTimeoutGoto(t *Timeout, steps map[int]*Step) error {
+	if t == nil || t.Outcome != timeoutGoto {
+		return nil
+	}
+
+	if stepByKey(steps, t.Goto) == nil {
+		return fmt.Errorf("%w: unknown step %q", ErrInvalidTimeout, t.Goto)
+	}
+
+	return nil
+}
+
+func stepByKey(steps map[int]*Step, key string) *Step {
+	for _, step := range steps {
+		if step.Key != nil && *step.Key == key {
+			return step
+		}
+	}
+	return nil
+}
+
+func (s *service) GetTimeout(ctx context.Context, scenarioID int) (Timeout, error) {
+	scenario, err := s.repo.GetByID(ctx, scenarioID)
+	if err != nil {
+		return Timeout{}, fmt.Errorf("get scenario by id: %w", err)
+	}
+
+	return scenarioTimeout(scenario), nil
+}
+
+func (s *service) UpdateTimeout(ctx context.Context, scenarioID int, timeout *Timeout) (Timeout, error) {
+	if timeout != nil {
+		normalized, err := normalizeTimeout(*timeout)
+		if err != nil {
+			return Timeout{}, err
+		}
+		timeout = &normalized
+	}
+
+	scenario, err := s.repo.UpdateTimeout(ctx, scenarioID, timeout)
+	if err != nil {
+		return Timeout{}, fmt.Errorf("update timeout: %w", err)
+	}
+
+	s.logger.Info("scenario timeout updated", "id", scenarioID)
+	return scenarioTimeout(scenario), nil
+}
+
+// CheckTimeouts is run by the scheduler. It reminds customers who stopped
+// answering and applies the timeout outcome to idle sessions.
+func (s *service) CheckTimeouts(ctx context.Context, now time.Time) error {
+	sessions, err := s.repo.GetInactiveSessions(ctx, now.Add(-minReminder*time.Second))
+	if err != nil {
+		return fmt.Errorf("get inactive sessions: %w", err)
+	}
+
+	type graphKey struct {
+		versionID int
+		locale    string
+	}
+
+	scenarios := make(map[int]Scenario)
+	graphs := make(map[graphKey]*graph)
+
+	for _, session := range sessions {
+		scenario, ok := scenarios[session.ScenarioID]
+		if !ok {
+			scenario, err = s.repo.GetByID(ctx, session.ScenarioID)
+			if err != nil {
+				s.logger.Error("failed to get session scenario", "ticket id", session.TicketID.String(), "error", err.Error())
+				continue
+			}
+			scenarios[session.ScenarioID] = scenario
+		}
+
+		key := graphKey{versionID: session.VersionID, locale: sessionLocale(session)}
+
+		g, ok := graphs[key]
+		if !ok {
+			steps, err := s.repo.GetAllSteps(ctx, session.VersionID)
+			if err != nil {
+				s.logger.Error("failed to get session steps", "ticket id", session.TicketID.String(), "error", err.Error())
+				continue
+			}
+			g = newGraph(localizeSteps(steps, key.locale))
+			graphs[key] = g
+		}
+
+		if err := s.checkTimeout(ctx, session, scenario, g, now); err != nil {
+			s.logger.Error("failed to apply session timeout", "ticket id", session.TicketID.String(), "error", err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (s *service) checkTimeout(ctx context.Context, session Session, scenario Scenario, g *graph, now time.Time) error {
+	timeout := effectiveTimeout(scenario, g.steps[session.CurrentStepID])
+	idle := now.Sub(session.LastActivityAt)
+
+	if idle >= time.Duration(timeout.After)*time.Second {
+		return s.timeoutSession(ctx, session, timeout, g)
+	}
+
+	if timeout.RemindAfter == 0 || idle < time.Duration(timeout.RemindAfter)*time.Second {
+		return nil
+	}
+
+	// The reminder is sent once per silence: answering moves last activity
+	// past the reminder.
+	if session.RemindedAt != nil && !session.RemindedAt.Before(session.LastActivityAt) {
+		return nil
+	}
+
+	reminder, _ := timeout.texts(sessionLocale(session))
+
+	if err := s.repo.MarkReminded(ctx, session.TicketID); err != nil {
+		return fmt.Errorf("mark reminded: %w", err)
+	}
+
+	if err := s.sendWithButtons(ctx, session.TicketID, reminder); err != nil {
+		return err
+	}
+
+	s.logger.Info("inactivity reminder sent", "ticket id", session.TicketID.String())
+	return nil
+}
+
+// A goto that cannot be followed closes the ticket instead.
+func (s *service) timeoutSession(ctx context.Context, session Session, timeout Timeout, g *graph) error {
+	ticketID := session.TicketID
+	_, message := timeout.texts(sessionLocale(session))
+
+	var target *Step
+	if timeout.Outcome == timeoutGoto && session.Jumps < maxJumpsPerSession {
+		target = stepByKey(g.steps, timeout.Goto)
+	}
+
+	outcome := timeout.Outcome
+	if outcome == timeoutGoto && target == nil {
+		outcome = timeoutClose
+	}
+
+	timedOut := Transition{
+		Event:      EventTimeout,
+		FromStepID: &session.CurrentStepID,
+		Reason:     &outcome,
+		DurationMs: waited(session),
+	}
+
+	s.logger.Info("bot session timed out", "ticket id", ticketID.String(), "outcome", outcome)
+
+	switch outcome {
+	case timeoutHandoff:
+		s.recordTransitions(ctx, session, []Transition{timedOut})
+		s.saveVariables(ctx, ticketID, session.Variables)
+
+		if message != "" {
+			if _, err := s.ticketService.CreateMessage(ctx, ticketID, 0, "bot", message); err != nil {
+				return err
+			}
+		}
+		return s.ticketService.ChangeStatus(ctx, 0, "bot", ticketID, "open")
+
+	case timeoutGoto:
+		if session.Variables == nil {
+			session.Variables = Variables{}
+		}
+
+		stop := s.callWebhooks(ctx, g, ticketID, session.Variables)
+		defer stop()
+		res := g.resolve(target, g.trimStack(session.CallStack, target.ID), session.Jumps+1)
+
+		if err := s.repo.UpdateSession(ctx, ticketID, res.step.ID, res.stack, res.jumps); err != nil {
+			return fmt.Errorf("update session: %w", err)
+		}
+
+		question, closes := s.enter(ctx, ticketID, res.path, session.Variables)
+
+		timedOut.ToStepID = &target.ID
+		s.recordTransitions(ctx, session, append([]Transition{timedOut}, g.moves(res)...))
+
+		message = strings.TrimSpace(strings.Join([]string{message, question}, "\n\n"))
+
+		if closes {
+			_, err := s.closeWithAnswer(ctx, ticketID, message, session.Variables)
+			return err
+		}
+
+		if res.limited || res.failed || g.isLeaf(res.step) {
+			if res.limited {
+				s.logLoopLimit(ctx, ticketID, res)
+			}
+
+			s.saveVariables(ctx, ticketID, session.Variables)
+			if message != "" {
+				if _, err := s.ticketService.CreateMessage(ctx, ticketID, 0, "bot", message); err != nil {
+					return err
+				}
+			}
+			return s.ticketService.ChangeStatus(ctx, 0, "bot", ticketID, "open")
+		}
+
+		// The new step starts its own timeout.
+		if err := s.repo.UpdateLastActivity(ctx, ticketID); err != nil {
+			return fmt.Errorf("update last activity: %w", err)
+		}
+
+		return s.sendWithButtons(ctx, ticketID, message)
+
+	default:
+		s.recordTransitions(ctx, session, []Transition{timedOut})
+
+		if message == "" {
+			message = defaultTimeoutMessages[sessionLocale(session)]
+		}
+
+		_, err := s.closeWithAnswer(ctx, ticketID, message, session.Variables)
+		return err
+	}
+}
+
+func (s *service) sendWithButtons(ctx context.Context, ticketID uuid.UUID, message string) error {
+	buttons, err := s.GetButtonsForCurrentStep(ctx, ticketID)
+	if err != nil {
+		return fmt.Errorf("get button for current step: %w", err)
+	}
+
+	_, err = s.ticketService.CreateMessageWithButtons(ctx, ticketID, 0, "bot", message, buttons)
+	return err
+}
@@ -0,0 +1,118 @@
+package scenario
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateSteps reports errors, which block publishing and activation, and
+// warnings about likely mistakes.
+func validateSteps(steps []Step) ValidationReport {
+	report := ValidationReport{
+		Errors:   []ValidationIssue{},
+		Warnings: []ValidationIssue{},
+	}
+
+	g := newGraph(steps)
+
+	if g.root == nil {
+		report.Errors = append(report.Errors, ValidationIssue{Code: issueNoRoot, Message: "version has no root step"})
+	}
+
+	if g.root != nil {
+		for _, id := range g.unreachable() {
+			report.addError(g.steps[id], issueUnreachable, "no answer or goto leads to this step")
+		}
+	}
+
+	for i := range steps {
+		step := &steps[i]
+		children := g.children[step.ID]
+
+		if step.GotoStepID == nil && step.Webhook == nil && strings.TrimSpace(step.Question) == "" {
+			report.addError(step, issueEmptyQuestion, ErrQuestionRequired.Error())
+		}
+
+		if err := validateWebhook(*step, children); err != nil {
+			report.addError(step, issueInvalidWebhook, err.Error())
+		}
+
+		if err := validateCondition(step.MatchMode, step.Condition); err != nil {
+			report.addError(step, issueInvalidCondition, err.Error())
+		}
+
+		if err := validateGoto(*step, children, g.steps); err != nil {
+			report.addError(step, issueInvalidGoto, err.Error())
+		} else if g.target(step) != nil && g.resolve(step, nil, 0).limited {
+			report.addError(step, issueGotoCycle, "goto steps form a cycle or a chain too long without a question")
+		}
+
+		if err := validateTranslations(step.Translations, step.MatchMode, step.Condition); err != nil {
+			report.addError(step, issueInvalidTranslation, err.Error())
+		}
+
+		if step.Timeout != nil {
+			if _, err := normalizeTimeout(*step.Timeout); err != nil {
+				report.addError(step, issueInvalidTimeout, err.Error())
+			} else if err := checkTimeoutGoto(step.Timeout, g.steps); err != nil {
+				report.addError(step, issueInvalidTimeout, err.Error())
+			}
+		}
+
+		defaults := 0
+		conditions := make(map[string]bool, len(children))
+		buttons := make(map[string]bool, len(children))
+
+		for j := range children {
+			child := &children[j]
+
+			if child.Condition == nil {
+				defaults++
+				if defaults > 1 {
+					report.addError(child, issueMultipleDefaults, ErrDefaultAlreadyExists.Error())
+				}
+			} else {
+				condition := child.MatchMode + ":" + strings.ToLower(strings.TrimSpace(*child.Condition))
+				if conditions[condition] {
+					report.addError(child, issueDuplicateCondition, fmt.Sprintf("condition %q is already used by another child of step %d", *child.Condition, step.ID))
+				}
+				conditions[condition] = true
+			}
+
+			if label := strings.ToLower(buttonLabel(*child)); label != "" {
+				if buttons[label] {
+					report.addWarning(child, issueDuplicateButton, fmt.Sprintf("button %q is shown twice", buttonLabel(*child)))
+				}
+				buttons[label] = true
+			}
+		}
+
+		if len(children) > 0 && defaults == 0 && step.GotoStepID == nil {
+			report.addWarning(step, issueNoDefault, "an answer matching no condition hands the ticket to an operator")
+		}
+
+		if g.isLeaf(step) && !step.Actions.closes() && strings.HasSuffix(strings.TrimSpace(step.Question), "?") {
+			report.addWarning(step, issueLeafQuestion, "leaf step asks a question, but the ticket is handed to an operator without waiting for the answer")
+		}
+	}
+
+	report.Valid = len(report.Errors) == 0
+	return report
+}
+
+func (r *ValidationReport) addError(step *Step, code, message string) {
+	r.Errors = append(r.Errors, newIssue(step, code, message))
+}
+
+func (r *ValidationReport) addWarning(step *Step, code, message string) {
+	r.Warnings = append(r.Warnings, newIssue(step, code, message))
+}
+
+func newIssue(step *Step, code, message string) ValidationIssue {
+	issue := ValidationIssue{Code: code, Message: message}
+	if step != nil {
+		issue.StepID = &step.ID
+		issue.StepKey = step.Key
+	}
+	return issue
+}
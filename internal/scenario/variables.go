@@ -0,0 +1,146 @@
+package scenario
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	variablePattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+	placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+var dateLayouts = []string{"2006-01-02", "02.01.2006", "02/01/2006", "2.1.2006"}
+
+func validateCapture(variable, validator, pattern *string) error {
+	if variable == nil {
+		if validator != nil || pattern != nil {
+			return fmt.Errorf("%w: validator requires a variable", ErrInvalidValidator)
+		}
+		return nil
+	}
+
+	if len(*variable) > maxVariableLength || !variablePattern.MatchString(*variable) {
+		return ErrInvalidVariable
+	}
+
+	if validator == nil {
+		return nil
+	}
+
+	if !validators[*validator] {
+		return fmt.Errorf("%w: unknown validator %q", ErrInvalidValidator, *validator)
+	}
+
+	if *validator != validatorRegex {
+		return nil
+	}
+
+	if pattern == nil || *pattern == "" {
+		return fmt.Errorf("%w: regex validator requires validator_pattern", ErrInvalidValidator)
+	}
+
+	if _, err := regexp.Compile(anchor(*pattern)); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidValidator, err.Error())
+	}
+
+	return nil
+}
+
+// capture returns the value to store for the answer. Numbers, phones and
+// dates are normalized.
+func capture(step Step, answer string) (string, bool) {
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return "", false
+	}
+
+	validator := validatorText
+	if step.Validator != nil {
+		validator = *step.Validator
+	}
+
+	switch validator {
+	case validatorRegex:
+		if step.ValidatorPattern == nil {
+			return "", false
+		}
+		re, err := regexp.Compile(anchor(*step.ValidatorPattern))
+		if err != nil || !re.MatchString(answer) {
+			return "", false
+		}
+		return answer, true
+	case validatorNumber:
+		value, err := parseNumber(answer)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return "", false
+		}
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	case validatorPhone:
+		return normalizePhone(answer)
+	case validatorDate:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, answer); err == nil {
+				return t.Format("2006-01-02"), true
+			}
+		}
+		return "", false
+	default:
+		return answer, true
+	}
+}
+
+func normalizePhone(answer string) (string, bool) {
+	var b strings.Builder
+
+	for i, r := range answer {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '(' || r == ')':
+		default:
+			return "", false
+		}
+	}
+
+	phone := b.String()
+	digits := len(strings.TrimPrefix(phone, "+"))
+	if digits < 7 || digits > 15 {
+		return "", false
+	}
+
+	return phone, true
+}
+
+func anchor(pattern string) string {
+	return "^(?:" + pattern + ")$"
+}
+
+func errorMessage(step Step, locale string) string {
+	if step.ErrorMessage != nil && *step.ErrorMessage != "" {
+		return *step.ErrorMessage
+	}
+	if message, ok := defaultErrorMessages[locale]; ok {
+		return message
+	}
+	return defaultErrorMessage
+}
+
+// interpolate replaces {{name}} placeholders with collected variables.
+// Unknown variables are replaced with an empty string.
+func interpolate(text string, vars Variables) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+		return vars[name]
+	})
+}
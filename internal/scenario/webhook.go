@@ -0,0 +1,431 @@
+package scenario
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AzizovHikmatullo/j-support/internal/activity_log"
+	"github.com/google/uuid"
+)
+
+const (
+	webhookSuccess = "success"
+	webhookFailure = "failure"
+
+	defaultWebhookTimeout = 3000
+	maxWebhookTimeout     = 10000
+	maxWebhookRetries     = 3
+	maxWebhookResponse    = 1 << 20
+
+	webhookBackoff = 200 * time.Millisecond
+
+	// webhookBudget bounds all webhook calls made while handling one message,
+	// so chained webhook steps cannot hold the customer's request for minutes.
+	webhookBudget = 15 * time.Second
+)
+
+var webhookMethods = []string{http.MethodGet, http.MethodPost}
+
+// urlValueReplacer escapes the characters url.PathEscape keeps but which
+// would split a value placed in the query of the URL.
+var urlValueReplacer = strings.NewReplacer("&", "%26", "=", "%3D", "+", "%2B", ";", "%3B")
+
+type webhookClient struct {
+	client       *http.Client
+	allowedHosts []string
+}
+
+// NewWebhookClient returns the client webhook steps call endpoints with. An
+// allowlist entry like "*.example.com" allows every subdomain. Redirects are not
+// followed, so an allowed host cannot send the bot to another one.
+func NewWebhookClient(allowedHosts []string) *webhookClient {
+	var hosts []string
+	for _, host := range allowedHosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return &webhookClient{
+		client: &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		allowedHosts: hosts,
+	}
+}
+
+func (c *webhookClient) allowed(host string) bool {
+	host = strings.ToLower(host)
+
+	for _, allowed := range c.allowedHosts {
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Placeholders may only follow the host, so the host is known when the step is
+// saved.
+func (c *webhookClient) checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+
+	if strings.ContainsAny(u.Host, "{}") || u.User != nil {
+		return fmt.Errorf("%w: url host cannot contain placeholders or credentials", ErrInvalidWebhook)
+	}
+
+	if !c.allowed(u.Hostname()) {
+		return fmt.Errorf("%w: host %q is not in the allowlist", ErrInvalidWebhook, u.Hostname())
+	}
+
+	return nil
+}
+
+func (c *webhookClient) normalize(w Webhook) (Webhook, error) {
+	w.URL = strings.TrimSpace(w.URL)
+	w.Method = strings.ToUpper(strings.TrimSpace(w.Method))
+
+	if w.Method == "" {
+		w.Method = http.MethodPost
+	}
+
+	if !slices.Contains(webhookMethods, w.Method) {
+		return w, fmt.Errorf("%w: method must be one of %s", ErrInvalidWebhook, strings.Join(webhookMethods, ", "))
+	}
+
+	if err := c.checkURL(w.URL); err != nil {
+		return w, err
+	}
+
+	if w.Timeout == 0 {
+		w.Timeout = defaultWebhookTimeout
+	}
+
+	if w.Timeout < 0 || w.Timeout > maxWebhookTimeout {
+		return w, fmt.Errorf("%w: timeout_ms must be between 1 and %d", ErrInvalidWebhook, maxWebhookTimeout)
+	}
+
+	if w.Retries < 0 || w.Retries > maxWebhookRetries {
+		return w, fmt.Errorf("%w: retries must be between 0 and %d", ErrInvalidWebhook, maxWebhookRetries)
+	}
+
+	var variables []string
+	for _, variable := range w.Variables {
+		variable = strings.TrimSpace(variable)
+
+		if len(variable) > maxVariableLength || !variablePattern.MatchString(variable) {
+			return w, fmt.Errorf("%w: variables: %s", ErrInvalidWebhook, ErrInvalidVariable.Error())
+		}
+
+		if !slices.Contains(variables, variable) {
+			variables = append(variables, variable)
+		}
+	}
+	w.Variables = variables
+
+	response := make(map[string]string, len(w.Response))
+	for variable, path := range w.Response {
+		variable, path = strings.TrimSpace(variable), strings.TrimSpace(path)
+
+		if len(variable) > maxVariableLength || !variablePattern.MatchString(variable) {
+			return w, fmt.Errorf("%w: response: %s", ErrInvalidWebhook, ErrInvalidVariable.Error())
+		}
+
+		if path == "" {
+			return w, fmt.Errorf("%w: response: path of %q is empty", ErrInvalidWebhook, variable)
+		}
+
+		response[variable] = path
+	}
+
+	w.Response = response
+	if len(response) == 0 {
+		w.Response = nil
+	}
+
+	return w, nil
+}
+
+func validateWebhook(step Step, children []Step) error {
+	if step.Webhook == nil {
+		return nil
+	}
+
+	if step.GotoStepID != nil || step.Variable != nil {
+		return fmt.Errorf("%w: webhook step cannot have goto or variable", ErrInvalidWebhook)
+	}
+
+	for _, child := range children {
+		if child.Condition == nil {
+			continue
+		}
+
+		if condition := strings.ToLower(strings.TrimSpace(*child.Condition)); condition != webhookSuccess && condition != webhookFailure {
+			return fmt.Errorf("%w: condition %q must be %s or %s", ErrInvalidWebhook, *child.Condition, webhookSuccess, webhookFailure)
+		}
+	}
+
+	return nil
+}
+
+type webhookResult struct {
+	status   int
+	attempts int
+	values   Variables
+	err      error
+}
+
+func (r webhookResult) outcome() string {
+	if r.err != nil {
+		return webhookFailure
+	}
+	return webhookSuccess
+}
+
+// call retries network errors, 5xx and 429 responses with a growing pause.
+func (c *webhookClient) call(ctx context.Context, w Webhook, ticketID uuid.UUID, vars Variables) webhookResult {
+	var res webhookResult
+
+	for res.attempts <= w.Retries {
+		if res.attempts > 0 {
+			select {
+			case <-ctx.Done():
+				res.err = ctx.Err()
+				return res
+			case <-time.After(webhookBackoff * time.Duration(res.attempts)):
+			}
+		}
+		res.attempts++
+
+		var body []byte
+		var retry bool
+		res.status, body, retry, res.err = c.do(ctx, w, ticketID, vars)
+
+		if res.err == nil {
+			res.values, res.err = mapResponse(w.Response, body)
+			return res
+		}
+
+		if !retry {
+			return res
+		}
+	}
+
+	return res
+}
+
+func (c *webhookClient) do(ctx context.Context, w Webhook, ticketID uuid.UUID, vars Variables) (int, []byte, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(w.Timeout)*time.Millisecond)
+	defer cancel()
+
+	req, err := c.request(ctx, w, ticketID, vars)
+	if err != nil {
+		return 0, nil, false, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, nil, true, fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponse))
+	if err != nil {
+		return resp.StatusCode, nil, true, fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return resp.StatusCode, nil, retry, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, body, false, nil
+}
+
+// The host is checked against the allowlist again, since the allowlist may have
+// changed after the step was saved.
+func (c *webhookClient) request(ctx context.Context, w Webhook, ticketID uuid.UUID, vars Variables) (*http.Request, error) {
+	vars = w.sent(vars)
+
+	escaped := make(Variables, len(vars))
+	for name, value := range vars {
+		escaped[name] = urlValueReplacer.Replace(url.PathEscape(value))
+	}
+
+	// A value that is a dot segment would move the request to another path
+	// of the host once the URL is resolved.
+	for _, match := range placeholderPattern.FindAllStringSubmatch(w.URL, -1) {
+		if value := vars[match[1]]; value == "." || value == ".." {
+			return nil, fmt.Errorf("variable %q cannot be used in the url", match[1])
+		}
+	}
+
+	u, err := url.Parse(interpolate(w.URL, escaped))
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+
+	if !c.allowed(u.Hostname()) {
+		return nil, fmt.Errorf("host %q is not in the allowlist", u.Hostname())
+	}
+
+	var body io.Reader
+	if w.Method == http.MethodGet {
+		query := u.Query()
+		query.Set("ticket_id", ticketID.String())
+		for name, value := range vars {
+			query.Set(name, value)
+		}
+		u.RawQuery = query.Encode()
+	} else {
+		payload, err := json.Marshal(map[string]any{"ticket_id": ticketID, "variables": vars})
+		if err != nil {
+			return nil, fmt.Errorf("encode body: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, w.Method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range w.Headers {
+		req.Header.Set(name, interpolate(value, vars))
+	}
+
+	return req, nil
+}
+
+// sent keeps other session values, often personal data collected for unrelated
+// steps, out of the call.
+func (w Webhook) sent(vars Variables) Variables {
+	names := slices.Clone(w.Variables)
+	for _, text := range append([]string{w.URL}, slices.Collect(maps.Values(w.Headers))...) {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			names = append(names, match[1])
+		}
+	}
+
+	sent := make(Variables, len(names))
+	for _, name := range names {
+		if value, ok := vars[name]; ok {
+			sent[name] = value
+		}
+	}
+
+	return sent
+}
+
+// A response that is not JSON fails the call only when something has to be
+// mapped from it.
+func mapResponse(paths map[string]string, body []byte) (Variables, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	var data any
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	values := make(Variables, len(paths))
+	for variable, path := range paths {
+		if value, ok := lookup(data, path); ok {
+			values[variable] = value
+		}
+	}
+
+	return values, nil
+}
+
+func lookup(data any, path string) (string, bool) {
+	for _, part := range strings.Split(path, ".") {
+		switch node := data.(type) {
+		case map[string]any:
+			data = node[part]
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", false
+			}
+			data = node[i]
+		default:
+			return "", false
+		}
+	}
+
+	switch value := data.(type) {
+	case nil:
+		return "", false
+	case string:
+		return value, true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(value), true
+	default:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return "", false
+		}
+		return string(b), true
+	}
+}
+
+// The returned func releases the deadline shared by the calls.
+func (s *service) callWebhooks(ctx context.Context, g *graph, ticketID uuid.UUID, vars Variables) context.CancelFunc {
+	callCtx, cancel := context.WithTimeout(ctx, webhookBudget)
+
+	g.call = func(step *Step) string {
+		res := s.webhooks.call(callCtx, *step.Webhook, ticketID, vars)
+
+		for name, value := range res.values {
+			vars[name] = value
+			if err := s.repo.SetVariable(ctx, ticketID, name, value); err != nil {
+				s.logger.Error("failed to set webhook variable", "ticket id", ticketID.String(), "error", err.Error())
+			}
+		}
+
+		payload := activity_log.Payload{"step_id": step.ID, "outcome": res.outcome(), "attempts": res.attempts}
+		if res.status != 0 {
+			payload["status"] = res.status
+		}
+		if res.err != nil {
+			payload["error"] = res.err.Error()
+			s.logger.Warn("scenario webhook failed", "ticket id", ticketID.String(), "step id", step.ID, "error", res.err.Error())
+		}
+
+		s.activityLog.Log(ctx, activity_log.LogEntry{
+			TicketID:  ticketID,
+			ActorID:   0,
+			ActorType: "bot",
+			Action:    activity_log.ActionBotWebhook,
+			Payload:   payload,
+		})
+
+		return res.outcome()
+	}
+
+	return cancel
+}
@@ -5,28 +5,26 @@ import (
 	"time"
 )
 
-const (
-	ticketTimeout = 5 * time.Minute
-
-	inactivityCloseMessage = "Время ожидания ответа истекло. Ваше обращение будет закрыто!"
-)
-
 func (sch *Scheduler) registerJobs() {
-	// CLOSE TICKET AFTER 5min INACTIVITY
+	// REMIND AND TIME OUT INACTIVE BOT SESSIONS
 
 	sch.s.Every(1).Minute().Do(func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 		defer cancel()
 
-		sessions, err := sch.scenarioRepo.GetInactiveSessions(ctx, time.Now().Add(-ticketTimeout))
-		if err != nil {
-			sch.logger.Error("failed to get inactive sessions", "error", err)
-			return
+		if err := sch.scenarioService.CheckTimeouts(ctx, time.Now()); err != nil {
+			sch.logger.Error("failed to check inactive sessions", "error", err)
 		}
+	})
+
+	// LOG SCENARIO SCHEDULE WINDOWS
+
+	sch.s.Every(1).Minute().Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+		defer cancel()
 
-		for _, s := range sessions {
-			_, _ = sch.ticketService.CreateMessage(ctx, s.TicketID, 0, "bot", inactivityCloseMessage)
-			_ = sch.ticketService.ChangeStatus(ctx, 0, "bot", s.TicketID, "closed")
+		if err := sch.scenarioService.CheckSchedules(ctx, time.Now()); err != nil {
+			sch.logger.Error("failed to check scenario schedules", "error", err)
 		}
 	})
 }
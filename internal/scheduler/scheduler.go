@@ -5,7 +5,6 @@ import (
 	"time"
 
 	"github.com/AzizovHikmatullo/j-support/internal/scenario"
-	"github.com/AzizovHikmatullo/j-support/internal/tickets"
 	"github.com/go-co-op/gocron"
 )
 
@@ -13,18 +12,16 @@ type Scheduler struct {
 	s      *gocron.Scheduler
 	logger *slog.Logger
 
-	ticketService tickets.Service
-	scenarioRepo  scenario.Repository
+	scenarioService scenario.Service
 }
 
-func New(ticketService tickets.Service, scenarioRepo scenario.Repository, logger *slog.Logger) *Scheduler {
+func New(scenarioService scenario.Service, logger *slog.Logger) *Scheduler {
 	sched := gocron.NewScheduler(time.UTC)
 
 	return &Scheduler{
-		s:             sched,
-		logger:        logger,
-		ticketService: ticketService,
-		scenarioRepo:  scenarioRepo,
+		s:               sched,
+		logger:          logger,
+		scenarioService: scenarioService,
 	}
 }
 
@@ -0,0 +1,214 @@
+package tags
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Service interface {
+	Create(ctx context.Context, name string) (Tag, error)
+	GetAll(ctx context.Context) ([]Tag, error)
+	Update(ctx context.Context, id int, name string) (Tag, error)
+	Delete(ctx context.Context, id int) error
+	Stats(ctx context.Context, req StatsRequest) ([]TagCount, error)
+}
+
+type handler struct {
+	service Service
+
+	logger *slog.Logger
+}
+
+func NewHandler(service Service, logger *slog.Logger) *handler {
+	return &handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// @Summary      Создать тег
+// @Description  Только администратор
+// @Tags         tags
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        body  body  tags.CreateTagRequest  true  "Тег"
+// @Success      201   {object}  tags.Tag
+// @Failure      400   {object}  map[string]string
+// @Failure      409   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /tags [post]
+func (h *handler) Create(c *gin.Context) {
+	var req CreateTagRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	tag, err := h.service.Create(c.Request.Context(), req.Name)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+// @Summary      Получить каталог тегов
+// @Tags         tags
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Success      200  {array}   tags.Tag
+// @Failure      401  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /tags [get]
+func (h *handler) GetAll(c *gin.Context) {
+	tags, err := h.service.GetAll(c.Request.Context())
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// @Summary      Переименовать тег
+// @Description  Только администратор
+// @Tags         tags
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id    path  int                    true  "ID тега"
+// @Param        body  body  tags.UpdateTagRequest  true  "Обновление"
+// @Success      200   {object}  tags.Tag
+// @Failure      400   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      409   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /tags/{id} [patch]
+func (h *handler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid tag id"})
+		return
+	}
+
+	var req UpdateTagRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	tag, err := h.service.Update(c.Request.Context(), id, req.Name)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// @Summary      Удалить тег
+// @Description  Только администратор. Тег снимается со всех тикетов
+// @Tags         tags
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path  int  true  "ID тега"
+// @Success      200  {object}  map[string]bool
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /tags/{id} [delete]
+func (h *handler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid tag id"})
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), id); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// @Summary      Количество тикетов по тегам за период
+// @Description  Только администратор. По умолчанию - последние 30 дней
+// @Tags         tags
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        from      query  string  false  "Начало периода (RFC3339 или YYYY-MM-DD)"
+// @Param        to        query  string  false  "Конец периода (RFC3339 или YYYY-MM-DD)"
+// @Param        group_by  query  string  false  "Группировка: day, week, month"
+// @Success      200  {array}   tags.TagCount
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /tags/stats [get]
+func (h *handler) Stats(c *gin.Context) {
+	now := time.Now()
+
+	req := StatsRequest{
+		From:    now.AddDate(0, 0, -30),
+		To:      now,
+		GroupBy: c.Query("group_by"),
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := parseTime(from)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+			return
+		}
+		req.From = t
+	}
+
+	if to := c.Query("to"); to != "" {
+		t, err := parseTime(to)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+			return
+		}
+		req.To = t
+	}
+
+	counts, err := h.service.Stats(c.Request.Context(), req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, counts)
+}
+
+func (h *handler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidName), errors.Is(err, ErrInvalidRange), errors.Is(err, ErrInvalidGrouping):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrTagNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrTagNotFound.Error()})
+	case errors.Is(err, ErrTagExists):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": ErrTagExists.Error()})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		h.logger.Error("tag error", "error", err.Error())
+	}
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
@@ -0,0 +1,50 @@
+package tags
+
+import (
+	"errors"
+	"time"
+)
+
+type Tag struct {
+	ID        int       `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type TagCount struct {
+	Period *time.Time `json:"period,omitempty" db:"period"`
+	TagID  int        `json:"tag_id" db:"tag_id"`
+	Name   string     `json:"name" db:"name"`
+	Count  int        `json:"count" db:"count"`
+}
+
+type CreateTagRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type UpdateTagRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type StatsRequest struct {
+	From    time.Time
+	To      time.Time
+	GroupBy string
+}
+
+const maxNameLength = 50
+
+var groupings = map[string]bool{
+	"":      true,
+	"day":   true,
+	"week":  true,
+	"month": true,
+}
+
+var (
+	ErrTagNotFound     = errors.New("tag not found")
+	ErrTagExists       = errors.New("tag already exists")
+	ErrInvalidName     = errors.New("invalid name")
+	ErrInvalidRange    = errors.New("invalid date range")
+	ErrInvalidGrouping = errors.New("group_by must be one of day, week, month")
+)
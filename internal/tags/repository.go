@@ -0,0 +1,203 @@
+package tags
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type postgresRepo struct {
+	db *sqlx.DB
+}
+
+func NewRepository(db *sqlx.DB) Repository {
+	return &postgresRepo{
+		db: db,
+	}
+}
+
+func (r *postgresRepo) Create(ctx context.Context, name string) (Tag, error) {
+	var tag Tag
+
+	query := `
+		INSERT INTO tags(name)
+		VALUES ($1)
+		RETURNING *
+	`
+
+	err := r.db.QueryRowxContext(ctx, query, name).StructScan(&tag)
+
+	return tag, wrapUnique(err)
+}
+
+func (r *postgresRepo) GetAll(ctx context.Context) ([]Tag, error) {
+	tags := make([]Tag, 0)
+
+	query := `
+		SELECT *
+		FROM tags
+		ORDER BY name
+	`
+
+	err := r.db.SelectContext(ctx, &tags, query)
+
+	return tags, err
+}
+
+func (r *postgresRepo) GetByID(ctx context.Context, id int) (Tag, error) {
+	var tag Tag
+
+	query := `
+		SELECT *
+		FROM tags
+		WHERE id = $1
+	`
+
+	err := r.db.GetContext(ctx, &tag, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return tag, ErrTagNotFound
+	}
+
+	return tag, err
+}
+
+func (r *postgresRepo) GetByName(ctx context.Context, name string) (Tag, error) {
+	var tag Tag
+
+	query := `
+		SELECT *
+		FROM tags
+		WHERE name = $1
+	`
+
+	err := r.db.GetContext(ctx, &tag, query, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return tag, ErrTagNotFound
+	}
+
+	return tag, err
+}
+
+func (r *postgresRepo) Update(ctx context.Context, id int, name string) (Tag, error) {
+	var tag Tag
+
+	query := `
+		UPDATE tags
+		SET name = $2
+		WHERE id = $1
+		RETURNING *
+	`
+
+	err := r.db.QueryRowxContext(ctx, query, id, name).StructScan(&tag)
+	if errors.Is(err, sql.ErrNoRows) {
+		return tag, ErrTagNotFound
+	}
+
+	return tag, wrapUnique(err)
+}
+
+func (r *postgresRepo) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM tags WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+
+	return err
+}
+
+func (r *postgresRepo) GetByTicket(ctx context.Context, ticketID uuid.UUID) ([]Tag, error) {
+	tags := make([]Tag, 0)
+
+	query := `
+		SELECT t.*
+		FROM tags t
+		JOIN ticket_tags tt ON tt.tag_id = t.id
+		WHERE tt.ticket_id = $1
+		ORDER BY t.name
+	`
+
+	err := r.db.SelectContext(ctx, &tags, query, ticketID)
+
+	return tags, err
+}
+
+func (r *postgresRepo) AddToTicket(ctx context.Context, ticketID uuid.UUID, tagID int) (bool, error) {
+	query := `
+		INSERT INTO ticket_tags(ticket_id, tag_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`
+
+	res, err := r.db.ExecContext(ctx, query, ticketID, tagID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+
+	return affected > 0, err
+}
+
+func (r *postgresRepo) RemoveFromTicket(ctx context.Context, ticketID uuid.UUID, tagID int) (bool, error) {
+	query := `
+		DELETE FROM ticket_tags
+		WHERE ticket_id = $1 AND tag_id = $2
+	`
+
+	res, err := r.db.ExecContext(ctx, query, ticketID, tagID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+
+	return affected > 0, err
+}
+
+func (r *postgresRepo) MoveToTicket(ctx context.Context, tx *sqlx.Tx, fromID, toID uuid.UUID) error {
+	query := `
+		INSERT INTO ticket_tags(ticket_id, tag_id, created_at)
+		SELECT $2, tag_id, created_at
+		FROM ticket_tags
+		WHERE ticket_id = $1
+		ON CONFLICT DO NOTHING
+	`
+
+	_, err := tx.ExecContext(ctx, query, fromID, toID)
+
+	return err
+}
+
+func (r *postgresRepo) Counts(ctx context.Context, req StatsRequest) ([]TagCount, error) {
+	counts := make([]TagCount, 0)
+
+	period := "NULL::timestamp"
+	if req.GroupBy != "" {
+		period = "date_trunc('" + req.GroupBy + "', t.created_at)"
+	}
+
+	query := `
+		SELECT ` + period + ` AS period, tg.id AS tag_id, tg.name, count(*) AS count
+		FROM ticket_tags tt
+		JOIN tags tg ON tg.id = tt.tag_id
+		JOIN tickets t ON t.id = tt.ticket_id
+		WHERE t.created_at >= $1 AND t.created_at < $2 AND t.merged_into IS NULL
+		GROUP BY 1, tg.id, tg.name
+		ORDER BY 1, count DESC, tg.name
+	`
+
+	err := r.db.SelectContext(ctx, &counts, query, req.From, req.To)
+
+	return counts, err
+}
+
+func wrapUnique(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrTagExists
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package tags
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository interface {
+	Create(ctx context.Context, name string) (Tag, error)
+	GetAll(ctx context.Context) ([]Tag, error)
+	GetByID(ctx context.Context, id int) (Tag, error)
+	GetByName(ctx context.Context, name string) (Tag, error)
+	Update(ctx context.Context, id int, name string) (Tag, error)
+	Delete(ctx context.Context, id int) error
+
+	GetByTicket(ctx context.Context, ticketID uuid.UUID) ([]Tag, error)
+	AddToTicket(ctx context.Context, ticketID uuid.UUID, tagID int) (bool, error)
+	RemoveFromTicket(ctx context.Context, ticketID uuid.UUID, tagID int) (bool, error)
+	MoveToTicket(ctx context.Context, tx *sqlx.Tx, fromID, toID uuid.UUID) error
+
+	Counts(ctx context.Context, req StatsRequest) ([]TagCount, error)
+}
+
+type service struct {
+	repo Repository
+
+	logger *slog.Logger
+}
+
+func NewService(repo Repository, logger *slog.Logger) Service {
+	return &service{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+func (s *service) Create(ctx context.Context, name string) (Tag, error) {
+	name, ok := NormalizeName(name)
+	if !ok {
+		return Tag{}, ErrInvalidName
+	}
+
+	tag, err := s.repo.Create(ctx, name)
+	if err != nil {
+		return Tag{}, fmt.Errorf("create tag: %w", err)
+	}
+	s.logger.Info("tag created", "id", tag.ID, "name", tag.Name)
+	return tag, nil
+}
+
+func (s *service) GetAll(ctx context.Context) ([]Tag, error) {
+	tags, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get all tags: %w", err)
+	}
+	return tags, nil
+}
+
+func (s *service) Update(ctx context.Context, id int, name string) (Tag, error) {
+	name, ok := NormalizeName(name)
+	if !ok {
+		return Tag{}, ErrInvalidName
+	}
+
+	tag, err := s.repo.Update(ctx, id, name)
+	if err != nil {
+		return Tag{}, fmt.Errorf("update tag: %w", err)
+	}
+	s.logger.Info("tag updated", "id", tag.ID, "name", tag.Name)
+	return tag, nil
+}
+
+func (s *service) Delete(ctx context.Context, id int) error {
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return fmt.Errorf("get tag by id: %w", err)
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("delete tag: %w", err)
+	}
+	s.logger.Info("tag deleted", "id", id)
+	return nil
+}
+
+func (s *service) Stats(ctx context.Context, req StatsRequest) ([]TagCount, error) {
+	if !groupings[req.GroupBy] {
+		return nil, ErrInvalidGrouping
+	}
+
+	if !req.From.Before(req.To) {
+		return nil, ErrInvalidRange
+	}
+
+	counts, err := s.repo.Counts(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("get tag counts: %w", err)
+	}
+	return counts, nil
+}
+
+// NormalizeName lowercases and trims a tag name and reports whether the
+// result is a valid tag name.
+func NormalizeName(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return "", false
+	}
+	return name, true
+}
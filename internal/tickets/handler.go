@@ -9,19 +9,30 @@ import (
 
 	"github.com/AzizovHikmatullo/j-support/internal/channel"
 	"github.com/AzizovHikmatullo/j-support/internal/contacts"
+	"github.com/AzizovHikmatullo/j-support/internal/customfields"
 	"github.com/AzizovHikmatullo/j-support/internal/middleware"
+	"github.com/AzizovHikmatullo/j-support/internal/tags"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type Service interface {
 	Create(ctx context.Context, contactID int, role string, source string, req CreateTicketRequest) (*CreateTicketResponse, error)
-	Get(ctx context.Context, role string, userID int) ([]Ticket, error)
+	Get(ctx context.Context, role string, userID int, filter Filter) ([]Ticket, error)
 	GetByID(ctx context.Context, userID int, role string, ticketID uuid.UUID) (Ticket, error)
 	GetMine(ctx context.Context, contactID int, ticketID uuid.UUID) (Ticket, error)
 	ChangeAssigned(ctx context.Context, userID int, role string, ticketID uuid.UUID, assignedTo int) (Ticket, error)
 	ChangeStatus(ctx context.Context, userID int, role string, ticketID uuid.UUID, status string) error
+	ChangePriority(ctx context.Context, userID int, role string, ticketID uuid.UUID, priority string) (Ticket, error)
+	ChangeTeam(ctx context.Context, userID int, role string, ticketID uuid.UUID, team string) (Ticket, error)
+	ChangeCategory(ctx context.Context, userID int, role string, ticketID uuid.UUID, categoryID int) (Ticket, error)
 	RateTicket(ctx context.Context, contactID int, ticketID uuid.UUID, req CreateRatingRequest) (Rating, error)
+	Merge(ctx context.Context, userID int, role string, sourceID, targetID uuid.UUID) (MergeTicketResponse, error)
+	UpdateFields(ctx context.Context, userID int, role string, ticketID uuid.UUID, values map[string]any) (Ticket, error)
+	SaveVariables(ctx context.Context, ticketID uuid.UUID, variables map[string]string) (Ticket, error)
+	GetTags(ctx context.Context, userID int, role string, ticketID uuid.UUID) ([]tags.Tag, error)
+	AddTag(ctx context.Context, userID int, role string, ticketID uuid.UUID, tagID int) ([]tags.Tag, error)
+	RemoveTag(ctx context.Context, userID int, role string, ticketID uuid.UUID, tagID int) ([]tags.Tag, error)
 	CreateMessage(ctx context.Context, ticketID uuid.UUID, senderID int, senderType, content string) (*Message, error)
 	CreateMessageWithButtons(ctx context.Context, ticketID uuid.UUID, senderID int, senderType, content string, buttons []string) (*Message, error)
 	GetMessages(ctx context.Context, userID int, role string, ticketID uuid.UUID, limit int, cursor string) ([]Message, string, error)
@@ -101,7 +112,7 @@ func (h *handler) GetMine(c *gin.Context) {
 		return
 	}
 
-	tickets, err := h.service.Get(c.Request.Context(), userRole, contact.ID)
+	tickets, err := h.service.Get(c.Request.Context(), userRole, contact.ID, Filter{})
 	if err != nil {
 		h.handleError(c, err)
 		return
@@ -288,6 +299,10 @@ func (h *handler) resolveContact(c *gin.Context) (*contacts.Contact, error) {
 // @Accept       json
 // @Produce      json
 // @Security     Bearer
+// @Param        status       query  string    false  "Статус тикета"
+// @Param        category_id  query  int       false  "ID категории"
+// @Param        tag          query  []string  false  "Только тикеты со всеми указанными тегами"  collectionFormat(multi)
+// @Param        field        query  object    false  "Фильтр по пользовательским полям: field[key]=value"
 // @Success      200  {array}  tickets.Ticket
 // @Failure      401  {object}  map[string]string
 // @Failure      403  {object}  map[string]string
@@ -296,7 +311,13 @@ func (h *handler) Get(c *gin.Context) {
 	role := c.GetString("role")
 	userID := c.GetInt("userID")
 
-	tickets, err := h.service.Get(c.Request.Context(), role, userID)
+	filter, err := FilterFromQuery(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tickets, err := h.service.Get(c.Request.Context(), role, userID, filter)
 	if err != nil {
 		h.handleError(c, err)
 		return
@@ -411,6 +432,315 @@ func (h *handler) ChangeStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
+// @Summary      Изменить приоритет тикета
+// @Tags         support
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id    path   string                        true  "UUID тикета"
+// @Param        body  body   tickets.ChangePriorityRequest true  "Приоритет: low, normal, high, urgent"
+// @Success      200   {object}  tickets.Ticket
+// @Failure      400   {object}  map[string]string
+// @Failure      401   {object}  map[string]string
+// @Failure      403   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Router       /support/tickets/{id}/priority [patch]
+func (h *handler) ChangePriority(c *gin.Context) {
+	var req ChangePriorityRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid ticketID"})
+		return
+	}
+
+	role := c.GetString("role")
+	userID := c.GetInt("userID")
+
+	ticket, err := h.service.ChangePriority(c.Request.Context(), userID, role, ticketID, req.Priority)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ticket)
+}
+
+// @Summary      Назначить тикет команде
+// @Description  Пустое название команды снимает тикет с команды
+// @Tags         support
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id    path   string                    true  "UUID тикета"
+// @Param        body  body   tickets.ChangeTeamRequest true  "Команда"
+// @Success      200   {object}  tickets.Ticket
+// @Failure      400   {object}  map[string]string
+// @Failure      401   {object}  map[string]string
+// @Failure      403   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      422   {object}  map[string]string
+// @Router       /support/tickets/{id}/team [patch]
+func (h *handler) ChangeTeam(c *gin.Context) {
+	var req ChangeTeamRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid ticketID"})
+		return
+	}
+
+	role := c.GetString("role")
+	userID := c.GetInt("userID")
+
+	ticket, err := h.service.ChangeTeam(c.Request.Context(), userID, role, ticketID, req.Team)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ticket)
+}
+
+// @Summary      Перевести тикет в другую категорию
+// @Tags         support
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id    path   string                        true  "UUID тикета"
+// @Param        body  body   tickets.ChangeCategoryRequest true  "Новая категория"
+// @Success      200   {object}  tickets.Ticket
+// @Failure      400   {object}  map[string]string
+// @Failure      401   {object}  map[string]string
+// @Failure      403   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      422   {object}  map[string]string
+// @Router       /support/tickets/{id}/category [patch]
+func (h *handler) ChangeCategory(c *gin.Context) {
+	var req ChangeCategoryRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid ticketID"})
+		return
+	}
+
+	role := c.GetString("role")
+	userID := c.GetInt("userID")
+
+	ticket, err := h.service.ChangeCategory(c.Request.Context(), userID, role, ticketID, req.CategoryID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ticket)
+}
+
+// @Summary      Объединить тикет с другим тикетом
+// @Description  Переносит сообщения, лог активности и оценку в целевой тикет и закрывает исходный
+// @Tags         support
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id    path   string                      true  "UUID исходного тикета"
+// @Param        body  body   tickets.MergeTicketRequest  true  "Целевой тикет"
+// @Success      200   {object}  tickets.MergeTicketResponse
+// @Failure      400   {object}  map[string]string
+// @Failure      401   {object}  map[string]string
+// @Failure      403   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      422   {object}  map[string]string
+// @Router       /support/tickets/{id}/merge [post]
+func (h *handler) Merge(c *gin.Context) {
+	var req MergeTicketRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid ticketID"})
+		return
+	}
+
+	role := c.GetString("role")
+	userID := c.GetInt("userID")
+
+	resp, err := h.service.Merge(c.Request.Context(), userID, role, ticketID, req.TargetID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary      Изменить пользовательские поля тикета
+// @Description  Значение null удаляет необязательное поле
+// @Tags         support
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id    path   string                       true  "UUID тикета"
+// @Param        body  body   tickets.UpdateFieldsRequest  true  "Значения полей"
+// @Success      200   {object}  tickets.Ticket
+// @Failure      400   {object}  map[string]any
+// @Failure      401   {object}  map[string]string
+// @Failure      403   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Router       /support/tickets/{id}/fields [patch]
+func (h *handler) UpdateFields(c *gin.Context) {
+	var req UpdateFieldsRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid ticketID"})
+		return
+	}
+
+	role := c.GetString("role")
+	userID := c.GetInt("userID")
+
+	ticket, err := h.service.UpdateFields(c.Request.Context(), userID, role, ticketID, req.Fields)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ticket)
+}
+
+// @Summary      Получить теги тикета
+// @Tags         support
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id   path   string   true   "UUID тикета"
+// @Success      200   {array}   tags.Tag
+// @Failure      400   {object}  map[string]string
+// @Failure      401   {object}  map[string]string
+// @Failure      403   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Router       /support/tickets/{id}/tags [get]
+func (h *handler) GetTags(c *gin.Context) {
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid ticketID"})
+		return
+	}
+
+	role := c.GetString("role")
+	userID := c.GetInt("userID")
+
+	ticketTags, err := h.service.GetTags(c.Request.Context(), userID, role, ticketID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ticketTags)
+}
+
+// @Summary      Добавить тег к тикету
+// @Tags         support
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id    path   string                 true  "UUID тикета"
+// @Param        body  body   tickets.AddTagRequest  true  "Тег"
+// @Success      200   {array}   tags.Tag
+// @Failure      400   {object}  map[string]string
+// @Failure      401   {object}  map[string]string
+// @Failure      403   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Router       /support/tickets/{id}/tags [post]
+func (h *handler) AddTag(c *gin.Context) {
+	var req AddTagRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid ticketID"})
+		return
+	}
+
+	role := c.GetString("role")
+	userID := c.GetInt("userID")
+
+	ticketTags, err := h.service.AddTag(c.Request.Context(), userID, role, ticketID, req.TagID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ticketTags)
+}
+
+// @Summary      Снять тег с тикета
+// @Tags         support
+// @Accept       json
+// @Produce      json
+// @Security     Bearer
+// @Param        id     path   string  true  "UUID тикета"
+// @Param        tagID  path   int     true  "ID тега"
+// @Success      200   {array}   tags.Tag
+// @Failure      400   {object}  map[string]string
+// @Failure      401   {object}  map[string]string
+// @Failure      403   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Router       /support/tickets/{id}/tags/{tagID} [delete]
+func (h *handler) RemoveTag(c *gin.Context) {
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid ticketID"})
+		return
+	}
+
+	tagID, err := strconv.Atoi(c.Param("tagID"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid tag id"})
+		return
+	}
+
+	role := c.GetString("role")
+	userID := c.GetInt("userID")
+
+	ticketTags, err := h.service.RemoveTag(c.Request.Context(), userID, role, ticketID, tagID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ticketTags)
+}
+
 // @Summary      Отправить сообщение от имени поддержки
 // @Tags         support
 // @Accept       json
@@ -487,8 +817,34 @@ func (h *handler) GetMessagesForSupport(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"messages": messages, "nextCursor": nextCursor})
 }
 
+// FilterFromQuery reads the ticket list filter from the status, category_id,
+// tag and field[key] query parameters and normalizes it.
+func FilterFromQuery(c *gin.Context) (Filter, error) {
+	filter := Filter{
+		Status:   c.Query("status"),
+		Priority: c.Query("priority"),
+		Team:     c.Query("team"),
+		Tags:     c.QueryArray("tag"),
+		Fields:   c.QueryMap("field"),
+	}
+
+	if raw := c.Query("category_id"); raw != "" {
+		categoryID, err := strconv.Atoi(raw)
+		if err != nil {
+			return filter, ErrInvalidCategoryID
+		}
+		filter.CategoryID = &categoryID
+	}
+
+	return NormalizeFilter(filter)
+}
+
 func (h *handler) handleError(c *gin.Context, err error) {
+	var fieldsErr customfields.ValidationError
+
 	switch {
+	case errors.As(err, &fieldsErr):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid fields", "fields": fieldsErr})
 	case errors.Is(err, ErrForbidden):
 		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": ErrForbidden.Error()})
 	case errors.Is(err, ErrUnauthorized):
@@ -497,10 +853,16 @@ func (h *handler) handleError(c *gin.Context, err error) {
 		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrRatingNotFound.Error()})
 	case errors.Is(err, ErrTicketNotFound):
 		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": ErrTicketNotFound.Error()})
+	case errors.Is(err, tags.ErrTagNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": tags.ErrTagNotFound.Error()})
 	case errors.Is(err, ErrUnknownChannel):
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrUnknownChannel.Error()})
 	case errors.Is(err, ErrInvalidStatus):
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrInvalidStatus.Error()})
+	case errors.Is(err, ErrInvalidPriority), errors.Is(err, ErrInvalidTeam), errors.Is(err, ErrInvalidCategoryID), errors.Is(err, ErrInvalidTag):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrSameCategory):
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": ErrSameCategory.Error()})
 	case errors.Is(err, ErrInvalidScore):
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrInvalidScore.Error()})
 	case errors.Is(err, ErrClosedTicket):
@@ -513,6 +875,12 @@ func (h *handler) handleError(c *gin.Context, err error) {
 		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": ErrCannotAssign.Error()})
 	case errors.Is(err, ErrSupportCannotWrite):
 		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": ErrSupportCannotWrite.Error()})
+	case errors.Is(err, ErrMergeSameTicket):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": ErrMergeSameTicket.Error()})
+	case errors.Is(err, ErrMergeContact):
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": ErrMergeContact.Error()})
+	case errors.Is(err, ErrTicketMerged):
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": ErrTicketMerged.Error()})
 	case errors.Is(err, ErrAlreadyRated):
 		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": ErrRatingNotFound.Error()})
 	default:
@@ -5,21 +5,25 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Ticket struct {
-	ID         uuid.UUID `json:"id" db:"id"`
-	CategoryID int       `json:"category_id" db:"category_id"`
-	ContactID  int       `json:"creator_id" db:"contact_id"`
-	AssignedTo *int      `json:"assigned_to" db:"assigned_id"`
-	Status     string    `json:"status" db:"status"`
-	Source     string    `json:"source" db:"source"`
-	Metadata   Metadata  `json:"metadata" db:"metadata"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	ID         uuid.UUID  `json:"id" db:"id"`
+	CategoryID int        `json:"category_id" db:"category_id"`
+	ContactID  int        `json:"creator_id" db:"contact_id"`
+	AssignedTo *int       `json:"assigned_to" db:"assigned_id"`
+	Status     string     `json:"status" db:"status"`
+	Priority   string     `json:"priority" db:"priority"`
+	Team       *string    `json:"team,omitempty" db:"team"`
+	Source     string     `json:"source" db:"source"`
+	Metadata   Metadata   `json:"metadata" db:"metadata"`
+	MergedInto *uuid.UUID `json:"merged_into,omitempty" db:"merged_into"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 type Message struct {
@@ -40,7 +44,31 @@ type Rating struct {
 }
 
 type CreateTicketRequest struct {
-	CategoryID int `json:"category_id" binding:"required"`
+	CategoryID int            `json:"category_id" binding:"required"`
+	Fields     map[string]any `json:"fields"`
+}
+
+type Filter struct {
+	Status     string            `json:"status,omitempty"`
+	CategoryID *int              `json:"category_id,omitempty"`
+	Priority   string            `json:"priority,omitempty"`
+	Team       string            `json:"team,omitempty"`
+	Tags       []string          `json:"tags,omitempty"`
+	Fields     map[string]string `json:"fields,omitempty"`
+}
+
+// Empty reports whether the filter has no criteria and so selects every
+// ticket.
+func (f Filter) Empty() bool {
+	return f.Status == "" && f.CategoryID == nil && f.Priority == "" && f.Team == "" && len(f.Tags) == 0 && len(f.Fields) == 0
+}
+
+type UpdateFieldsRequest struct {
+	Fields map[string]any `json:"fields" binding:"required"`
+}
+
+type AddTagRequest struct {
+	TagID int `json:"tag_id" binding:"required"`
 }
 
 type CreateTicketResponse struct {
@@ -48,6 +76,15 @@ type CreateTicketResponse struct {
 	FirstMessage *MessageWithButtons `json:"first_message,omitempty"`
 }
 
+// MergeTicketResponse is the merged target ticket with its rating after the
+// merge. DiscardedRating is the rating of the source that was not moved
+// because the target was already rated.
+type MergeTicketResponse struct {
+	*Ticket
+	Rating          *Rating `json:"rating,omitempty"`
+	DiscardedRating *Rating `json:"discarded_rating,omitempty"`
+}
+
 type MessageWithButtons struct {
 	*Message
 	Buttons []string `json:"buttons,omitempty"`
@@ -61,6 +98,22 @@ type ChangeStatusRequest struct {
 	Status string `json:"status" binding:"required"`
 }
 
+type ChangePriorityRequest struct {
+	Priority string `json:"priority" binding:"required"`
+}
+
+type ChangeTeamRequest struct {
+	Team string `json:"team"`
+}
+
+type ChangeCategoryRequest struct {
+	CategoryID int `json:"category_id" binding:"required"`
+}
+
+type MergeTicketRequest struct {
+	TargetID uuid.UUID `json:"target_id" binding:"required"`
+}
+
 type CreateMessageRequest struct {
 	Content string `json:"content" binding:"required,min=1,max=150"`
 }
@@ -73,7 +126,11 @@ type CreateRatingRequest struct {
 type Metadata map[string]any
 
 func (m Metadata) Value() (driver.Value, error) {
-	return json.Marshal(m)
+	if m == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
 }
 
 func (m *Metadata) Scan(value any) error {
@@ -108,6 +165,14 @@ var (
 	ErrSupportCannotWrite = errors.New("you cannot write to this ticket")
 	ErrAlreadyRated       = errors.New("ticket already rated")
 	ErrInvalidScore       = errors.New("score must be between 1 and 5")
+	ErrMergeSameTicket    = errors.New("cannot merge ticket into itself")
+	ErrMergeContact       = errors.New("tickets belong to different contacts")
+	ErrTicketMerged       = errors.New("ticket already merged")
+	ErrInvalidCategoryID  = errors.New("invalid category id")
+	ErrInvalidPriority    = errors.New("priority must be one of low, normal, high, urgent")
+	ErrInvalidTeam        = errors.New("team must be at most 64 characters")
+	ErrSameCategory       = errors.New("ticket already belongs to this category")
+	ErrInvalidTag         = errors.New("invalid tag")
 )
 
 const (
@@ -117,15 +182,52 @@ const (
 	statusClosed     = "closed"
 
 	userRole = "user"
+	botRole  = "bot"
+)
+
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+
+	maxTeamLength = 64
 )
 
-func NewTicket(contactID int, source string, req CreateTicketRequest) *Ticket {
+// variablesKey is the metadata key holding variables collected by the bot.
+const variablesKey = "variables"
+
+// ValidPriority reports whether priority is a known ticket priority.
+func ValidPriority(priority string) bool {
+	switch priority {
+	case PriorityLow, PriorityNormal, PriorityHigh, PriorityUrgent:
+		return true
+	default:
+		return false
+	}
+}
+
+// NormalizeTeam trims and lowercases a team name. An empty name removes the
+// team from the ticket.
+func NormalizeTeam(team string) (string, bool) {
+	team = strings.ToLower(strings.TrimSpace(team))
+	return team, len(team) <= maxTeamLength
+}
+
+// ValidStatus reports whether status is a known ticket status.
+func ValidStatus(status string) bool {
+	return checkStatus(status)
+}
+
+func NewTicket(contactID int, source string, req CreateTicketRequest, fields Metadata) *Ticket {
 	return &Ticket{
 		ID:         uuid.Must(uuid.NewV7()),
 		CategoryID: req.CategoryID,
 		ContactID:  contactID,
 		Status:     statusPending,
+		Priority:   PriorityNormal,
 		Source:     source,
+		Metadata:   fields,
 	}
 }
 
@@ -6,8 +6,10 @@ import (
 	"errors"
 	"strconv"
 
+	"github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type repository struct {
@@ -22,8 +24,8 @@ func NewRepository(db *sqlx.DB) Repository {
 
 func (r *repository) Create(ctx context.Context, tx *sqlx.Tx, ticket *Ticket) error {
 	query := `
- 		INSERT INTO tickets(id, category_id, contact_id, status, source) 
- 		VALUES ($1, $2, $3, $4, $5) 
+ 		INSERT INTO tickets(id, category_id, contact_id, status, priority, source, metadata) 
+ 		VALUES ($1, $2, $3, $4, $5, $6, $7) 
  		RETURNING created_at, updated_at
 	`
 
@@ -32,7 +34,9 @@ func (r *repository) Create(ctx context.Context, tx *sqlx.Tx, ticket *Ticket) er
 		ticket.CategoryID,
 		ticket.ContactID,
 		ticket.Status,
+		ticket.Priority,
 		ticket.Source,
+		ticket.Metadata,
 	).Scan(&ticket.CreatedAt, &ticket.UpdatedAt)
 
 	return err
@@ -53,34 +57,37 @@ func (r *repository) GetByContact(ctx context.Context, creatorID int) ([]Ticket,
 	return tickets, err
 }
 
-func (r *repository) GetSupportTickets(ctx context.Context, assignedTo int) ([]Ticket, error) {
-	tickets := make([]Ticket, 0)
-
-	query := `
-		SELECT *
-		FROM tickets 
-		WHERE status = $1 OR assigned_id = $2 
-		ORDER BY created_at DESC
-	`
+func (r *repository) GetSupportTickets(ctx context.Context, assignedTo int, filter Filter) ([]Ticket, error) {
+	builder := squirrel.Select("*").
+		PlaceholderFormat(squirrel.Dollar).
+		From("tickets").
+		Where(squirrel.Or{
+			squirrel.Eq{"status": statusOpen},
+			squirrel.Eq{"assigned_id": assignedTo},
+		}).
+		OrderBy("created_at DESC")
+
+	return r.selectTickets(ctx, ApplyFilter(builder, filter))
+}
 
-	err := r.db.SelectContext(ctx, &tickets, query,
-		statusOpen,
-		assignedTo,
-	)
+func (r *repository) GetAll(ctx context.Context, filter Filter) ([]Ticket, error) {
+	builder := squirrel.Select("*").
+		PlaceholderFormat(squirrel.Dollar).
+		From("tickets").
+		OrderBy("created_at DESC")
 
-	return tickets, err
+	return r.selectTickets(ctx, ApplyFilter(builder, filter))
 }
 
-func (r *repository) GetAll(ctx context.Context) ([]Ticket, error) {
+func (r *repository) selectTickets(ctx context.Context, builder squirrel.SelectBuilder) ([]Ticket, error) {
 	tickets := make([]Ticket, 0)
 
-	query := `
-		SELECT *
-		FROM tickets 
-		ORDER BY created_at DESC
-	`
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return tickets, err
+	}
 
-	err := r.db.SelectContext(ctx, &tickets, query)
+	err = r.db.SelectContext(ctx, &tickets, query, args...)
 
 	return tickets, err
 }
@@ -140,6 +147,87 @@ func (r *repository) ChangeStatus(ctx context.Context, status string, ticketID u
 	return err
 }
 
+func (r *repository) ChangePriority(ctx context.Context, ticketID uuid.UUID, priority string) (Ticket, error) {
+	var ticket Ticket
+
+	query := `
+		UPDATE tickets
+		SET priority = $2, updated_at = now()
+		WHERE id = $1
+		RETURNING *
+	`
+
+	err := r.db.QueryRowxContext(ctx, query, ticketID, priority).StructScan(&ticket)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ticket, ErrTicketNotFound
+	}
+
+	return ticket, err
+}
+
+func (r *repository) ChangeTeam(ctx context.Context, ticketID uuid.UUID, team string) (Ticket, error) {
+	var ticket Ticket
+
+	query := `
+		UPDATE tickets
+		SET team = nullif($2, ''), updated_at = now()
+		WHERE id = $1
+		RETURNING *
+	`
+
+	err := r.db.QueryRowxContext(ctx, query, ticketID, team).StructScan(&ticket)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ticket, ErrTicketNotFound
+	}
+
+	return ticket, err
+}
+
+func (r *repository) ChangeCategory(ctx context.Context, ticketID uuid.UUID, categoryID int) (Ticket, error) {
+	var ticket Ticket
+
+	query := `
+		UPDATE tickets
+		SET category_id = $2, updated_at = now()
+		WHERE id = $1
+		RETURNING *
+	`
+
+	err := r.db.QueryRowxContext(ctx, query, ticketID, categoryID).StructScan(&ticket)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ticket, ErrTicketNotFound
+	}
+
+	return ticket, err
+}
+
+func (r *repository) MergeMetadata(ctx context.Context, ticketID uuid.UUID, set Metadata, unset []string) (Ticket, error) {
+	var ticket Ticket
+
+	if set == nil {
+		set = Metadata{}
+	}
+
+	query := `
+		UPDATE tickets
+		SET metadata = (coalesce(nullif(metadata, 'null'::jsonb), '{}'::jsonb) || $2::jsonb) - $3::text[],
+		    updated_at = now()
+		WHERE id = $1
+		RETURNING *
+	`
+
+	err := r.db.QueryRowxContext(ctx, query,
+		ticketID,
+		set,
+		pq.StringArray(unset),
+	).StructScan(&ticket)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ticket, ErrTicketNotFound
+	}
+
+	return ticket, err
+}
+
 func (r *repository) CreateRating(ctx context.Context, rating *Rating) error {
 	query := `
         INSERT INTO ticket_ratings(ticket_id, contact_id, score, reason)
@@ -221,6 +309,139 @@ func (r *repository) GetMessages(ctx context.Context, ticketID uuid.UUID, limit
 	return messages, err
 }
 
+func (r *repository) IsSameContact(ctx context.Context, contactID, otherContactID int) (bool, error) {
+	var same bool
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM contacts a
+			JOIN contacts b ON b.phone = a.phone
+			WHERE a.id = $1 AND b.id = $2 AND a.phone IS NOT NULL AND a.phone != ''
+		)
+	`
+
+	err := r.db.GetContext(ctx, &same, query, contactID, otherContactID)
+
+	return same, err
+}
+
+func (r *repository) MoveMessages(ctx context.Context, tx *sqlx.Tx, fromID, toID uuid.UUID) error {
+	query := `
+		UPDATE messages
+		SET ticket_id = $2
+		WHERE ticket_id = $1
+	`
+
+	_, err := tx.ExecContext(ctx, query, fromID, toID)
+
+	return err
+}
+
+func (r *repository) MoveActivity(ctx context.Context, tx *sqlx.Tx, fromID, toID uuid.UUID) error {
+	query := `
+		UPDATE activity_log
+		SET ticket_id = $2
+		WHERE ticket_id = $1
+	`
+
+	_, err := tx.ExecContext(ctx, query, fromID, toID)
+
+	return err
+}
+
+func (r *repository) MoveRating(ctx context.Context, tx *sqlx.Tx, fromID, toID uuid.UUID) error {
+	query := `
+		UPDATE ticket_ratings
+		SET ticket_id = $2
+		WHERE ticket_id = $1
+		  AND NOT EXISTS (SELECT 1 FROM ticket_ratings WHERE ticket_id = $2)
+	`
+
+	_, err := tx.ExecContext(ctx, query, fromID, toID)
+
+	return err
+}
+
+func (r *repository) MarkMerged(ctx context.Context, tx *sqlx.Tx, sourceID, targetID uuid.UUID) error {
+	query := `
+		UPDATE tickets
+		SET status = $3, merged_into = $2, updated_at = now()
+		WHERE id = $1
+	`
+
+	_, err := tx.ExecContext(ctx, query, sourceID, targetID, statusClosed)
+
+	return err
+}
+
+// LockForMerge reads the tickets with a row lock held until the end of the
+// transaction. Rows are locked in id order so concurrent merges cannot
+// deadlock.
+func (r *repository) LockForMerge(ctx context.Context, tx *sqlx.Tx, ids ...uuid.UUID) (map[uuid.UUID]Ticket, error) {
+	var tickets []Ticket
+
+	query := `
+		SELECT *
+		FROM tickets
+		WHERE id = ANY($1)
+		ORDER BY id
+		FOR UPDATE
+	`
+
+	if err := tx.SelectContext(ctx, &tickets, query, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	locked := make(map[uuid.UUID]Ticket, len(tickets))
+	for _, ticket := range tickets {
+		locked[ticket.ID] = ticket
+	}
+
+	for _, id := range ids {
+		if _, ok := locked[id]; !ok {
+			return nil, ErrTicketNotFound
+		}
+	}
+
+	return locked, nil
+}
+
 func (r *repository) BeginTxx(ctx context.Context) (*sqlx.Tx, error) {
 	return r.db.BeginTxx(ctx, nil)
 }
+
+// ApplyFilter adds the ticket list filter conditions to a query selecting from
+// the tickets table. Filter tags are expected to be normalized.
+func ApplyFilter(builder squirrel.SelectBuilder, filter Filter) squirrel.SelectBuilder {
+	if filter.Status != "" {
+		builder = builder.Where(squirrel.Eq{"tickets.status": filter.Status})
+	}
+
+	if filter.CategoryID != nil {
+		builder = builder.Where(squirrel.Eq{"tickets.category_id": *filter.CategoryID})
+	}
+
+	if filter.Priority != "" {
+		builder = builder.Where(squirrel.Eq{"tickets.priority": filter.Priority})
+	}
+
+	if filter.Team != "" {
+		builder = builder.Where(squirrel.Eq{"tickets.team": filter.Team})
+	}
+
+	for _, tag := range filter.Tags {
+		builder = builder.Where(`EXISTS (
+			SELECT 1
+			FROM ticket_tags tt
+			JOIN tags tg ON tg.id = tt.tag_id
+			WHERE tt.ticket_id = tickets.id AND tg.name = ?
+		)`, tag)
+	}
+
+	for key, value := range filter.Fields {
+		builder = builder.Where("tickets.metadata ->> ? = ?", key, value)
+	}
+
+	return builder
+}
@@ -2,12 +2,15 @@ package tickets
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"log/slog"
 
 	"github.com/AzizovHikmatullo/j-support/internal/activity_log"
 	"github.com/AzizovHikmatullo/j-support/internal/categories"
+	"github.com/AzizovHikmatullo/j-support/internal/customfields"
+	"github.com/AzizovHikmatullo/j-support/internal/tags"
 	"github.com/AzizovHikmatullo/j-support/internal/ws"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -16,22 +19,34 @@ import (
 type Repository interface {
 	Create(ctx context.Context, tx *sqlx.Tx, ticket *Ticket) error
 	GetByContact(ctx context.Context, creatorID int) ([]Ticket, error)
-	GetSupportTickets(ctx context.Context, assignedTo int) ([]Ticket, error)
-	GetAll(ctx context.Context) ([]Ticket, error)
+	GetSupportTickets(ctx context.Context, assignedTo int, filter Filter) ([]Ticket, error)
+	GetAll(ctx context.Context, filter Filter) ([]Ticket, error)
 	GetByID(ctx context.Context, ticketID uuid.UUID) (Ticket, error)
 	ChangeAssigned(ctx context.Context, ticketID uuid.UUID, assignedTo int) (Ticket, error)
 	ChangeStatus(ctx context.Context, status string, ticketID uuid.UUID) error
+	ChangePriority(ctx context.Context, ticketID uuid.UUID, priority string) (Ticket, error)
+	ChangeTeam(ctx context.Context, ticketID uuid.UUID, team string) (Ticket, error)
+	ChangeCategory(ctx context.Context, ticketID uuid.UUID, categoryID int) (Ticket, error)
+	MergeMetadata(ctx context.Context, ticketID uuid.UUID, set Metadata, unset []string) (Ticket, error)
 
 	CreateRating(ctx context.Context, rating *Rating) error
 	GetRating(ctx context.Context, ticketID uuid.UUID) (Rating, error)
 
 	CreateMessage(ctx context.Context, tx *sqlx.Tx, message *Message) error
 	GetMessages(ctx context.Context, ticketID uuid.UUID, limit int, cursor *uuid.UUID) ([]Message, error)
+
+	IsSameContact(ctx context.Context, contactID, otherContactID int) (bool, error)
+	MoveMessages(ctx context.Context, tx *sqlx.Tx, fromID, toID uuid.UUID) error
+	MoveActivity(ctx context.Context, tx *sqlx.Tx, fromID, toID uuid.UUID) error
+	MoveRating(ctx context.Context, tx *sqlx.Tx, fromID, toID uuid.UUID) error
+	MarkMerged(ctx context.Context, tx *sqlx.Tx, sourceID, targetID uuid.UUID) error
+	LockForMerge(ctx context.Context, tx *sqlx.Tx, ids ...uuid.UUID) (map[uuid.UUID]Ticket, error)
+
 	BeginTxx(ctx context.Context) (*sqlx.Tx, error)
 }
 
 type scenarioService interface {
-	StartIfExists(ctx context.Context, ticketID uuid.UUID, categoryID int) (*Message, []string, error)
+	StartIfExists(ctx context.Context, ticket Ticket, role string) (*Message, []string, error)
 	HandleMessage(ctx context.Context, ticketID uuid.UUID, answer string) (*string, error)
 	GetButtonsForCurrentStep(ctx context.Context, ticketID uuid.UUID) ([]string, error)
 }
@@ -41,15 +56,19 @@ type service struct {
 	scenarioService scenarioService
 	activityLog     activity_log.Service
 	categoryRepo    categories.Repository
+	tagRepo         tags.Repository
+	fieldRepo       customfields.Repository
 	publisher       ws.Publisher
 
 	logger *slog.Logger
 }
 
-func NewService(repo Repository, categoryRepo categories.Repository, pub ws.Publisher, botService scenarioService, al activity_log.Service, logger *slog.Logger) Service {
+func NewService(repo Repository, categoryRepo categories.Repository, tagRepo tags.Repository, fieldRepo customfields.Repository, pub ws.Publisher, botService scenarioService, al activity_log.Service, logger *slog.Logger) Service {
 	return &service{
 		repo:            repo,
 		categoryRepo:    categoryRepo,
+		tagRepo:         tagRepo,
+		fieldRepo:       fieldRepo,
 		publisher:       pub,
 		scenarioService: botService,
 		activityLog:     al,
@@ -82,7 +101,24 @@ func (s *service) Create(ctx context.Context, contactID int, role string, source
 		return nil, ErrCategoryDisabled
 	}
 
-	ticket := NewTicket(contactID, source, req)
+	definitions, err := s.fieldRepo.GetForCategory(ctx, category.ID)
+	if err != nil {
+		return nil, fmt.Errorf("create ticket: get custom fields: %w", err)
+	}
+
+	values, err := customfields.Validate(definitions, req.Fields, false)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(Metadata, len(values))
+	for key, value := range values {
+		if value != nil {
+			fields[key] = value
+		}
+	}
+
+	ticket := NewTicket(contactID, source, req, fields)
 
 	err = s.repo.Create(ctx, tx, ticket)
 	if err != nil {
@@ -101,7 +137,7 @@ func (s *service) Create(ctx context.Context, contactID int, role string, source
 		Payload:   activity_log.Payload{"category_id": req.CategoryID, "source": source},
 	})
 
-	firstBotMessage, buttons, err := s.scenarioService.StartIfExists(ctx, ticket.ID, category.ID)
+	firstBotMessage, buttons, err := s.scenarioService.StartIfExists(ctx, *ticket, role)
 	if err != nil {
 		return nil, err
 	}
@@ -121,7 +157,12 @@ func (s *service) Create(ctx context.Context, contactID int, role string, source
 	}, nil
 }
 
-func (s *service) Get(ctx context.Context, role string, userID int) ([]Ticket, error) {
+func (s *service) Get(ctx context.Context, role string, userID int, filter Filter) ([]Ticket, error) {
+	filter, err := NormalizeFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
 	switch role {
 	case "user":
 		tickets, err := s.repo.GetByContact(ctx, userID)
@@ -131,14 +172,14 @@ func (s *service) Get(ctx context.Context, role string, userID int) ([]Ticket, e
 		return tickets, nil
 
 	case "support":
-		tickets, err := s.repo.GetSupportTickets(ctx, userID)
+		tickets, err := s.repo.GetSupportTickets(ctx, userID, filter)
 		if err != nil {
 			return tickets, fmt.Errorf("get tickets for support: %w", err)
 		}
 		return tickets, nil
 
 	case "admin":
-		tickets, err := s.repo.GetAll(ctx)
+		tickets, err := s.repo.GetAll(ctx, filter)
 		if err != nil {
 			return tickets, fmt.Errorf("get all tickets for admin: %w", err)
 		}
@@ -262,6 +303,156 @@ func (s *service) ChangeStatus(ctx context.Context, userID int, role string, tic
 	return nil
 }
 
+func (s *service) ChangePriority(ctx context.Context, userID int, role string, ticketID uuid.UUID, priority string) (Ticket, error) {
+	if !ValidPriority(priority) {
+		return Ticket{}, ErrInvalidPriority
+	}
+
+	ticket, err := s.repo.GetByID(ctx, ticketID)
+	if err != nil {
+		return Ticket{}, fmt.Errorf("get ticket by id: %w", err)
+	}
+
+	if role != botRole {
+		if err = checkAccess(userID, role, ticket); err != nil {
+			return Ticket{}, err
+		}
+	}
+
+	if ticket.Priority == priority {
+		return ticket, nil
+	}
+
+	updated, err := s.repo.ChangePriority(ctx, ticketID, priority)
+	if err != nil {
+		return Ticket{}, fmt.Errorf("change priority: %w", err)
+	}
+
+	s.activityLog.Log(ctx, activity_log.LogEntry{
+		TicketID:  ticketID,
+		ActorID:   userID,
+		ActorType: role,
+		Action:    activity_log.ActionPriorityChanged,
+		Payload:   activity_log.Payload{"from": ticket.Priority, "to": priority},
+	})
+
+	event := ws.Event{
+		Type:    "priority_changed",
+		Payload: map[string]any{"ticket_id": ticketID, "priority": priority},
+	}
+	if err = s.publisher.PublishToTicket(ticketID, event); err != nil {
+		s.logger.Error("failed to publish ws_event on change priority", "error", err.Error())
+	}
+
+	s.logger.Info("ticket priority changed", "ticket id", ticketID.String(), "priority", priority)
+	return updated, nil
+}
+
+func (s *service) ChangeTeam(ctx context.Context, userID int, role string, ticketID uuid.UUID, team string) (Ticket, error) {
+	team, ok := NormalizeTeam(team)
+	if !ok {
+		return Ticket{}, ErrInvalidTeam
+	}
+
+	ticket, err := s.repo.GetByID(ctx, ticketID)
+	if err != nil {
+		return Ticket{}, fmt.Errorf("get ticket by id: %w", err)
+	}
+
+	if role != botRole {
+		if err = checkAccess(userID, role, ticket); err != nil {
+			return Ticket{}, err
+		}
+	}
+
+	if ticket.Status == statusClosed {
+		return Ticket{}, ErrClosedTicket
+	}
+
+	updated, err := s.repo.ChangeTeam(ctx, ticketID, team)
+	if err != nil {
+		return Ticket{}, fmt.Errorf("change team: %w", err)
+	}
+
+	s.activityLog.Log(ctx, activity_log.LogEntry{
+		TicketID:  ticketID,
+		ActorID:   userID,
+		ActorType: role,
+		Action:    activity_log.ActionTeamChanged,
+		Payload:   activity_log.Payload{"from": ticket.Team, "to": updated.Team},
+	})
+
+	event := ws.Event{
+		Type:    "team_changed",
+		Payload: map[string]any{"ticket_id": ticketID, "team": updated.Team},
+	}
+	if err = s.publisher.PublishToTicket(ticketID, event); err != nil {
+		s.logger.Error("failed to publish ws_event on change team", "error", err.Error())
+	}
+
+	s.logger.Info("ticket team changed", "ticket id", ticketID.String(), "team", team)
+	return updated, nil
+}
+
+// ChangeCategory transfers the ticket to another enabled category. Custom
+// field values of the previous category are kept in the metadata.
+func (s *service) ChangeCategory(ctx context.Context, userID int, role string, ticketID uuid.UUID, categoryID int) (Ticket, error) {
+	ticket, err := s.repo.GetByID(ctx, ticketID)
+	if err != nil {
+		return Ticket{}, fmt.Errorf("get ticket by id: %w", err)
+	}
+
+	if role != botRole {
+		if err = checkAccess(userID, role, ticket); err != nil {
+			return Ticket{}, err
+		}
+	}
+
+	if ticket.Status == statusClosed {
+		return Ticket{}, ErrClosedTicket
+	}
+
+	if ticket.CategoryID == categoryID {
+		return Ticket{}, ErrSameCategory
+	}
+
+	category, err := s.categoryRepo.GetByID(ctx, categoryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Ticket{}, ErrInvalidCategoryID
+	}
+	if err != nil {
+		return Ticket{}, fmt.Errorf("get category by id: %w", err)
+	}
+
+	if !category.Enabled {
+		return Ticket{}, ErrCategoryDisabled
+	}
+
+	updated, err := s.repo.ChangeCategory(ctx, ticketID, categoryID)
+	if err != nil {
+		return Ticket{}, fmt.Errorf("change category: %w", err)
+	}
+
+	s.activityLog.Log(ctx, activity_log.LogEntry{
+		TicketID:  ticketID,
+		ActorID:   userID,
+		ActorType: role,
+		Action:    activity_log.ActionCategoryChanged,
+		Payload:   activity_log.Payload{"from": ticket.CategoryID, "to": categoryID},
+	})
+
+	event := ws.Event{
+		Type:    "category_changed",
+		Payload: map[string]any{"ticket_id": ticketID, "category_id": categoryID},
+	}
+	if err = s.publisher.PublishToTicket(ticketID, event); err != nil {
+		s.logger.Error("failed to publish ws_event on change category", "error", err.Error())
+	}
+
+	s.logger.Info("ticket category changed", "ticket id", ticketID.String(), "category id", categoryID)
+	return updated, nil
+}
+
 func (s *service) RateTicket(ctx context.Context, contactID int, ticketID uuid.UUID, req CreateRatingRequest) (Rating, error) {
 	if req.Score < 1 || req.Score > 5 {
 		return Rating{}, ErrInvalidScore
@@ -280,6 +471,10 @@ func (s *service) RateTicket(ctx context.Context, contactID int, ticketID uuid.U
 		return Rating{}, ErrForbidden
 	}
 
+	if ticket.MergedInto != nil {
+		return Rating{}, ErrTicketMerged
+	}
+
 	_, err = s.repo.GetRating(ctx, ticketID)
 	if err == nil {
 		return Rating{}, ErrAlreadyRated
@@ -310,6 +505,352 @@ func (s *service) RateTicket(ctx context.Context, contactID int, ticketID uuid.U
 	return *rating, nil
 }
 
+func (s *service) Merge(ctx context.Context, userID int, role string, sourceID, targetID uuid.UUID) (MergeTicketResponse, error) {
+	if sourceID == targetID {
+		return MergeTicketResponse{}, ErrMergeSameTicket
+	}
+
+	source, err := s.repo.GetByID(ctx, sourceID)
+	if err != nil {
+		return MergeTicketResponse{}, fmt.Errorf("merge: get source ticket: %w", err)
+	}
+
+	target, err := s.repo.GetByID(ctx, targetID)
+	if err != nil {
+		return MergeTicketResponse{}, fmt.Errorf("merge: get target ticket: %w", err)
+	}
+
+	if err = checkAccess(userID, role, source); err != nil {
+		return MergeTicketResponse{}, err
+	}
+
+	if err = checkAccess(userID, role, target); err != nil {
+		return MergeTicketResponse{}, err
+	}
+
+	if source.ContactID != target.ContactID {
+		same, err := s.repo.IsSameContact(ctx, source.ContactID, target.ContactID)
+		if err != nil {
+			return MergeTicketResponse{}, fmt.Errorf("merge: compare contacts: %w", err)
+		}
+		if !same {
+			return MergeTicketResponse{}, ErrMergeContact
+		}
+	}
+
+	tx, err := s.repo.BeginTxx(ctx)
+	if err != nil {
+		return MergeTicketResponse{}, fmt.Errorf("merge: begin tx: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// The tickets are checked again under a row lock, so two merges of the
+	// same pair in opposite directions cannot both succeed.
+	locked, err := s.repo.LockForMerge(ctx, tx, sourceID, targetID)
+	if err != nil {
+		return MergeTicketResponse{}, fmt.Errorf("merge: lock tickets: %w", err)
+	}
+	source, target = locked[sourceID], locked[targetID]
+
+	if source.MergedInto != nil || target.MergedInto != nil {
+		err = ErrTicketMerged
+		return MergeTicketResponse{}, err
+	}
+
+	if target.Status == statusClosed {
+		err = ErrClosedTicket
+		return MergeTicketResponse{}, err
+	}
+
+	if err = s.repo.MoveMessages(ctx, tx, sourceID, targetID); err != nil {
+		return MergeTicketResponse{}, fmt.Errorf("merge: move messages: %w", err)
+	}
+
+	if err = s.repo.MoveActivity(ctx, tx, sourceID, targetID); err != nil {
+		return MergeTicketResponse{}, fmt.Errorf("merge: move activity: %w", err)
+	}
+
+	if err = s.tagRepo.MoveToTicket(ctx, tx, sourceID, targetID); err != nil {
+		return MergeTicketResponse{}, fmt.Errorf("merge: move tags: %w", err)
+	}
+
+	if err = s.repo.MoveRating(ctx, tx, sourceID, targetID); err != nil {
+		return MergeTicketResponse{}, fmt.Errorf("merge: move rating: %w", err)
+	}
+
+	if err = s.repo.MarkMerged(ctx, tx, sourceID, targetID); err != nil {
+		return MergeTicketResponse{}, fmt.Errorf("merge: close source: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return MergeTicketResponse{}, fmt.Errorf("merge: tx commit: %w", err)
+	}
+
+	s.activityLog.Log(ctx, activity_log.LogEntry{
+		TicketID:  sourceID,
+		ActorID:   userID,
+		ActorType: role,
+		Action:    activity_log.ActionMerged,
+		Payload:   activity_log.Payload{"merged_into": targetID, "from": source.Status},
+	})
+
+	s.activityLog.Log(ctx, activity_log.LogEntry{
+		TicketID:  targetID,
+		ActorID:   userID,
+		ActorType: role,
+		Action:    activity_log.ActionMerged,
+		Payload:   activity_log.Payload{"merged_from": sourceID},
+	})
+
+	event := ws.Event{
+		Type:    "ticket_merged",
+		Payload: map[string]any{"ticket_id": sourceID, "target_id": targetID},
+	}
+
+	if err := s.publisher.PublishToTicket(sourceID, event); err != nil {
+		s.logger.Error("failed to publish ws_event on merge to source", "error", err.Error())
+	}
+
+	if err := s.publisher.PublishToTicket(targetID, event); err != nil {
+		s.logger.Error("failed to publish ws_event on merge to target", "error", err.Error())
+	}
+
+	merged, err := s.repo.GetByID(ctx, targetID)
+	if err != nil {
+		return MergeTicketResponse{}, fmt.Errorf("merge: get merged ticket: %w", err)
+	}
+
+	resp := MergeTicketResponse{Ticket: &merged}
+
+	// The target keeps its own rating; a rating of the source is moved only
+	// when the target has none and otherwise stays on the closed source.
+	if resp.Rating, err = s.findRating(ctx, targetID); err != nil {
+		return MergeTicketResponse{}, fmt.Errorf("merge: get rating: %w", err)
+	}
+
+	if resp.DiscardedRating, err = s.findRating(ctx, sourceID); err != nil {
+		return MergeTicketResponse{}, fmt.Errorf("merge: get source rating: %w", err)
+	}
+
+	s.logger.Info("ticket merged", "source id", sourceID.String(), "target id", targetID.String())
+	return resp, nil
+}
+
+func (s *service) findRating(ctx context.Context, ticketID uuid.UUID) (*Rating, error) {
+	rating, err := s.repo.GetRating(ctx, ticketID)
+	if errors.Is(err, ErrRatingNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rating, nil
+}
+
+func (s *service) UpdateFields(ctx context.Context, userID int, role string, ticketID uuid.UUID, values map[string]any) (Ticket, error) {
+	ticket, err := s.repo.GetByID(ctx, ticketID)
+	if err != nil {
+		return Ticket{}, fmt.Errorf("get ticket by id: %w", err)
+	}
+
+	if role != botRole {
+		if err = checkAccess(userID, role, ticket); err != nil {
+			return Ticket{}, err
+		}
+	}
+
+	definitions, err := s.fieldRepo.GetForCategory(ctx, ticket.CategoryID)
+	if err != nil {
+		return Ticket{}, fmt.Errorf("get custom fields: %w", err)
+	}
+
+	validated, err := customfields.Validate(definitions, values, true)
+	if err != nil {
+		return Ticket{}, err
+	}
+
+	set := make(Metadata, len(validated))
+	var unset []string
+	for key, value := range validated {
+		if value == nil {
+			unset = append(unset, key)
+			continue
+		}
+		set[key] = value
+	}
+
+	updated, err := s.repo.MergeMetadata(ctx, ticketID, set, unset)
+	if err != nil {
+		return Ticket{}, fmt.Errorf("update fields: %w", err)
+	}
+
+	s.activityLog.Log(ctx, activity_log.LogEntry{
+		TicketID:  ticketID,
+		ActorID:   userID,
+		ActorType: role,
+		Action:    activity_log.ActionFieldsUpdated,
+		Payload:   activity_log.Payload{"fields": validated},
+	})
+
+	event := ws.Event{
+		Type:    "fields_changed",
+		Payload: map[string]any{"ticket_id": ticketID, "metadata": updated.Metadata},
+	}
+	if err = s.publisher.PublishToTicket(ticketID, event); err != nil {
+		s.logger.Error("failed to publish ws_event on fields update", "error", err.Error())
+	}
+
+	s.logger.Info("ticket fields updated", "ticket id", ticketID.String())
+	return updated, nil
+}
+
+// SaveVariables stores the variables collected by the bot in the ticket
+// metadata under the "variables" key. A variable named after a custom field of
+// the ticket category also sets that field when its value is valid for it.
+func (s *service) SaveVariables(ctx context.Context, ticketID uuid.UUID, variables map[string]string) (Ticket, error) {
+	ticket, err := s.repo.GetByID(ctx, ticketID)
+	if err != nil {
+		return Ticket{}, fmt.Errorf("get ticket by id: %w", err)
+	}
+
+	definitions, err := s.fieldRepo.GetForCategory(ctx, ticket.CategoryID)
+	if err != nil {
+		return Ticket{}, fmt.Errorf("get custom fields: %w", err)
+	}
+
+	set := Metadata{variablesKey: variables}
+	fields := customfields.Values{}
+
+	for _, field := range definitions {
+		value, ok := variables[field.Key]
+		if !ok || field.Key == variablesKey {
+			continue
+		}
+
+		validated, err := customfields.Validate([]customfields.Field{field}, map[string]any{field.Key: value}, true)
+		if err != nil {
+			continue
+		}
+
+		set[field.Key] = validated[field.Key]
+		fields[field.Key] = validated[field.Key]
+	}
+
+	updated, err := s.repo.MergeMetadata(ctx, ticketID, set, nil)
+	if err != nil {
+		return Ticket{}, fmt.Errorf("save variables: %w", err)
+	}
+
+	s.activityLog.Log(ctx, activity_log.LogEntry{
+		TicketID:  ticketID,
+		ActorID:   0,
+		ActorType: botRole,
+		Action:    activity_log.ActionFieldsUpdated,
+		Payload:   activity_log.Payload{"fields": fields, "variables": variables},
+	})
+
+	event := ws.Event{
+		Type:    "fields_changed",
+		Payload: map[string]any{"ticket_id": ticketID, "metadata": updated.Metadata},
+	}
+	if err = s.publisher.PublishToTicket(ticketID, event); err != nil {
+		s.logger.Error("failed to publish ws_event on variables save", "error", err.Error())
+	}
+
+	s.logger.Info("ticket variables saved", "ticket id", ticketID.String())
+	return updated, nil
+}
+
+func (s *service) GetTags(ctx context.Context, userID int, role string, ticketID uuid.UUID) ([]tags.Tag, error) {
+	ticket, err := s.repo.GetByID(ctx, ticketID)
+	if err != nil {
+		return nil, fmt.Errorf("get ticket by id: %w", err)
+	}
+
+	if err = checkAccess(userID, role, ticket); err != nil {
+		return nil, err
+	}
+
+	ticketTags, err := s.tagRepo.GetByTicket(ctx, ticketID)
+	if err != nil {
+		return nil, fmt.Errorf("get ticket tags: %w", err)
+	}
+
+	return ticketTags, nil
+}
+
+func (s *service) AddTag(ctx context.Context, userID int, role string, ticketID uuid.UUID, tagID int) ([]tags.Tag, error) {
+	return s.changeTag(ctx, userID, role, ticketID, tagID, true)
+}
+
+func (s *service) RemoveTag(ctx context.Context, userID int, role string, ticketID uuid.UUID, tagID int) ([]tags.Tag, error) {
+	return s.changeTag(ctx, userID, role, ticketID, tagID, false)
+}
+
+func (s *service) changeTag(ctx context.Context, userID int, role string, ticketID uuid.UUID, tagID int, add bool) ([]tags.Tag, error) {
+	ticket, err := s.repo.GetByID(ctx, ticketID)
+	if err != nil {
+		return nil, fmt.Errorf("get ticket by id: %w", err)
+	}
+
+	if role != botRole {
+		if err = checkAccess(userID, role, ticket); err != nil {
+			return nil, err
+		}
+	}
+
+	tag, err := s.tagRepo.GetByID(ctx, tagID)
+	if err != nil {
+		return nil, fmt.Errorf("get tag by id: %w", err)
+	}
+
+	var changed bool
+
+	action := activity_log.ActionTagAdded
+	if add {
+		changed, err = s.tagRepo.AddToTicket(ctx, ticketID, tagID)
+	} else {
+		action = activity_log.ActionTagRemoved
+		changed, err = s.tagRepo.RemoveFromTicket(ctx, ticketID, tagID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("change ticket tag: %w", err)
+	}
+
+	ticketTags, err := s.tagRepo.GetByTicket(ctx, ticketID)
+	if err != nil {
+		return nil, fmt.Errorf("get ticket tags: %w", err)
+	}
+
+	if !changed {
+		return ticketTags, nil
+	}
+
+	s.activityLog.Log(ctx, activity_log.LogEntry{
+		TicketID:  ticketID,
+		ActorID:   userID,
+		ActorType: role,
+		Action:    action,
+		Payload:   activity_log.Payload{"tag_id": tag.ID, "tag": tag.Name},
+	})
+
+	event := ws.Event{
+		Type:    "tags_changed",
+		Payload: map[string]any{"ticket_id": ticketID, "tags": ticketTags},
+	}
+	if err = s.publisher.PublishToTicket(ticketID, event); err != nil {
+		s.logger.Error("failed to publish ws_event on tags change", "error", err.Error())
+	}
+
+	s.logger.Info("ticket tags changed", "ticket id", ticketID.String(), "tag", tag.Name, "added", add)
+	return ticketTags, nil
+}
+
 func (s *service) CreateMessage(ctx context.Context, ticketID uuid.UUID, senderID int, senderType, content string) (*Message, error) {
 	tx, err := s.repo.BeginTxx(ctx)
 	if err != nil {
@@ -526,6 +1067,33 @@ func checkAccess(userID int, role string, ticket Ticket) error {
 	}
 }
 
+// NormalizeFilter normalizes tag names and the team the same way they are
+// stored. An invalid value is an error rather than being dropped, since
+// dropping it would widen the filter.
+func NormalizeFilter(filter Filter) (Filter, error) {
+	names := make([]string, 0, len(filter.Tags))
+	for _, tag := range filter.Tags {
+		name, ok := tags.NormalizeName(tag)
+		if !ok {
+			return filter, ErrInvalidTag
+		}
+		names = append(names, name)
+	}
+	filter.Tags = names
+
+	if filter.Priority != "" && !ValidPriority(filter.Priority) {
+		return filter, ErrInvalidPriority
+	}
+
+	team, ok := NormalizeTeam(filter.Team)
+	if !ok {
+		return filter, ErrInvalidTeam
+	}
+	filter.Team = team
+
+	return filter, nil
+}
+
 func checkStatus(status string) bool {
 	return status == statusOpen || status == statusInProgress || status == statusClosed || status == statusPending
 }
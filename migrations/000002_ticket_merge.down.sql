@@ -0,0 +1,3 @@
+drop index if exists idx_tickets_merged_into;
+
+alter table tickets drop column if exists merged_into;
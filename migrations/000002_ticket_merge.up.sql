@@ -0,0 +1,3 @@
+alter table tickets add column merged_into uuid references tickets(id);
+
+create index idx_tickets_merged_into on tickets(merged_into);
@@ -0,0 +1,4 @@
+drop index if exists idx_ticket_tags_tag_id;
+
+drop table if exists ticket_tags;
+drop table if exists tags;
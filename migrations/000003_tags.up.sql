@@ -0,0 +1,15 @@
+create table tags (
+    id serial primary key,
+    name text not null unique,
+    created_at timestamp not null default now()
+);
+
+create table ticket_tags (
+    ticket_id uuid not null references tickets(id) on delete cascade,
+    tag_id int not null references tags(id) on delete cascade,
+    created_at timestamp not null default now(),
+
+    primary key (ticket_id, tag_id)
+);
+
+create index idx_ticket_tags_tag_id on ticket_tags(tag_id);
@@ -0,0 +1,8 @@
+drop index if exists idx_tickets_metadata;
+drop index if exists idx_custom_fields_entity_category_key;
+
+alter table bot_steps drop column if exists set_fields;
+
+alter table contacts drop column if exists metadata;
+
+drop table if exists custom_fields;
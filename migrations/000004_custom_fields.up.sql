@@ -0,0 +1,19 @@
+create table custom_fields (
+    id serial primary key,
+    entity text not null,
+    category_id int references categories(id) on delete cascade,
+    key text not null,
+    label text not null,
+    type text not null,
+    required bool not null default false,
+    options text[] not null default '{}',
+    created_at timestamp not null default now()
+);
+
+alter table contacts add column metadata jsonb;
+
+alter table bot_steps add column set_fields jsonb;
+
+create unique index idx_custom_fields_entity_category_key on custom_fields(entity, coalesce(category_id, 0), key);
+
+create index idx_tickets_metadata on tickets using gin (metadata);
@@ -0,0 +1,3 @@
+drop index if exists idx_bulk_jobs_created_by;
+
+drop table if exists bulk_jobs;
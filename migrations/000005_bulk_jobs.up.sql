@@ -0,0 +1,15 @@
+create table bulk_jobs (
+    id uuid primary key,
+    created_by int not null,
+    actor_role text not null,
+    status text not null,
+    params jsonb not null,
+    total int not null default 0,
+    processed int not null default 0,
+    failed int not null default 0,
+    failures jsonb not null default '[]',
+    created_at timestamp not null default now(),
+    finished_at timestamp
+);
+
+create index idx_bulk_jobs_created_by on bulk_jobs(created_by, created_at desc);
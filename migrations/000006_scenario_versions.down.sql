@@ -0,0 +1,21 @@
+drop index if exists idx_bot_sessions_version_id;
+drop index if exists idx_bot_steps_version_parent_null;
+drop index if exists idx_bot_steps_version_id;
+drop index if exists idx_bot_scenario_versions_draft;
+
+-- only the published tree of each scenario survives
+delete from bot_sessions bs
+using bot_scenarios s
+where s.id = bs.scenario_id and bs.version_id is distinct from s.published_version_id;
+
+delete from bot_steps st
+using bot_scenarios s
+where s.id = st.scenario_id and st.version_id is distinct from s.published_version_id;
+
+create index idx_bot_steps_scenario_parent_null on bot_steps(scenario_id) where parent_id is null;
+
+alter table bot_sessions drop column if exists version_id;
+alter table bot_steps drop column if exists version_id;
+alter table bot_scenarios drop column if exists published_version_id;
+
+drop table if exists bot_scenario_versions;
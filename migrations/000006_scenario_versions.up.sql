@@ -0,0 +1,32 @@
+create table bot_scenario_versions (
+    id serial primary key,
+    scenario_id int not null references bot_scenarios(id) on delete cascade,
+    number int not null,
+    status text not null,
+    created_at timestamp not null default now(),
+    published_at timestamp,
+
+    unique (scenario_id, number)
+);
+
+alter table bot_scenarios add column published_version_id int references bot_scenario_versions(id);
+alter table bot_steps add column version_id int references bot_scenario_versions(id) on delete cascade;
+alter table bot_sessions add column version_id int references bot_scenario_versions(id) on delete cascade;
+
+-- every existing tree becomes the first published version of its scenario
+insert into bot_scenario_versions(scenario_id, number, status, published_at)
+select id, 1, 'published', now() from bot_scenarios;
+
+update bot_scenarios s set published_version_id = v.id from bot_scenario_versions v where v.scenario_id = s.id;
+update bot_steps st set version_id = v.id from bot_scenario_versions v where v.scenario_id = st.scenario_id;
+update bot_sessions bs set version_id = v.id from bot_scenario_versions v where v.scenario_id = bs.scenario_id;
+
+alter table bot_steps alter column version_id set not null;
+alter table bot_sessions alter column version_id set not null;
+
+drop index if exists idx_bot_steps_scenario_parent_null;
+
+create unique index idx_bot_scenario_versions_draft on bot_scenario_versions(scenario_id) where status = 'draft';
+create index idx_bot_steps_version_id on bot_steps(version_id);
+create index idx_bot_steps_version_parent_null on bot_steps(version_id) where parent_id is null;
+create index idx_bot_sessions_version_id on bot_sessions(version_id);
@@ -0,0 +1,3 @@
+drop index if exists idx_bot_steps_version_key;
+
+alter table bot_steps drop column if exists key;
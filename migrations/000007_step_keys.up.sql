@@ -0,0 +1,3 @@
+alter table bot_steps add column key text;
+
+create unique index idx_bot_steps_version_key on bot_steps(version_id, key) where key is not null;
@@ -0,0 +1,2 @@
+alter table bot_steps drop column if exists button_label;
+alter table bot_steps drop column if exists match_mode;
@@ -0,0 +1,2 @@
+alter table bot_steps add column match_mode text not null default 'contains';
+alter table bot_steps add column button_label text;
@@ -0,0 +1 @@
+alter table bot_steps add column match_threshold double precision;
@@ -0,0 +1,6 @@
+alter table bot_sessions drop column if exists variables;
+
+alter table bot_steps drop column if exists error_message;
+alter table bot_steps drop column if exists validator_pattern;
+alter table bot_steps drop column if exists validator;
+alter table bot_steps drop column if exists variable;
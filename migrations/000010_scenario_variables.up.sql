@@ -0,0 +1,6 @@
+alter table bot_steps add column variable text;
+alter table bot_steps add column validator text;
+alter table bot_steps add column validator_pattern text;
+alter table bot_steps add column error_message text;
+
+alter table bot_sessions add column variables jsonb not null default '{}'::jsonb;
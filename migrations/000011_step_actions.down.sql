@@ -0,0 +1,7 @@
+alter table bot_steps drop column if exists actions;
+
+drop index if exists idx_tickets_team;
+drop index if exists idx_tickets_priority;
+
+alter table tickets drop column if exists team;
+alter table tickets drop column if exists priority;
@@ -0,0 +1,8 @@
+alter table tickets add column priority text not null default 'normal'
+    check (priority in ('low', 'normal', 'high', 'urgent'));
+alter table tickets add column team text;
+
+create index idx_tickets_priority on tickets(priority);
+create index idx_tickets_team on tickets(team);
+
+alter table bot_steps add column actions jsonb;
@@ -0,0 +1,3 @@
+alter table bot_sessions drop column if exists history;
+
+alter table bot_scenarios drop column if exists commands;
@@ -0,0 +1,3 @@
+alter table bot_scenarios add column commands jsonb;
+
+alter table bot_sessions add column history integer[] not null default '{}';
@@ -0,0 +1,11 @@
+drop index if exists idx_bot_steps_goto_step_id;
+
+alter table bot_sessions drop column if exists jumps;
+alter table bot_sessions drop column if exists call_stack;
+
+-- sub-flow roots would become extra roots of the tree
+delete from bot_steps where subflow;
+
+alter table bot_steps drop column if exists subflow;
+alter table bot_steps drop column if exists goto_mode;
+alter table bot_steps drop column if exists goto_step_id;
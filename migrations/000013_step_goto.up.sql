@@ -0,0 +1,8 @@
+alter table bot_steps add column goto_step_id int references bot_steps(id) on delete set null;
+alter table bot_steps add column goto_mode text not null default 'jump';
+alter table bot_steps add column subflow boolean not null default false;
+
+alter table bot_sessions add column call_stack integer[] not null default '{}';
+alter table bot_sessions add column jumps int not null default 0;
+
+create index idx_bot_steps_goto_step_id on bot_steps(goto_step_id);
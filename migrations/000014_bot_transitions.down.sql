@@ -0,0 +1,3 @@
+drop table if exists bot_transitions;
+
+alter table bot_sessions drop column if exists step_entered_at;
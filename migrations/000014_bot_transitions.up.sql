@@ -0,0 +1,19 @@
+alter table bot_sessions add column step_entered_at timestamp not null default now();
+
+create table bot_transitions (
+    id bigserial primary key,
+    ticket_id uuid not null references tickets(id) on delete cascade,
+    scenario_id int not null references bot_scenarios(id) on delete cascade,
+    version_id int not null,
+    event text not null check (event in ('start', 'answer', 'goto', 'back', 'restart', 'handoff', 'timeout', 'resolved')),
+    from_step_id int,
+    to_step_id int,
+    matched_condition text,
+    default_used boolean not null default false,
+    reason text,
+    duration_ms bigint not null default 0,
+    created_at timestamp not null default now()
+);
+
+create index idx_bot_transitions_scenario_id on bot_transitions(scenario_id, created_at);
+create index idx_bot_transitions_ticket_id on bot_transitions(ticket_id);
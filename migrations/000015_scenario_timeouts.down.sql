@@ -0,0 +1,4 @@
+alter table bot_sessions drop column if exists reminded_at;
+
+alter table bot_steps drop column if exists timeout;
+alter table bot_scenarios drop column if exists timeout;
@@ -0,0 +1,4 @@
+alter table bot_scenarios add column timeout jsonb;
+alter table bot_steps add column timeout jsonb;
+
+alter table bot_sessions add column reminded_at timestamp;
@@ -0,0 +1,5 @@
+drop index if exists idx_bot_scenarios_category_active;
+create index idx_bot_scenarios_category_active on bot_scenarios(category_id, is_active);
+
+alter table bot_scenarios drop column if exists targeting;
+alter table bot_scenarios drop column if exists priority;
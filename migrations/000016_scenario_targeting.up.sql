@@ -0,0 +1,5 @@
+alter table bot_scenarios add column priority int not null default 0;
+alter table bot_scenarios add column targeting jsonb;
+
+drop index if exists idx_bot_scenarios_category_active;
+create index idx_bot_scenarios_category_active on bot_scenarios(category_id, is_active, priority desc);
@@ -0,0 +1,4 @@
+drop index if exists idx_bot_scenarios_experiment;
+
+alter table bot_scenarios drop column if exists weight;
+alter table bot_scenarios drop column if exists experiment;
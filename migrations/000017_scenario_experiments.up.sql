@@ -0,0 +1,4 @@
+alter table bot_scenarios add column experiment text;
+alter table bot_scenarios add column weight int not null default 1 check (weight >= 0);
+
+create index idx_bot_scenarios_experiment on bot_scenarios(category_id, experiment);
@@ -0,0 +1,5 @@
+alter table bot_sessions drop column if exists locale;
+
+alter table bot_steps drop column if exists translations;
+
+alter table contacts drop column if exists locale;
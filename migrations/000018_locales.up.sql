@@ -0,0 +1,5 @@
+alter table contacts add column locale text;
+
+alter table bot_steps add column translations jsonb;
+
+alter table bot_sessions add column locale text;
@@ -0,0 +1,2 @@
+alter table bot_scenarios drop column if exists schedule_active;
+alter table bot_scenarios drop column if exists schedule;
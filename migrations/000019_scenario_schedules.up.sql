@@ -0,0 +1,2 @@
+alter table bot_scenarios add column schedule jsonb;
+alter table bot_scenarios add column schedule_active boolean;
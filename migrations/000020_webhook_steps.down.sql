@@ -0,0 +1 @@
+alter table bot_steps drop column if exists webhook;
@@ -0,0 +1 @@
+alter table bot_steps add column webhook jsonb;
@@ -0,0 +1,3 @@
+alter table bot_sessions drop column if exists faq_rule_id;
+
+drop table if exists faq_rules;
@@ -0,0 +1,17 @@
+create table faq_rules (
+    id serial primary key,
+    category_id int references categories(id) on delete cascade,
+    condition text not null,
+    match_mode text not null default 'one_of',
+    match_threshold double precision,
+    answer text not null,
+    translations jsonb,
+    priority int not null default 0,
+    is_active boolean not null default true,
+    created_at timestamp not null default now(),
+    updated_at timestamp not null default now()
+);
+
+create index idx_faq_rules_category_id on faq_rules(category_id);
+
+alter table bot_sessions add column faq_rule_id int references faq_rules(id) on delete set null;